@@ -355,7 +355,9 @@ class TradingBot:
 
         self.session = aiohttp.ClientSession()
         trade_log_path = Path(self.trade_log_csv or "results/live_trades.csv")
-        self.trade_logger = TradeLogger(trade_log_path)
+        self.trade_logger = TradeLogger(
+            trade_log_path, decimals=self.config.logging.csv_decimals
+        )
 
         self.database = DatabaseManager(self.config.database.url)
         await self.database.initialize()
@@ -11,7 +11,11 @@ from prometheus_client import make_asgi_app
 from starlette.exceptions import HTTPException as StarletteHTTPException
 
 from src.api.middleware.auth import APIKeyMiddleware
-from src.api.middleware.error_handler import AppError, global_exception_handler
+from src.api.middleware.error_handler import (
+    AppError,
+    ServiceWarmingUp,
+    global_exception_handler,
+)
 from src.api.middleware.rate_limit import (
     RateLimitExceeded,
     limiter,
@@ -28,12 +32,14 @@ from src.api.routes.intelligence import get_db as get_db_intelligence
 from src.api.routes.intelligence import get_exchange as get_exchange_intelligence
 from src.api.routes.intelligence import intelligence_router
 from src.api.routes.market import get_db, get_exchange, market_router
+from src.api.routes.market import get_messaging as get_messaging_market
 from src.api.routes.notifications import get_db as get_db_notifications
 from src.api.routes.notifications import notifications_router
 from src.api.routes.portfolio import get_db as get_db_portfolio
 from src.api.routes.portfolio import portfolio_router
 from src.api.routes.presets import presets_router
 from src.api.routes.risk import get_db as get_db_risk
+from src.api.routes.risk import get_messaging as get_messaging_risk
 from src.api.routes.risk import risk_router
 from src.api.routes.signals import get_db as get_db_signals
 from src.api.routes.signals import signals_router
@@ -92,7 +98,12 @@ async def _ensure_messaging():
     if _state.messaging is None:
         config = get_config()
         try:
-            real_client = MessagingClient({"servers": config.messaging.servers})
+            real_client = MessagingClient(
+                {
+                    "servers": config.messaging.servers,
+                    "encoding": config.messaging.encoding,
+                }
+            )
             await real_client.connect()
             _state.messaging = real_client
         except Exception as e:
@@ -187,6 +198,8 @@ app.dependency_overrides[get_db_agents] = get_db_dependency
 app.dependency_overrides[get_messaging_agents] = get_messaging_dependency
 app.dependency_overrides[get_db_signals] = get_db_dependency
 app.dependency_overrides[get_db_risk] = get_db_dependency
+app.dependency_overrides[get_messaging_risk] = get_messaging_dependency
+app.dependency_overrides[get_messaging_market] = get_messaging_dependency
 app.dependency_overrides[get_db_notifications] = get_db_dependency
 app.dependency_overrides[get_db_portfolio] = get_db_dependency
 app.dependency_overrides[get_db_intelligence] = get_db_dependency
@@ -225,6 +238,7 @@ app.add_exception_handler(RateLimitExceeded, rate_limit_exceeded_handler)
 # Register Global Exception Handler
 app.add_exception_handler(Exception, global_exception_handler)
 app.add_exception_handler(AppError, global_exception_handler)
+app.add_exception_handler(ServiceWarmingUp, global_exception_handler)
 app.add_exception_handler(StarletteHTTPException, global_exception_handler)
 app.add_exception_handler(RequestValidationError, global_exception_handler)
 
@@ -22,9 +22,29 @@ class AppError(Exception):
         super().__init__(self.message)
 
 
+class ServiceWarmingUp(Exception):
+    """A backing service has not produced its first update yet.
+
+    Answered with 503 and ``{"status": "warming_up"}`` plus ``Retry-After``,
+    so dashboards do not render empty state as real zeros.
+    """
+
+    def __init__(self, service: str, retry_after: int = 5):
+        self.service = service
+        self.retry_after = retry_after
+        super().__init__(f"{service} is warming up")
+
+
 async def global_exception_handler(request: Request, exc: Exception):
     """Global exception handler for all unhandled exceptions."""
 
+    if isinstance(exc, ServiceWarmingUp):
+        return JSONResponse(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            content={"status": "warming_up", "service": exc.service},
+            headers={"Retry-After": str(exc.retry_after)},
+        )
+
     # Handle Application Errors
     if isinstance(exc, AppError):
         logger.warning(f"AppError: {exc.message} ({exc.status_code})")
@@ -1,4 +1,4 @@
-from typing import Any, Dict, List, Optional
+from typing import Any, Dict, List, Literal, Optional
 
 from pydantic import BaseModel, Field
 
@@ -65,7 +65,7 @@ class PaperConfigResponse(BaseModel):
     ofi_slippage_coeff: float
     latency_ms: Dict[str, float]
     partial_fill: Dict[str, Any]
-    price_source: str
+    price_source: Literal["live", "bars", "replay"]
 
 class PnLDailyEntry(BaseModel):
     date: str
@@ -1,18 +1,21 @@
 import logging
-from typing import List, Optional
+from typing import Any, Dict, List, Optional
 
 from fastapi import APIRouter, Depends, HTTPException, Query
 from pydantic import BaseModel
 
 logger = logging.getLogger(__name__)
 
+from src.api.middleware.error_handler import ServiceWarmingUp
 from src.api.models import (
     AccountSummaryResponse,
     OrderResponse,
     PositionResponse,
     TradeResponse,
 )
+from src.config import get_config
 from src.database import DatabaseManager
+from src.messaging import request_reply
 
 market_router = APIRouter()
 
@@ -23,6 +26,9 @@ def get_db():
 def get_exchange():
     raise NotImplementedError
 
+def get_messaging():
+    raise NotImplementedError
+
 @market_router.get("/api/account", response_model=AccountSummaryResponse)
 async def get_account_summary(exchange = Depends(get_exchange)) -> AccountSummaryResponse:
     if not exchange:
@@ -217,6 +223,62 @@ async def place_order(request: PlaceOrderRequest, exchange = Depends(get_exchang
         ) from e
 
 
+@market_router.post("/api/orders/simulate")
+async def simulate_order(
+    request: PlaceOrderRequest,
+    timeout: float = Query(default=2.0, gt=0, le=10),
+    messaging: Any = Depends(get_messaging),
+) -> Dict[str, Any]:
+    """Project fill price, slippage and fees for an order without placing it.
+
+    Proxies ``trading.orders.simulate`` to the execution service's paper
+    broker; the response carries ``"simulated": true``.
+    """
+    if not messaging:
+        raise HTTPException(status_code=503, detail="Messaging unavailable")
+
+    subject = get_config().messaging.subjects.get(
+        "order_simulate", "trading.orders.simulate"
+    )
+    reply = await request_reply(messaging, subject, request.model_dump(), timeout)
+    if reply is None:
+        raise HTTPException(status_code=503, detail="Execution service did not answer")
+    if not reply.get("simulation"):
+        raise HTTPException(
+            status_code=400,
+            detail=f"Order simulation failed: {reply.get('error', 'unknown error')}",
+        )
+    return reply["simulation"]
+
+
+@market_router.get("/api/executions/recent")
+async def recent_executions(
+    symbol: Optional[str] = None,
+    count: Optional[int] = Query(default=None, ge=1),
+    timeout: float = Query(default=1.0, gt=0, le=10),
+    messaging: Any = Depends(get_messaging),
+) -> Dict[str, Any]:
+    """Latest execution reports from the execution service, oldest first.
+
+    Proxies ``trading.executions.recent``; ``symbol`` and ``count`` narrow
+    the reports returned.  Answers 503 ``warming_up`` until the execution
+    service has seen its first market tick.
+    """
+    if not messaging:
+        raise HTTPException(status_code=503, detail="Messaging unavailable")
+
+    subject = get_config().messaging.subjects.get(
+        "executions_recent", "trading.executions.recent"
+    )
+    request: Dict[str, Any] = {"symbol": symbol, "count": count}
+    reply = await request_reply(messaging, subject, request, timeout)
+    if reply is None:
+        raise HTTPException(status_code=503, detail="Execution service did not answer")
+    if reply.get("warming_up"):
+        raise ServiceWarmingUp("execution")
+    return {"reports": reply.get("reports", [])}
+
+
 @market_router.delete("/api/orders/{order_id}")
 async def cancel_order(order_id: str, exchange = Depends(get_exchange)):
     """Cancel an order via the exchange adapter."""
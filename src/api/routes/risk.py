@@ -1,6 +1,7 @@
 """
 Risk Management API routes — kill switch, limits, alarms.
 
+- GET  /api/risk               — latest RiskState published by the risk service
 - GET  /api/risk/status        — current risk status and limits
 - PUT  /api/risk/limits        — update risk limits
 - POST /api/risk/kill-switch   — emergency: cancel all, flatten, pause agents
@@ -14,10 +15,13 @@ import logging
 from datetime import datetime, timezone
 from typing import Any, Dict, List, Optional
 
-from fastapi import APIRouter, Depends, HTTPException
+from fastapi import APIRouter, Depends, HTTPException, Query, status
 from pydantic import BaseModel
 
+from src.api.middleware.error_handler import ServiceWarmingUp
+from src.config import get_config
 from src.database import DatabaseManager
+from src.messaging import request_reply
 from src.notifications.escalation import AlertEscalator, Severity
 
 logger = logging.getLogger(__name__)
@@ -88,6 +92,38 @@ class AlarmResponse(BaseModel):
 # Endpoints
 # ---------------------------------------------------------------------------
 
+@risk_router.get("/api/risk")
+async def current_risk_state(
+    timeout: float = Query(default=1.0, gt=0, le=10),
+    messaging: Any = Depends(get_messaging),
+) -> Dict[str, Any]:
+    """Return the risk service's latest published ``RiskState``.
+
+    Responds 503 when messaging is down or the risk service does not answer,
+    and 503 ``warming_up`` when it answers but has not published a state yet.
+    """
+    if not messaging:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Messaging unavailable",
+        )
+    config = get_config()
+    subject = config.messaging.subjects.get("risk_query", "risk.query")
+    try:
+        reply = await request_reply(messaging, subject, {}, timeout)
+    except Exception as exc:
+        logger.error("Risk state query failed: %s", exc)
+        reply = None
+    if reply is None:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="No risk state available",
+        )
+    if not reply.get("state"):
+        raise ServiceWarmingUp("risk")
+    return reply["state"]
+
+
 @risk_router.get("/api/risk/status")
 async def risk_status():
     """Get current risk status including kill switch state and limits."""
@@ -2,12 +2,15 @@ import asyncio
 
 # We need logging
 import logging
+import os
+import uuid
 from datetime import datetime, timezone
 from pathlib import Path
-from typing import Any, Dict
+from typing import Any, Dict, Optional
+from urllib.parse import urlsplit, urlunsplit
 
 import yaml
-from fastapi import APIRouter, Depends, HTTPException, Security, status
+from fastapi import APIRouter, Depends, HTTPException, Query, Security, status
 from fastapi.responses import Response
 from fastapi.security import APIKeyHeader
 from prometheus_client import CONTENT_TYPE_LATEST, REGISTRY, Gauge, generate_latest
@@ -21,6 +24,7 @@ from src.config import (
     get_config,
     reload_config,
 )
+from src.messaging import decode_payload
 
 logger = logging.getLogger(__name__)
 
@@ -98,12 +102,127 @@ def get_messaging():
     # Will be overridden
     return None
 
+
+# Services expected to answer health.ping; anything silent is reported as unknown.
+EXPECTED_SERVICES = (
+    "execution",
+    "feed",
+    "risk",
+    "reporter",
+    "replay",
+    "agent-orchestrator",
+)
+
+
+async def _collect_service_health(messaging: Any, timeout: float) -> Dict[str, Any]:
+    """Broadcast a health ping and gather every reply received within ``timeout``."""
+    replies: Dict[str, Any] = {}
+    if not messaging:
+        return replies
+
+    config = get_config()
+    subject = config.messaging.subjects.get("health_ping", "health.ping")
+    inbox = f"{subject}.reply.{uuid.uuid4().hex}"
+
+    async def _on_reply(msg: Any) -> None:
+        try:
+            payload = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        name = payload.get("service") if isinstance(payload, dict) else None
+        if name:
+            replies[name] = payload
+
+    subscription = await messaging.subscribe(inbox, _on_reply)
+    try:
+        await messaging.publish(subject, {"reply_to": inbox})
+        await asyncio.sleep(timeout)
+    finally:
+        if subscription is not None:
+            try:
+                await subscription.unsubscribe()
+            except Exception:
+                logger.debug("Failed to unsubscribe health inbox %s", inbox)
+    return replies
+
 @system_router.get("/api/health", response_model=Dict[str, str])
 @system_router.get("/health", response_model=Dict[str, str])
 async def health() -> Dict[str, str]:
     return {"status": "healthy", "timestamp": datetime.now(timezone.utc).isoformat()}
 
 
+@system_router.get("/api/status")
+async def services_status(
+    timeout: float = Query(default=1.0, gt=0, le=10),
+    messaging: Any = Depends(get_messaging),
+) -> Dict[str, Any]:
+    """Aggregate health across services via a ``health.ping`` broadcast."""
+    try:
+        replies = await _collect_service_health(messaging, timeout)
+    except Exception as exc:
+        logger.error("Service health collection failed: %s", exc)
+        replies = {}
+
+    services: Dict[str, Any] = {
+        name: {"service": name, "status": "unknown"} for name in EXPECTED_SERVICES
+    }
+    services.update(replies)
+    return {
+        "services": services,
+        "timestamp": datetime.now(timezone.utc).isoformat(),
+    }
+
+
+@system_router.post("/api/broker/reset", dependencies=[Depends(get_api_key)])
+async def reset_broker(messaging: Any = Depends(get_messaging)) -> Dict[str, Any]:
+    """Ask the execution service to reset its paper broker to a clean slate."""
+    if not messaging:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Messaging unavailable",
+        )
+
+    config = get_config()
+    subject = config.messaging.subjects.get("broker_control", "broker.control")
+    requested_at = datetime.now(timezone.utc).isoformat()
+    await messaging.publish(subject, {"command": "reset", "timestamp": requested_at})
+    return {"status": "reset_requested", "subject": subject, "timestamp": requested_at}
+
+
+async def _set_symbol_enabled(symbol: str, enabled: bool, messaging: Any) -> Dict[str, Any]:
+    if not messaging:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Messaging unavailable",
+        )
+
+    config = get_config()
+    subject = config.messaging.subjects.get("broker_control", "broker.control")
+    command = "enable_symbol" if enabled else "disable_symbol"
+    requested_at = datetime.now(timezone.utc).isoformat()
+    await messaging.publish(
+        subject, {"command": command, "symbol": symbol, "timestamp": requested_at}
+    )
+    return {
+        "status": f"{command}_requested",
+        "symbol": symbol,
+        "subject": subject,
+        "timestamp": requested_at,
+    }
+
+
+@system_router.post("/api/symbols/{symbol}/disable", dependencies=[Depends(get_api_key)])
+async def disable_symbol(symbol: str, messaging: Any = Depends(get_messaging)) -> Dict[str, Any]:
+    """Stop new orders for ``symbol``; reduce-only orders are still accepted."""
+    return await _set_symbol_enabled(symbol, False, messaging)
+
+
+@system_router.post("/api/symbols/{symbol}/enable", dependencies=[Depends(get_api_key)])
+async def enable_symbol(symbol: str, messaging: Any = Depends(get_messaging)) -> Dict[str, Any]:
+    """Accept new orders for ``symbol`` again."""
+    return await _set_symbol_enabled(symbol, True, messaging)
+
+
 @system_router.get("/api/presets")
 async def get_presets():
     """Return preset strategy configurations."""
@@ -117,6 +236,46 @@ async def metrics() -> Response:
     return Response(generate_latest(), media_type=CONTENT_TYPE_LATEST)
 
 
+# Venue credentials; /api/config reports only whether each is set
+_EXCHANGE_SECRETS = ("api_key", "secret_key", "passphrase")
+
+
+def _redact_url(url: Optional[str]) -> Optional[str]:
+    """``url`` with any embedded password replaced by ``***``."""
+    if not url:
+        return url
+    parts = urlsplit(url)
+    if parts.password is None:
+        return url
+    netloc = parts.netloc.replace(f":{parts.password}@", ":***@", 1)
+    return urlunsplit(parts._replace(netloc=netloc))
+
+
+@system_router.get("/api/config")
+async def effective_config() -> Dict[str, Any]:
+    """The full effective configuration this process is running with.
+
+    Exchange credentials and URL passwords are never returned; booleans say
+    whether credentials and the ops ``API_KEY`` are configured.
+    """
+    config = get_config()
+    body = config.model_dump(
+        mode="json",
+        include=set(type(config).model_fields),
+        exclude={"exchange": set(_EXCHANGE_SECRETS)},
+    )
+    for secret in _EXCHANGE_SECRETS:
+        body["exchange"][f"{secret}_configured"] = bool(getattr(config.exchange, secret))
+    body["api_key_configured"] = bool(os.getenv("API_KEY"))
+    body["database"]["url"] = _redact_url(body["database"].get("url"))
+    body["messaging"]["servers"] = [
+        _redact_url(server) for server in body["messaging"]["servers"]
+    ]
+    for key in ("nats_url", "db_url"):
+        body[key] = _redact_url(body.get(key))
+    return body
+
+
 @system_router.get("/api/mode", response_model=ModeResponse)
 async def get_mode() -> ModeResponse:
     config = get_config()
@@ -14,6 +14,8 @@ from typing import Any, Dict, Optional, Set
 from fastapi import WebSocket, WebSocketDisconnect
 from starlette.websockets import WebSocketState
 
+from src.messaging import decode_payload
+
 logger = logging.getLogger(__name__)
 
 VALID_TOPICS: Set[str] = {"positions", "fills", "alarms", "agents", "market"}
@@ -195,8 +197,8 @@ class WebSocketManager:
     def _make_nats_handler(self, ws_topic: str):
         async def _handler(msg: Any) -> None:
             try:
-                payload = json.loads(msg.data.decode() if isinstance(msg.data, bytes) else msg.data)
-            except (json.JSONDecodeError, AttributeError):
+                payload = decode_payload(msg.data)
+            except (ValueError, AttributeError):
                 payload = str(msg.data)
             await self.broadcast(ws_topic, payload)
         return _handler
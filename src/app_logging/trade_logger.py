@@ -4,8 +4,9 @@ import csv
 import json
 import logging
 from datetime import datetime, timezone
+from decimal import Decimal
 from pathlib import Path
-from typing import Any, Dict
+from typing import Any, Dict, Optional
 
 logger = logging.getLogger(__name__)
 
@@ -15,6 +16,8 @@ class TradeLogger:
     Lightweight CSV logger for completed trades.
 
     Appends rows to a CSV file and writes the header if the file is missing or empty.
+    Numbers are written in plain positional notation, never as ``1e-05``, with
+    ``decimals`` fixed places when given and the shortest exact form otherwise.
     """
 
     HEADERS = [
@@ -37,8 +40,9 @@ class TradeLogger:
         "extra",
     ]
 
-    def __init__(self, csv_path: Path):
+    def __init__(self, csv_path: Path, decimals: Optional[int] = None):
         self.csv_path = Path(csv_path)
+        self.decimals = decimals
         self.csv_path.parent.mkdir(parents=True, exist_ok=True)
 
     def _ensure_header(self) -> None:
@@ -65,12 +69,15 @@ class TradeLogger:
             return ""
         return str(value)
 
-    @staticmethod
-    def _format_float(value: Any, default: Any = "") -> Any:
+    def _format_float(self, value: Any, default: Any = "") -> Any:
         try:
-            return float(value)
+            number = float(value)
         except (TypeError, ValueError):
             return default
+        if self.decimals is not None:
+            return f"{number:.{self.decimals}f}"
+        # repr() is the shortest exact form but switches to exponents
+        return format(Decimal(repr(number)), "f")
 
     @staticmethod
     def _format_extra(value: Any) -> str:
@@ -20,18 +20,21 @@ from __future__ import annotations
 
 import os
 from pathlib import Path
-from typing import Any, Dict, List, Literal, Optional
+from typing import Any, Dict, List, Literal, Optional, Tuple
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
 
 import yaml
 from pydantic import BaseModel, ConfigDict, Field, field_validator, model_validator
 from pydantic_settings import BaseSettings, SettingsConfigDict
 
+from src.messaging import subject_matches
 from src.security.mode_guard import resolve_exchange_credentials
 
 APP_MODE = Literal["live", "paper", "replay", "backtest"]
 PRICE_SOURCE = Literal["live", "bars", "replay"]
 APP_MODE = Literal["live", "paper", "replay", "backtest"]
 PRICE_SOURCE = Literal["live", "bars", "replay"]
+MARK_PRICE_SOURCE = Literal["mid", "last", "index"]
 
 
 def _resolve_required_path(
@@ -83,6 +86,15 @@ def _assert_no_literal_secrets(raw_data: Dict[str, Any]) -> None:
             )
 
 
+def _validate_symbol(value: Optional[str]) -> Optional[str]:
+    if value is None:
+        return None
+    value = value.strip()
+    if not value:
+        raise ValueError("default_symbol must not be empty")
+    return value
+
+
 class StrictModel(BaseModel):
     """Pydantic helper that rejects unknown keys and validates on assignment."""
 
@@ -348,12 +360,32 @@ class CrisisModeConfig(StrictModel):
     consecutive_losses: int = Field(default=3, ge=0)
     volatility_multiplier: float = Field(default=3.0, ge=0)
     position_size_reduction: float = Field(default=0.50, ge=0, le=1)
+    # Minimum time crisis mode holds after a trip, even if conditions recover
+    cooldown_seconds: float = Field(default=900.0, ge=0)
+
+
+class VolTargetConfig(StrictModel):
+    """Target-volatility sizing: factor = min(1, target_vol / realized_vol)."""
+
+    # Annualised volatility the position size factor aims for
+    target_vol: float = Field(default=0.60, gt=0)
+    # Number of market-data returns per symbol in the realized-vol window
+    window: int = Field(default=60, ge=2)
+    min_factor: float = Field(default=0.1, ge=0, le=1)
+    max_factor: float = Field(default=1.0, gt=0, le=1)
+
+    @model_validator(mode="after")
+    def _validate_bounds(self) -> "VolTargetConfig":
+        if self.min_factor > self.max_factor:
+            raise ValueError("min_factor must be less than or equal to max_factor")
+        return self
 
 
 class RiskManagementConfig(StrictModel):
     ladder_entries: LadderConfig = Field(default_factory=LadderConfig)
     stops: StopsConfig = Field(default_factory=StopsConfig)
     crisis_mode: CrisisModeConfig = Field(default_factory=CrisisModeConfig)
+    vol_target: VolTargetConfig = Field(default_factory=VolTargetConfig)
 
 
 class TimeframesConfig(StrictModel):
@@ -378,6 +410,9 @@ class LoggingConfig(StrictModel):
     file: str = "logs/trading.log"
     max_size: str = "10MB"
     backup_count: int = Field(default=5, ge=0)
+    # Fixed decimal places for numbers in the trade-ledger CSV; None writes
+    # the shortest exact form (either way without scientific notation)
+    csv_decimals: Optional[int] = Field(default=None, ge=0, le=18)
 
 
 class DatabaseConfig(StrictModel):
@@ -415,18 +450,39 @@ class DashboardConfig(StrictModel):
 
 class MessagingConfig(StrictModel):
     servers: List[str] = Field(default_factory=lambda: [os.getenv("NATS_URL", "nats://localhost:4222")])
+    # Wire codec for published messages; consumers decode either codec
+    encoding: Literal["json", "msgpack"] = "json"
+    # Consumers drop market data/orders older than this (None = keep all);
+    # ignored in replay and backtest, whose timestamps are historical
+    max_message_age_s: Optional[float] = Field(default=None, gt=0)
+    # Per-symbol market data subject, e.g. "market.data.{symbol}"; consumers
+    # subscribe with "*" in place of the symbol.  None keeps the flat
+    # subjects["market_data"] subject.
+    market_data_subject_template: Optional[str] = None
     subjects: Dict[str, str] = Field(
         default_factory=lambda: {
             "market_data": "market.data",
             "orders": "trading.orders",
+            "order_simulate": "trading.orders.simulate",
+            "order_amend": "trading.amend",
+            # Order accepted/rejected events; empty keeps them on "executions"
+            "order_acks": "",
             "positions": "trading.positions",
+            "positions_reconcile": "trading.positions.reconcile",
+            "run_started": "run.started",
             "executions": "trading.executions",
+            "executions_recent": "trading.executions.recent",
             "executions_shadow": "trading.executions.shadow",
             "risk": "risk.management",
+            "risk_query": "risk.query",
             "performance": "performance.metrics",
             "config_reload": "config.reload",
             "replay_control": "replay.control",
+            "replay_status": "replay.status",
             "reports": "reports.performance",
+            "health_ping": "health.ping",
+            "broker_control": "broker.control",
+            "paper_config_patch": "paper.config.patch",
         }
     )
 
@@ -441,16 +497,50 @@ class MessagingConfig(StrictModel):
             raise ValueError("At least one NATS server must be configured.")
         return value
 
+    @field_validator("market_data_subject_template")
+    @classmethod
+    def _validate_subject_template(cls, value: Optional[str]) -> Optional[str]:
+        if value is None:
+            return value
+        tokens = value.split(".")
+        if tokens.count("{symbol}") != 1 or any(
+            not token or token in ("*", ">") for token in tokens
+        ):
+            raise ValueError(
+                "market_data_subject_template needs exactly one '{symbol}' token "
+                "and no empty or wildcard tokens, e.g. 'market.data.{symbol}'"
+            )
+        return value
+
+    def market_data_subject(self, symbol: str) -> str:
+        """Subject a publisher uses for ``symbol``'s market data."""
+        if self.market_data_subject_template is None:
+            return self.subjects["market_data"]
+        return self.market_data_subject_template.replace("{symbol}", symbol)
+
+    def market_data_subscription(self) -> str:
+        """Subject, possibly wildcarded, that market data consumers subscribe to."""
+        if self.market_data_subject_template is None:
+            return self.subjects["market_data"]
+        return self.market_data_subject_template.replace("{symbol}", "*")
+
 
 class LatencyConfig(StrictModel):
     mean: float = Field(default=120.0, ge=0)
     p95: float = Field(default=300.0, ge=0)
     jitter: float = Field(default=25.0, ge=0)
+    # Bounds applied to each sampled latency (max None = unbounded tail)
+    min_ms: float = Field(default=0.0, ge=0)
+    max_ms: Optional[float] = Field(default=None, gt=0)
 
     @model_validator(mode="after")
     def _validate_percentiles(self) -> "LatencyConfig":
         if self.p95 < self.mean:
             raise ValueError("latency p95 must be greater than or equal to the mean")
+        if self.mean < self.min_ms or (
+            self.max_ms is not None and self.mean > self.max_ms
+        ):
+            raise ValueError("latency must satisfy min_ms <= mean <= max_ms")
         return self
 
 
@@ -459,6 +549,14 @@ class PartialFillConfig(StrictModel):
     min_slice_pct: float = Field(default=0.15, ge=0, le=1)
     max_slices: int = Field(default=4, ge=1)
     randomize: bool = True
+    # Delay of slice i as a multiple of its latency draw: "constant" (1, every
+    # slice lands after its own draw), "linear" (1 + i * slice_ramp) or
+    # "exponential" (slice_backoff ** i)
+    slice_timing: Literal["constant", "linear", "exponential"] = "constant"
+    slice_ramp: float = Field(default=0.5, ge=0)
+    slice_backoff: float = Field(default=2.0, ge=1)
+    # Slices below this notional are merged into their neighbours (None = off)
+    min_fill_notional: Optional[float] = Field(default=None, gt=0)
 
     @model_validator(mode="after")
     def _validate_bounds(self) -> "PartialFillConfig":
@@ -466,27 +564,234 @@ class PartialFillConfig(StrictModel):
             raise ValueError("min_slice_pct must be > 0 when partial fills are enabled")
         return self
 
+    def slice_delay_factor(self, index: int) -> float:
+        """Multiplier on the latency draw of the ``index``-th (0-based) slice."""
+        if self.slice_timing == "linear":
+            return 1.0 + index * self.slice_ramp
+        if self.slice_timing == "exponential":
+            return self.slice_backoff**index
+        return 1.0
+
+
+class EstimatorWarmupConfig(StrictModel):
+    """Cold-start seeding for the broker's order-flow imbalance estimator."""
+
+    # OFI a symbol starts from on its first tick
+    ofi_initial: float = 0.0
+    # When > 0, OFI over a symbol's first N ticks is the steady state of their
+    # average flow, and the estimator continues from that seed afterwards
+    seed_from_first_n: int = Field(default=0, ge=0)
+    # Trade flow taken from a symbol's first tick, which has no decay history:
+    # "raw" (its full last_size), "zero" (ignored) or "normalized" (weighted
+    # by 1 - decay, like the newest sample of a normalised moving average)
+    first_tick_flow: Literal["raw", "zero", "normalized"] = "raw"
+
+
+class ReportJournalConfig(StrictModel):
+    """Parquet journal of execution reports, one ``<run_id>.parquet`` per run."""
+
+    directory: str = "data/journal"
+    # Buffered reports are written out this often, and on shutdown
+    flush_interval_s: float = Field(default=5.0, gt=0)
+
+
+class SymbolOverrides(StrictModel):
+    """Per-symbol execution parameters; unset fields fall back to PaperConfig."""
+
+    latency_mean_ms: Optional[float] = Field(default=None, ge=0)
+    latency_p95_ms: Optional[float] = Field(default=None, ge=0)
+    slippage_bps: Optional[float] = Field(default=None, ge=0)
+    max_slippage_bps: Optional[float] = Field(default=None, ge=0)
+    # Decimals for reported prices/amounts and quantities (None = unrounded)
+    price_precision: Optional[int] = Field(default=None, ge=0)
+    qty_precision: Optional[int] = Field(default=None, ge=0)
+
+
+class InitialPosition(StrictModel):
+    """Position the paper broker starts a run holding."""
+
+    size: float  # positive = long, negative = short
+    avg_price: float = Field(gt=0)
+
+    @field_validator("size")
+    @classmethod
+    def _validate_size(cls, value: float) -> float:
+        if value == 0:
+            raise ValueError("initial position size must be non-zero")
+        return value
+
 
 class PaperConfig(StrictModel):
     fee_bps: float = Field(default=7.0, ge=-1000, le=1000)
     maker_rebate_bps: float = Field(default=-1.0, ge=-1000, le=1000)
+    # Makers only earn the rebate when the spread at fill time exceeds this
+    min_maker_spread_bps: Optional[float] = Field(default=None, ge=0)
+    # Testing knob: class every limit-order fill as "maker" or "taker" for
+    # fees and maker/taker stats, whatever its price; "auto" classifies
+    # crossing limits as taker and rested fills as maker
+    force_liquidity: Literal["auto", "maker", "taker"] = "auto"
+    # Minimum charge per fill with a positive fee; rebates and zero fees are untouched
+    min_commission: float = Field(default=0.0, ge=0)
     funding_enabled: bool = True
+    # Size a fill closes, which prepaid an hour of funding when opened:
+    # "skip" charges or refunds nothing, "prorate" refunds the part of the
+    # hour it was not held for, so closing early is not charged in full
+    funding_proration: Literal["skip", "prorate"] = "skip"
     slippage_bps: float = Field(default=3.0, ge=0)
     max_slippage_bps: float = Field(default=10.0, ge=0)
+    # Slippage beyond max_slippage_bps: "clamp" it, or "reject" the order
+    # with max_slippage_exceeded like an exchange price-protection band
+    slippage_overflow: Literal["clamp", "reject"] = "clamp"
+    # Reduce-only orders larger than the open position: "clamp" them to close
+    # it exactly, or "reject" them with reduce_only_exceeds_position
+    reduce_only_overflow: Literal["clamp", "reject"] = "clamp"
+    # Reject orders that would close a position and open the opposite side in
+    # one go (would_flip_position); False books the close and opens the rest
+    no_flip: bool = False
     spread_slippage_coeff: float = Field(default=0.5, ge=0)
     ofi_slippage_coeff: float = Field(default=0.3, ge=0)
+    # Side multipliers on the modelled slippage, for directional liquidity
+    buy_slippage_coeff: float = Field(default=1.0, ge=0)
+    sell_slippage_coeff: float = Field(default=1.0, ge=0)
+    # Price taker fills are slipped from: the touch (ask/bid) or the mid
+    market_ref_price: Literal["touch", "mid"] = "touch"
     latency_ms: LatencyConfig = Field(default_factory=LatencyConfig)
+    # Testing/calibration knob: every latency is exactly the (per-symbol)
+    # mean, with no Gaussian draw from the seeded RNG
+    deterministic_latency: bool = False
     partial_fill: PartialFillConfig = Field(default_factory=PartialFillConfig)
+    estimator_warmup: EstimatorWarmupConfig = Field(
+        default_factory=EstimatorWarmupConfig
+    )
+    # Concurrent workers applying scheduled fills (bounds task fan-out)
+    fill_workers: int = Field(default=8, ge=1)
+    # Probability a market order fills only partly and the rest is rejected
+    partial_reject_rate: float = Field(default=0.0, ge=0, le=1)
+    # live/replay trust incoming top-of-book; bars rebuild it from the bar range
     price_source: PRICE_SOURCE = "live"
+    bar_spread_range_pct: float = Field(default=0.5, ge=0, le=1)
+    # Book synthesised around last_price when a tick has no bid/ask: "off"
+    # prices at last with zero spread, "fixed" uses synthetic_spread_bps,
+    # "vol" scales it by recent last-price moves (floored at the fixed bps)
+    synthetic_spread: Literal["off", "fixed", "vol"] = "off"
+    synthetic_spread_bps: float = Field(default=5.0, ge=0)
+    synthetic_spread_vol_mult: float = Field(default=1.0, ge=0)
+    mark_price_source: MARK_PRICE_SOURCE = "mid"
+    # Price a resting stop watches for its trigger (falls back to mid without trades)
+    stop_trigger_source: Literal["last", "mid"] = "last"
     max_leverage: float = Field(default=5.0, ge=1.0)
     initial_margin_pct: float = Field(default=0.1, ge=0, le=1)
     maintenance_margin_pct: float = Field(default=0.005, ge=0, le=1)
-    seed: int = Field(default=1337, ge=0)
+    # RNG seed; None derives one from the clock, reported as broker.seed
+    seed: Optional[int] = Field(default=1337, ge=0)
+    # Fat-finger guard on a single order's quantity (None = unbounded)
+    max_order_qty: Optional[float] = Field(default=None, gt=0)
+    max_order_qty_by_symbol: Dict[str, float] = Field(default_factory=dict)
+    # Cap on accepted-but-unfilled orders; new orders beyond it are rejected
+    max_in_flight_orders: Optional[int] = Field(default=None, gt=0)
+    # Caps on resting (not-yet-filled) limit orders, per symbol and in total
+    max_open_orders_per_symbol: Optional[int] = Field(default=None, gt=0)
+    max_open_orders: Optional[int] = Field(default=None, gt=0)
+    # Reference-price protection: limit orders priced further than this from
+    # mid are rejected with price_out_of_band (None = off)
+    price_band_bps: Optional[float] = Field(default=None, gt=0)
+    # Scale inbound order quantities by the risk service's position_size_factor;
+    # reduce-only orders are exempt and a zero factor rejects with
+    # size_factor_zero
+    respect_size_factor: bool = False
+    # Reject orders for a symbol whose market data is older than this (0 = off);
+    # wall-clock age, so never applied in replay or backtest mode
+    market_data_stale_after_s: float = Field(default=0.0, ge=0)
+    # A tick more than gap_threshold_s (market time) after the previous one
+    # opens a post_gap_cooldown_s window in which liquidity-taking orders are
+    # rejected ("reject") or held for a tick past the window ("wait")
+    gap_threshold_s: Optional[float] = Field(default=None, gt=0)
+    post_gap_cooldown_s: float = Field(default=5.0, ge=0)
+    post_gap_policy: Literal["reject", "wait"] = "reject"
+    # Crossed/locked books (best_bid >= best_ask): "reject" orders until the
+    # book uncrosses, or "last_price" to collapse the book onto the last trade
+    crossed_book_policy: Literal["reject", "last_price"] = "reject"
+    # Orders taking from an empty (size 0) side of the book: "fill" anyway,
+    # "reject" with no_liquidity, or "delay" until that side shows size
+    zero_liquidity_policy: Literal["fill", "reject", "delay"] = "fill"
+    # "sync": orders carrying reply_to are answered with their first fill
+    # (waits out the simulated latency); "async": ack now, fills on executions
+    ack_mode: Literal["async", "sync"] = "async"
+    sync_ack_timeout_s: float = Field(default=5.0, gt=0)
+    # Fill/trade timestamps: "market" = submission snapshot time + simulated
+    # latency, "wall" = clock; "auto" uses market time in replay/backtest
+    fill_timestamp_source: Literal["auto", "wall", "market"] = "auto"
+    # Fills kept individually for position reconciliation; older ones are
+    # folded into a per-symbol baseline
+    fill_journal_size: int = Field(default=10_000, ge=1)
+    # Execution reports kept for trading.executions.recent queries
+    recent_reports_size: int = Field(default=500, ge=1)
+    # Merge consecutive fills of one order within coalesce_window_ms of
+    # market-data time into one report (VWAP price, summed fees and PnL)
+    coalesce_fills: bool = False
+    coalesce_window_ms: float = Field(default=50.0, gt=0)
+    # Write every execution report to Parquet for post-run analysis (None = off)
+    report_journal: Optional[ReportJournalConfig] = None
+    # Symbols given their own label on latency/slippage histograms; the rest
+    # share "other" (None = trading.symbols)
+    metric_symbols: Optional[List[str]] = None
+    per_symbol: Dict[str, SymbolOverrides] = Field(default_factory=dict)
+    # Book inherited at the start of a run (and after reset); PnL is measured
+    # from each seeded avg_price.  Starting cash is trading.initial_capital.
+    initial_positions: Dict[str, InitialPosition] = Field(default_factory=dict)
+    # Feed/strategy symbol -> canonical broker symbol (e.g. XBTUSD -> BTCUSDT)
+    symbol_aliases: Dict[str, str] = Field(default_factory=dict)
 
     @model_validator(mode="after")
     def _validate_slippage(self) -> "PaperConfig":
         if self.max_slippage_bps < self.slippage_bps:
             raise ValueError("max_slippage_bps must be >= slippage_bps")
+        for symbol, limit in self.max_order_qty_by_symbol.items():
+            if limit <= 0:
+                raise ValueError(f"max_order_qty_by_symbol[{symbol}] must be > 0")
+        if any(not symbol.strip() for symbol in self.initial_positions):
+            raise ValueError("initial_positions symbols must not be empty")
+        for symbol, override in self.per_symbol.items():
+            mean = (
+                override.latency_mean_ms
+                if override.latency_mean_ms is not None
+                else self.latency_ms.mean
+            )
+            p95 = (
+                override.latency_p95_ms
+                if override.latency_p95_ms is not None
+                else self.latency_ms.p95
+            )
+            if p95 < mean:
+                raise ValueError(
+                    f"per_symbol[{symbol}] latency p95 must be greater than or equal to the mean"
+                )
+            max_ms = self.latency_ms.max_ms
+            if mean < self.latency_ms.min_ms or (max_ms is not None and mean > max_ms):
+                raise ValueError(
+                    f"per_symbol[{symbol}] latency mean is outside min_ms/max_ms"
+                )
+            slippage = (
+                override.slippage_bps
+                if override.slippage_bps is not None
+                else self.slippage_bps
+            )
+            max_slippage = (
+                override.max_slippage_bps
+                if override.max_slippage_bps is not None
+                else self.max_slippage_bps
+            )
+            if max_slippage < slippage:
+                raise ValueError(
+                    f"per_symbol[{symbol}] max_slippage_bps must be >= slippage_bps"
+                )
+        for alias, canonical in self.symbol_aliases.items():
+            if not alias.strip() or not canonical.strip():
+                raise ValueError("symbol_aliases entries must not be empty")
+            if canonical in self.symbol_aliases:
+                raise ValueError(
+                    f"symbol_aliases[{alias}] points at another alias ({canonical})"
+                )
         if self.initial_margin_pct < self.maintenance_margin_pct:
             raise ValueError(
                 "initial_margin_pct must be greater than or equal to maintenance_margin_pct"
@@ -494,12 +799,177 @@ class PaperConfig(StrictModel):
         return self
 
 
+class FeedConfig(StrictModel):
+    # Symbol published by single-symbol feeds (falls back to trading.symbols[0])
+    default_symbol: Optional[str] = None
+    # Seconds without a successful publish before the watchdog flags a stall
+    stall_timeout_seconds: float = Field(default=30.0, gt=0)
+    # Tick-to-trade delay: snapshots keep the time they were built but are
+    # published this much later, so consumers see data stale by that much
+    publish_delay_ms: float = Field(default=0.0, ge=0)
+    # In replay mode the replay service owns market_data: the feed either
+    # stays idle or publishes live data on a distinct subject.
+    replay_mode: Literal["idle", "separate_subject"] = "idle"
+    replay_subject: str = "market.data.feed"
+
+    @field_validator("default_symbol")
+    @classmethod
+    def _validate_default_symbol(cls, value: Optional[str]) -> Optional[str]:
+        return _validate_symbol(value)
+
+
+class SessionFilterConfig(StrictModel):
+    """Trading hours a replay keeps; records outside them are dropped on load."""
+
+    timezone: str = "UTC"
+    # "HH:MM-HH:MM" local-time windows, open inclusive and close exclusive; a
+    # window whose close is before its open runs past midnight
+    windows: List[str] = Field(default_factory=lambda: ["00:00-24:00"], min_length=1)
+    # Weekdays kept, 0 = Monday, judged on each record's local date (None
+    # keeps every day)
+    weekdays: Optional[List[int]] = None
+
+    @field_validator("timezone")
+    @classmethod
+    def _validate_timezone(cls, value: str) -> str:
+        try:
+            ZoneInfo(value)
+        except (ZoneInfoNotFoundError, ValueError) as exc:
+            raise ValueError(f"Unknown session timezone: {value}") from exc
+        return value
+
+    @field_validator("windows")
+    @classmethod
+    def _validate_windows(cls, value: List[str]) -> List[str]:
+        for window in value:
+            cls.parse_window(window)
+        return value
+
+    @field_validator("weekdays")
+    @classmethod
+    def _validate_weekdays(cls, value: Optional[List[int]]) -> Optional[List[int]]:
+        if value is not None and any(day < 0 or day > 6 for day in value):
+            raise ValueError("session weekdays must be between 0 (Monday) and 6")
+        return value
+
+    @staticmethod
+    def parse_window(window: str) -> Tuple[int, int]:
+        """``"HH:MM-HH:MM"`` as (open, close) minutes after local midnight."""
+        bounds = []
+        for part in window.split("-"):
+            hours, _, minutes = part.strip().partition(":")
+            if not (hours.isdigit() and minutes.isdigit()):
+                break
+            value = int(hours) * 60 + int(minutes)
+            if int(minutes) >= 60 or value > 24 * 60:
+                break
+            bounds.append(value)
+        if len(bounds) != 2 or window.count("-") != 1:
+            raise ValueError(
+                f"Invalid session window {window!r}, expected HH:MM-HH:MM"
+            )
+        if bounds[0] == bounds[1]:
+            raise ValueError(f"Session window {window!r} is empty")
+        return bounds[0], bounds[1]
+
+
+class ReportingConfig(StrictModel):
+    """Currency the reporter converts per-symbol PnL into."""
+
+    # Common reporting currency (None = no conversion, every symbol's PnL is
+    # taken to be in the same currency)
+    currency: Optional[str] = None
+    # Quote currency per symbol; unlisted symbols are quoted in ``currency``
+    quote_currencies: Dict[str, str] = Field(default_factory=dict)
+    # Units of ``currency`` per unit of each quote currency
+    fx_rates: Dict[str, float] = Field(default_factory=dict)
+    # Trailing windows (name -> seconds of fill time) reported next to the
+    # cumulative figures
+    windows_s: Dict[str, float] = Field(
+        default_factory=lambda: {"1h": 3600.0, "24h": 86400.0}
+    )
+
+    @model_validator(mode="after")
+    def _validate_rates(self) -> "ReportingConfig":
+        for name, seconds in self.windows_s.items():
+            if seconds <= 0:
+                raise ValueError(f"windows_s[{name}] must be positive")
+        for quote, rate in self.fx_rates.items():
+            if rate <= 0:
+                raise ValueError(f"fx_rates[{quote}] must be positive")
+        if self.currency is None:
+            return self
+        missing = sorted(
+            {
+                quote
+                for quote in self.quote_currencies.values()
+                if quote != self.currency and quote not in self.fx_rates
+            }
+        )
+        if missing:
+            raise ValueError(f"fx_rates missing for quote currencies: {missing}")
+        return self
+
+    def quote_currency(self, symbol: str) -> Optional[str]:
+        return self.quote_currencies.get(symbol, self.currency)
+
+    def fx_rate(self, symbol: str) -> float:
+        """Multiplier converting ``symbol``'s PnL into the reporting currency."""
+        quote = self.quote_currency(symbol)
+        if quote == self.currency:
+            return 1.0
+        return self.fx_rates[quote]
+
+
 class ReplayConfig(StrictModel):
     source: str = "parquet://bars/"
     speed: str = "10x"
     start: str = "2023-01-01"
     end: str = "2024-12-31"
     seed: int = Field(default=1337, ge=0)
+    # Integrity pass over the loaded dataset (duplicates, bad prices, gaps)
+    validate_data: bool = False
+    reject_invalid: bool = False
+    max_gap_multiplier: float = Field(default=5.0, ge=1.0)
+    # Replayed gaps longer than this (s) are counted and logged as data gaps;
+    # None derives it as max_gap_multiplier x the median record spacing
+    gap_alert_seconds: Optional[float] = Field(default=None, gt=0)
+    # Keep zero-volume bars at zero top-of-book size instead of flooring
+    # sizes at 1 (pair with paper.zero_liquidity_policy)
+    preserve_zero_sizes: bool = False
+    # Trading-hours filter for equity-like instruments (None replays all records)
+    session: Optional[SessionFilterConfig] = None
+    # Symbol used when the source has no symbol column (falls back to trading.symbols[0])
+    default_symbol: Optional[str] = None
+    # Opt-in checkpointing so long replays can resume after a crash
+    resume: bool = False
+    checkpoint_path: str = "data/replay_checkpoint.json"
+    checkpoint_every: int = Field(default=100, ge=1)
+    # Seeded +/- jitter on each record's emission time (data timestamps untouched)
+    timestamp_jitter_ms: float = Field(default=0.0, ge=0)
+    # Optional funding schedule (csv/parquet: timestamp, funding_rate[, symbol])
+    funding_source: Optional[str] = None
+    # Parquet files with more rows than this are read in batches (0 = never)
+    stream_batch_rows: int = Field(default=250_000, ge=0)
+    # Threads for directory loads and batched parquet decoding
+    read_workers: int = Field(default=4, ge=1)
+    # Record-count slice applied after sorting: skip warmup, cap the length
+    start_index: int = Field(default=0, ge=0)
+    max_records: Optional[int] = Field(default=None, gt=0)
+    # Drop all but the last record per (symbol, timestamp), e.g. merged sources
+    dedupe_timestamps: bool = False
+    # Keep every Nth record after the slice, for quick smoke runs (1 = all)
+    downsample: int = Field(default=1, ge=1)
+    # Run budget for CI: stop after this much wall-clock time or this many
+    # published records (across passes), whichever comes first, and publish
+    # a "completed" event on subjects["replay_status"]
+    max_duration_s: Optional[float] = Field(default=None, gt=0)
+    record_budget: Optional[int] = Field(default=None, gt=0)
+
+    @field_validator("default_symbol")
+    @classmethod
+    def _validate_default_symbol(cls, value: Optional[str]) -> Optional[str]:
+        return _validate_symbol(value)
 
     @field_validator("speed")
     @classmethod
@@ -639,7 +1109,9 @@ class TradingBotConfig(BaseSettings):
     messaging: MessagingConfig = Field(default_factory=MessagingConfig)
     paper: PaperConfig = Field(default_factory=PaperConfig)
     replay: ReplayConfig = Field(default_factory=ReplayConfig)
+    feed: FeedConfig = Field(default_factory=FeedConfig)
     perps: PerpsConfig = Field(default_factory=PerpsConfig)
+    reporting: ReportingConfig = Field(default_factory=ReportingConfig)
     shadow_paper: bool = False
     config_paths: ConfigPaths
 
@@ -654,7 +1126,19 @@ class TradingBotConfig(BaseSettings):
     risk_port: Optional[int] = None
     reporter_port: Optional[int] = None
     replay_port: Optional[int] = None
+    orchestrator_port: Optional[int] = None
     ops_port: Optional[int] = None
+    # Bind addresses for ``python -m src.services.<name>`` (default 0.0.0.0).
+    exec_host: Optional[str] = None
+    feed_host: Optional[str] = None
+    risk_host: Optional[str] = None
+    reporter_host: Optional[str] = None
+    replay_host: Optional[str] = None
+    orchestrator_host: Optional[str] = None
+    # Opt-in profiling endpoints on a dedicated admin port (ENABLE_PPROF=1).
+    enable_pprof: bool = False
+    pprof_host: str = "127.0.0.1"
+    pprof_port: int = 6060
     log_level: Optional[str] = None
 
     @model_validator(mode="after")
@@ -674,6 +1158,20 @@ class TradingBotConfig(BaseSettings):
                 raise ValueError("Live mode cannot use testnet perps endpoints.")
         return self
 
+    @model_validator(mode="after")
+    def _validate_market_data_routing(self) -> "TradingBotConfig":
+        """Keep the feed's replay-time subject out of the consumers' wildcard."""
+
+        if self.feed.replay_mode == "separate_subject" and subject_matches(
+            self.messaging.market_data_subscription(), self.feed.replay_subject
+        ):
+            raise ValueError(
+                f"feed.replay_subject {self.feed.replay_subject!r} matches the market "
+                f"data subscription {self.messaging.market_data_subscription()!r}; "
+                "choose a subject outside it."
+            )
+        return self
+
 
 _CONFIG: Optional[TradingBotConfig] = None
 
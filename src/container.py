@@ -42,7 +42,10 @@ class Container:
         await self.database.initialize()
 
         # Messaging
-        messaging_config = {"servers": self.config.messaging.servers}
+        messaging_config = {
+            "servers": self.config.messaging.servers,
+            "encoding": self.config.messaging.encoding,
+        }
         try:
             self.messaging = MessagingClient(messaging_config)
             await self.messaging.connect()
@@ -128,6 +131,9 @@ class Container:
         if self.exchange:
             await self.exchange.close()
 
+        if self.paper_broker:
+            await self.paper_broker.close()
+
         if self.messaging:
             await self.messaging.close()  # assuming close method exists or similar
 
@@ -53,6 +53,14 @@ class Order(DBModel):
     created_at: Optional[datetime] = None
     updated_at: Optional[datetime] = None
     is_shadow: bool = False
+    # Market-data time after which a resting order is cancelled (not persisted)
+    expires_at: Optional[datetime] = None
+    # Limit price requested relative to mid; ``price`` holds the resolved value
+    price_offset_bps: Optional[float] = None
+    # Reports emitted so far; numbers each report's ``fill_id`` (not persisted)
+    report_seq: int = 0
+    # Mid price when the broker accepted the order, for shortfall (not persisted)
+    arrival_mid: Optional[float] = None
 
     @field_validator("client_id", "run_id")
     @classmethod
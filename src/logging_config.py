@@ -16,6 +16,9 @@ from .config import TradingBotConfig
 
 # Context variable for request correlation ID
 correlation_id_var: ContextVar[str] = ContextVar("correlation_id", default="")
+# Service handling the current task, set per app so co-hosted services log
+# under their own names; unset falls back to the name given to setup_logging
+service_name_var: ContextVar[str] = ContextVar("service_name", default="")
 
 
 class _CorrelationJsonFormatter(JsonFormatter):
@@ -27,7 +30,7 @@ class _CorrelationJsonFormatter(JsonFormatter):
 
     def add_fields(self, log_record, record, message_dict):
         super().add_fields(log_record, record, message_dict)
-        log_record["service"] = self._service_name
+        log_record["service"] = service_name_var.get("") or self._service_name
         log_record["level"] = record.levelname
         req_id = correlation_id_var.get("")
         if req_id:
@@ -9,6 +9,7 @@ import asyncio
 import importlib
 import json
 import logging
+import uuid
 from typing import TYPE_CHECKING, Any, Awaitable, Callable, Dict, List, Optional
 
 if TYPE_CHECKING:  # pragma: no cover - typing aides
@@ -34,8 +35,100 @@ except ImportError:  # pragma: no cover - optional dependency
         "NATS client not available. Messaging will be disabled unless memory mode is used."
     )
 
+try:
+    import msgpack
+except ImportError:  # pragma: no cover - optional dependency
+    msgpack = None
+
 logger = logging.getLogger(__name__)
 
+WIRE_ENCODINGS = ("json", "msgpack")
+
+
+def encode_payload(message: Any, encoding: str = "json") -> bytes:
+    """Serialise ``message`` for the wire with the configured codec."""
+    if encoding == "msgpack":
+        if msgpack is None:
+            raise RuntimeError("msgpack wire encoding requires the msgpack package")
+        return msgpack.packb(message, use_bin_type=True)
+    return json.dumps(message).encode("utf-8")
+
+
+def decode_payload(data: bytes | str) -> Any:
+    """Deserialise a message body, detecting JSON or msgpack from its first byte.
+
+    JSON documents start with an ASCII byte while msgpack maps and arrays
+    start at 0x80 or above, so consumers accept either codec and producers
+    can switch independently.  Malformed bodies raise ``ValueError``.
+    """
+    if isinstance(data, str):
+        return json.loads(data)
+    if not data or data[0] < 0x80:
+        return json.loads(data.decode("utf-8"))
+    if msgpack is None:
+        raise ValueError("Received a msgpack payload but msgpack is not installed")
+    try:
+        return msgpack.unpackb(data, raw=False)
+    except Exception as exc:
+        raise ValueError(f"Invalid msgpack payload: {exc}") from exc
+
+
+def _wire_encoding(config: Optional[Dict[str, Any]]) -> str:
+    encoding = (config or {}).get("encoding") or "json"
+    if encoding not in WIRE_ENCODINGS:
+        logger.warning("Unsupported messaging encoding %r; using json", encoding)
+        return "json"
+    if encoding == "msgpack" and msgpack is None:
+        raise RuntimeError("msgpack wire encoding requires the msgpack package")
+    return encoding
+
+
+def subject_matches(pattern: str, subject: str) -> bool:
+    """NATS subject matching: ``*`` matches one token, a trailing ``>`` the rest."""
+    pattern_tokens = pattern.split(".")
+    subject_tokens = subject.split(".")
+    for index, token in enumerate(pattern_tokens):
+        if token == ">":
+            return len(subject_tokens) > index
+        if index >= len(subject_tokens):
+            return False
+        if token != "*" and token != subject_tokens[index]:
+            return False
+    return len(pattern_tokens) == len(subject_tokens)
+
+
+async def request_reply(
+    messaging: Any, subject: str, payload: Dict[str, Any], timeout: float
+) -> Optional[Dict[str, Any]]:
+    """Publish ``payload`` with a fresh ``reply_to`` inbox; return the first reply.
+
+    Works over both NATS and the memory bus, unlike ``MessagingClient.request``.
+    Returns ``None`` when nothing answers within ``timeout`` seconds.
+    """
+    inbox = f"{subject}.reply.{uuid.uuid4().hex}"
+    reply: asyncio.Future = asyncio.get_running_loop().create_future()
+
+    async def _on_reply(msg: Any) -> None:
+        try:
+            body = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        if isinstance(body, dict) and not reply.done():
+            reply.set_result(body)
+
+    subscription = await messaging.subscribe(inbox, _on_reply)
+    try:
+        await messaging.publish(subject, {**payload, "reply_to": inbox})
+        return await asyncio.wait_for(reply, timeout)
+    except asyncio.TimeoutError:
+        return None
+    finally:
+        if subscription is not None:
+            try:
+                await subscription.unsubscribe()
+            except Exception:
+                logger.debug("Failed to unsubscribe reply inbox %s", inbox)
+
 
 # -------------------------------------------------------------------------
 # Memory Messaging Client (Monolith Mode)
@@ -49,6 +142,7 @@ class MemoryMessagingClient:
         self.subscribers: Dict[str, List[Callable]] = {}
         self.connected = False
         self._loop = None
+        self.encoding = _wire_encoding(config)
 
     async def connect(self, timeout: float = 1.0):
         self.connected = True
@@ -71,11 +165,17 @@ class MemoryMessagingClient:
             logger.warning("Attempted to publish to closed memory bus")
             return
 
-        if subject not in self.subscribers:
+        callbacks = [
+            callback
+            for pattern, subscribed in self.subscribers.items()
+            if subject_matches(pattern, subject)
+            for callback in subscribed
+        ]
+        if not callbacks:
             return
 
         # Create a mock NATS message object
-        data_bytes = json.dumps(message).encode("utf-8")
+        data_bytes = encode_payload(message, self.encoding)
 
         class MockMsg:
             def __init__(self, data, subj):
@@ -86,7 +186,7 @@ class MemoryMessagingClient:
         msg = MockMsg(data_bytes, subject)
 
         # Dispatch
-        for callback in self.subscribers[subject]:
+        for callback in callbacks:
             if asyncio.iscoroutinefunction(callback):
                 asyncio.create_task(callback(msg))
             else:
@@ -173,6 +273,9 @@ class MessagingClient:
         self._max_backoff = float(config.get("max_backoff", 5.0))
         self._reconnect_time_wait = float(config.get("reconnect_time_wait", 1.0))
         self._connect_timeout = float(config.get("connect_timeout", 2.0))
+        self._drain_timeout = float(config.get("drain_timeout", 5.0))
+        self.encoding = _wire_encoding(config)
+        logger.info("Messaging wire encoding: %s", self.encoding)
 
     def _is_nc_connected(self) -> bool:
         if self._is_memory:
@@ -366,15 +469,19 @@ class MessagingClient:
             return
 
         try:
-            for sub in list(self.subscribers.values()):
-                try:
-                    await sub.unsubscribe()
-                except Exception as exc:
-                    logger.debug("Failed to unsubscribe from %s: %s", sub.subject, exc)
-
             if self.nc:
-                await self.nc.drain()
-                await self.nc.close()
+                # Drain lets in-flight callbacks finish and flushes buffered
+                # publishes before the connection closes; subscriptions are
+                # drained as part of it rather than unsubscribed up front.
+                try:
+                    await asyncio.wait_for(self.nc.drain(), timeout=self._drain_timeout)
+                    logger.info("NATS connection drained")
+                except asyncio.TimeoutError:
+                    logger.warning(
+                        "NATS drain timed out after %.1fs; closing", self._drain_timeout
+                    )
+                if not getattr(self.nc, "is_closed", False):
+                    await self.nc.close()
                 self.connected = False
                 logger.info("NATS connection closed")
         except Exception as exc:
@@ -399,7 +506,7 @@ class MessagingClient:
             return
 
         try:
-            payload = json.dumps(message).encode()
+            payload = encode_payload(message, self.encoding)
         except (TypeError, ValueError) as exc:
             logger.error("Failed to serialise message for %s: %s", subject, exc)
             return
@@ -504,7 +611,7 @@ class MessagingClient:
             return None
 
         try:
-            payload = json.dumps(message).encode()
+            payload = encode_payload(message, self.encoding)
         except (TypeError, ValueError) as exc:
             logger.error("Failed to serialise request payload for %s: %s", subject, exc)
             return None
@@ -528,8 +635,8 @@ class MessagingClient:
             try:
                 response = await self.nc.request(subject, payload, timeout=timeout)
                 try:
-                    return json.loads(response.data)
-                except json.JSONDecodeError as exc:
+                    return decode_payload(response.data)
+                except ValueError as exc:
                     logger.error("Failed to decode response from %s: %s", subject, exc)
                     return None
             except asyncio.TimeoutError:
@@ -1,3 +1,6 @@
+import os
+import time
+from typing import Collection
 
 from prometheus_client import (
     CONTENT_TYPE_LATEST,
@@ -14,6 +17,46 @@ AVERAGE_SLIPPAGE_BPS = Gauge(
     'Average slippage in basis points', 
     ['mode', 'symbol']
 )
+IMPLEMENTATION_SHORTFALL_BPS = Gauge(
+    'paper_implementation_shortfall_bps',
+    'Last fill price vs arrival mid in basis points, positive is a cost',
+    ['mode', 'symbol']
+)
+SLIPPAGE_REJECTS = Counter(
+    'paper_slippage_rejects_total',
+    'Orders rejected because modelled slippage exceeded max_slippage_bps',
+    ['mode', 'symbol']
+)
+POST_GAP_ORDERS = Counter(
+    'paper_post_gap_orders_total',
+    'Orders rejected or held during the cooldown after a market-data gap',
+    ['mode', 'action']
+)
+CROSSED_BOOKS = Counter(
+    'paper_crossed_books_total',
+    'Market snapshots with best_bid >= best_ask (crossed or locked)',
+    ['mode', 'symbol']
+)
+FUNDING_TOTAL = Counter(
+    'paper_funding_total',
+    'Funding accrued in quote currency, split into paid and received',
+    ['mode', 'symbol', 'direction']
+)
+ACCOUNT_EQUITY = Gauge(
+    'paper_account_equity',
+    'Cash balance plus unrealized PnL of open positions',
+    ['mode']
+)
+GROSS_NOTIONAL = Gauge(
+    'paper_gross_notional',
+    'Sum of absolute position notional (size x mark) across symbols',
+    ['mode']
+)
+NET_NOTIONAL = Gauge(
+    'paper_net_notional',
+    'Signed position notional (size x mark) across symbols, short negative',
+    ['mode']
+)
 MAKER_RATIO = Gauge(
     'paper_maker_ratio', 
     'Ratio of maker fills', 
@@ -22,6 +65,44 @@ MAKER_RATIO = Gauge(
 SIGNAL_ACK_LATENCY = Histogram(
     'paper_signal_ack_latency_seconds', 
     'Latency from signal to acknowledgement', 
+    ['mode', 'symbol']
+)
+SLIPPAGE_BPS = Histogram(
+    'paper_fill_slippage_bps',
+    'Modelled slippage per fill in basis points',
+    ['mode', 'symbol'],
+    buckets=(0.5, 1, 2, 3, 5, 7.5, 10, 15, 25, 50, float('inf'))
+)
+RESTING_ORDER_AGE = Histogram(
+    'paper_resting_order_age_seconds',
+    'Market-data time a resting limit order spent on the book, by how it left',
+    ['mode', 'reason'],
+    buckets=(1, 5, 15, 60, 300, 900, 3600, 4 * 3600, 24 * 3600, float('inf'))
+)
+REPLAY_DATA_GAP_SECONDS = Histogram(
+    'replay_data_gap_seconds',
+    'Data-time gap between consecutive replayed records of a symbol',
+    ['symbol'],
+    buckets=(1, 5, 15, 60, 300, 900, 3600, 4 * 3600, 24 * 3600, float('inf'))
+)
+REPLAY_DATA_GAPS = Counter(
+    'replay_data_gaps_total',
+    'Replayed record transitions whose gap exceeds the replay gap threshold',
+    ['symbol']
+)
+FILL_QUEUE_DEPTH = Gauge(
+    'paper_fill_queue_depth',
+    'Paper fills scheduled but not yet applied',
+    ['mode']
+)
+SYMBOL_ENABLED = Gauge(
+    'paper_symbol_enabled',
+    'Whether new orders are accepted for a symbol toggled at runtime (1=enabled)',
+    ['mode', 'symbol']
+)
+IN_FLIGHT_ORDERS = Gauge(
+    'paper_in_flight_orders',
+    'Paper orders accepted but not yet fully filled or cancelled',
     ['mode']
 )
 TRADING_MODE = Gauge(
@@ -39,6 +120,51 @@ REJECT_RATE = Gauge(
     'Current order rejection rate',
     ['mode']
 )
+FEED_HEALTHY = Gauge(
+    'feed_healthy',
+    'Market data feed health (1=publishing, 0=stalled)',
+    ['mode']
+)
+STALE_MESSAGES_DROPPED = Counter(
+    'messaging_stale_messages_dropped_total',
+    'Messages dropped for exceeding messaging.max_message_age_s',
+    ['service', 'subject']
+)
+BOOK_IMBALANCE = Gauge(
+    'market_book_imbalance',
+    'Normalised top-of-book imbalance (bid-ask)/(bid+ask) in [-1, 1]',
+    ['symbol']
+)
+BUILD_INFO = Gauge(
+    'build_info',
+    'Build metadata for the running service (always 1)',
+    ['service', 'version', 'commit']
+)
+SERVICE_START_TIME = Gauge(
+    'service_start_time_seconds',
+    'Unix time the service started',
+    ['service']
+)
+
+# Injected at image build time (docker build --build-arg BUILD_VERSION=...).
+BUILD_VERSION = os.getenv("BUILD_VERSION", "dev")
+BUILD_COMMIT = os.getenv("BUILD_COMMIT", "unknown")
+
+
+def bounded_symbol(symbol: str, allowed: Collection[str]) -> str:
+    """``symbol`` when allow-listed, else ``"other"``, to cap label cardinality."""
+    return symbol if symbol in allowed else "other"
+
+
+def register_build_info(service: str) -> None:
+    """Publish build metadata and start time for ``service``.
+
+    ``process_start_time_seconds`` itself comes from prometheus_client's
+    default process collector; the per-service start time covers services
+    co-hosted in one process.
+    """
+    BUILD_INFO.labels(service=service, version=BUILD_VERSION, commit=BUILD_COMMIT).set(1)
+    SERVICE_START_TIME.labels(service=service).set(time.time())
 
 
 class MetricsManager:
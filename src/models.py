@@ -5,7 +5,7 @@ Shared data models and types.
 from __future__ import annotations
 
 from datetime import datetime
-from typing import Literal, Optional
+from typing import Dict, Literal, Optional
 
 from pydantic import BaseModel
 
@@ -13,6 +13,66 @@ Mode = Literal["live", "paper", "replay", "backtest"]
 Side = Literal["buy", "sell"]
 OrderType = Literal["market", "limit", "stop", "stop_market"]
 
+# Stable codes carried as ``error_code`` on execution reports, so consumers
+# can switch on them; ``error`` stays a human-readable message for logs.
+ERR_INVALID_PAYLOAD = "ERR_INVALID_PAYLOAD"
+ERR_INVALID_ORDER = "ERR_INVALID_ORDER"
+ERR_NO_MARKET_DATA = "ERR_NO_MARKET_DATA"
+ERR_MARKET_DATA_STALE = "ERR_MARKET_DATA_STALE"
+ERR_CROSSED_BOOK = "ERR_CROSSED_BOOK"
+ERR_NO_LIQUIDITY = "ERR_NO_LIQUIDITY"
+ERR_MAX_ORDER_QTY = "ERR_MAX_ORDER_QTY"
+ERR_MAX_SLIPPAGE = "ERR_MAX_SLIPPAGE"
+ERR_PRICE_OUT_OF_BAND = "ERR_PRICE_OUT_OF_BAND"
+ERR_MAX_OPEN_ORDERS = "ERR_MAX_OPEN_ORDERS"
+ERR_REDUCE_ONLY = "ERR_REDUCE_ONLY"
+ERR_WOULD_FLIP = "ERR_WOULD_FLIP"
+ERR_UNKNOWN_ORDER = "ERR_UNKNOWN_ORDER"
+ERR_POST_GAP_COOLDOWN = "ERR_POST_GAP_COOLDOWN"
+ERR_SYMBOL_DISABLED = "ERR_SYMBOL_DISABLED"
+ERR_SIZE_FACTOR_ZERO = "ERR_SIZE_FACTOR_ZERO"
+ERR_AMEND_WOULD_CROSS = "ERR_AMEND_WOULD_CROSS"
+ERR_TOO_MANY_IN_FLIGHT = "ERR_TOO_MANY_IN_FLIGHT"
+ERR_LIQUIDATION_GUARD = "ERR_LIQUIDATION_GUARD"
+ERR_PARTIAL_REJECT = "ERR_PARTIAL_REJECT"
+ERR_EXPIRED = "ERR_EXPIRED"
+ERR_CANCELLED = "ERR_CANCELLED"
+ERR_UNKNOWN = "ERR_UNKNOWN"
+
+# Reject/unfilled reason -> error code
+_REASON_ERROR_CODES: Dict[str, str] = {
+    "invalid_payload": ERR_INVALID_PAYLOAD,
+    "invalid_order": ERR_INVALID_ORDER,
+    "no_market_data": ERR_NO_MARKET_DATA,
+    "market_data_stale": ERR_MARKET_DATA_STALE,
+    "crossed_book": ERR_CROSSED_BOOK,
+    "no_liquidity": ERR_NO_LIQUIDITY,
+    "max_order_qty_exceeded": ERR_MAX_ORDER_QTY,
+    "max_slippage_exceeded": ERR_MAX_SLIPPAGE,
+    "price_out_of_band": ERR_PRICE_OUT_OF_BAND,
+    "max_open_orders": ERR_MAX_OPEN_ORDERS,
+    "reduce_only_exceeds_position": ERR_REDUCE_ONLY,
+    "would_flip_position": ERR_WOULD_FLIP,
+    "unknown_order": ERR_UNKNOWN_ORDER,
+    "post_gap_cooldown": ERR_POST_GAP_COOLDOWN,
+    "symbol_disabled": ERR_SYMBOL_DISABLED,
+    "size_factor_zero": ERR_SIZE_FACTOR_ZERO,
+    "amend_would_cross": ERR_AMEND_WOULD_CROSS,
+    "too_many_in_flight": ERR_TOO_MANY_IN_FLIGHT,
+    "liquidation_guard": ERR_LIQUIDATION_GUARD,
+    "partial_reject": ERR_PARTIAL_REJECT,
+    "expired": ERR_EXPIRED,
+}
+
+
+def error_code(reason: str) -> str:
+    """Error code for a reject or unfilled ``reason``; ``""`` for no error."""
+    if not reason:
+        return ""
+    if reason.startswith("cancelled"):
+        return ERR_CANCELLED
+    return _REASON_ERROR_CODES.get(reason, ERR_UNKNOWN)
+
 
 class OrderResponse(BaseModel):
     """Order acknowledgement returned to upstream callers."""
@@ -52,6 +112,10 @@ class MarketSnapshot(BaseModel):
     last_side: Optional[Side] = None
     last_size: float = 0.0
     funding_rate: float = 0.0
+    index_price: Optional[float] = None
+    # Bar range, present when the snapshot was derived from OHLC data
+    high: Optional[float] = None
+    low: Optional[float] = None
     timestamp: datetime
     order_flow_imbalance: float = 0.0
 
@@ -60,7 +60,7 @@ async def main():
     # Start Services (Background Tasks)
     for service in services:
         logger.info(f"Starting Service: {service.name}...")
-        await service.on_startup()
+        await service.start()
 
     # Start Trading Engine (Main Loop)
     engine = TradingEngine()
@@ -87,7 +87,7 @@ async def main():
         # Shutdown in reverse order
         await engine.shutdown()
         for service in reversed(services):
-            await service.on_shutdown()
+            await service.stop()
 
 
 if __name__ == "__main__":
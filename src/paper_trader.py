@@ -10,19 +10,95 @@ fees, funding accrual, and queue dynamics.  All events are persisted through
 from __future__ import annotations
 
 import asyncio
+import heapq
+import itertools
 import logging
 import math
 import random
+import time
 import uuid
-from collections import defaultdict
-from dataclasses import dataclass
-from datetime import datetime, timezone
-from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple, cast
+from collections import defaultdict, deque
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import (
+    Any,
+    Awaitable,
+    Callable,
+    Deque,
+    Dict,
+    Iterable,
+    List,
+    Optional,
+    Set,
+    Tuple,
+    cast,
+)
 
 from .config import PaperConfig, RiskManagementConfig
 from .database import DatabaseManager, Order, PnLEntry, Position, Trade
-from .metrics import AVERAGE_SLIPPAGE_BPS, MAKER_RATIO, SIGNAL_ACK_LATENCY
-from .models import MarketSnapshot, Mode, OrderType, Side
+from .metrics import (
+    ACCOUNT_EQUITY,
+    AVERAGE_SLIPPAGE_BPS,
+    CROSSED_BOOKS,
+    FILL_QUEUE_DEPTH,
+    FUNDING_TOTAL,
+    GROSS_NOTIONAL,
+    IMPLEMENTATION_SHORTFALL_BPS,
+    IN_FLIGHT_ORDERS,
+    MAKER_RATIO,
+    NET_NOTIONAL,
+    POST_GAP_ORDERS,
+    RESTING_ORDER_AGE,
+    SIGNAL_ACK_LATENCY,
+    SLIPPAGE_BPS,
+    SLIPPAGE_REJECTS,
+    SYMBOL_ENABLED,
+    bounded_symbol,
+)
+from .models import MarketSnapshot, Mode, OrderType, Side, error_code
+
+
+# PaperConfig fields that may be changed on a running broker
+PATCHABLE_FIELDS = frozenset(
+    {
+        "latency_ms",
+        "slippage_bps",
+        "max_slippage_bps",
+        "spread_slippage_coeff",
+        "ofi_slippage_coeff",
+        "buy_slippage_coeff",
+        "sell_slippage_coeff",
+        "per_symbol",
+    }
+)
+
+# Report fields rounded by SymbolOverrides.price_precision / qty_precision;
+# fees and PnL are quote-currency amounts and follow price precision.
+_PRICE_REPORT_FIELDS = (
+    "price",
+    "mark_price",
+    "arrival_mid",
+    "initial_price",
+    "fees",
+    "funding",
+    "realized_pnl",
+    "balance",
+    "equity",
+)
+_QTY_REPORT_FIELDS = ("quantity", "remaining_qty", "rejected_qty")
+
+# Floor on the synthetic spread built from bar data
+_MIN_BAR_SPREAD_BPS = 4.0
+
+# Per-tick decay of the order-flow imbalance accumulator
+_OFI_DECAY = 0.85
+
+# Per-tick decay of the last-price move average behind synthetic_spread="vol"
+_SPREAD_VOL_DECAY = 0.94
+
+
+def _as_utc(ts: datetime) -> datetime:
+    return ts if ts.tzinfo else ts.replace(tzinfo=timezone.utc)
 
 
 @dataclass
@@ -31,6 +107,8 @@ class _RestingOrder:
     limit_price: float
     remaining_qty: float
     reduce_only: bool = False
+    # Market-data time the order went onto the book
+    rested_at: Optional[datetime] = None
 
 
 @dataclass
@@ -48,19 +126,37 @@ class _PendingMarketOrder:
     reduce_only: bool = True
 
 
+@dataclass
+class _FundingLot:
+    """Position size that prepaid an hour of funding, kept for proration."""
+
+    quantity: float
+    price: float
+    rate: float
+    opened_at: datetime
+
+
 @dataclass
 class _PositionState:
     symbol: str
     size: float = 0.0  # positive = long, negative = short
     avg_price: float = 0.0
     unrealized_pnl: float = 0.0
+    mark_price: float = 0.0
+    # Open size by when it prepaid funding, oldest first
+    funding_lots: Deque[_FundingLot] = field(default_factory=deque)
+
+    @property
+    def notional(self) -> float:
+        """Signed notional at the last mark (negative when short)."""
+        return self.size * self.mark_price
 
     def update_mark(self, mark_price: float) -> None:
+        self.mark_price = mark_price
         if self.size == 0:
             self.unrealized_pnl = 0.0
             return
-        direction = 1 if self.size > 0 else -1
-        self.unrealized_pnl = (mark_price - self.avg_price) * self.size * direction
+        self.unrealized_pnl = (mark_price - self.avg_price) * self.size
 
 
 class PaperBroker:
@@ -81,18 +177,36 @@ class PaperBroker:
             Callable[[Dict[str, Any]], Awaitable[None]]
         ] = None,
         time_provider: Optional[Callable[[], datetime]] = None,
+        metric_symbols: Optional[Iterable[str]] = None,
     ):
         self.config = config
         self.database = database
         self.mode = mode
         self.run_id = run_id
         self._balance = initial_balance
+        self._initial_balance = initial_balance
         self._execution_listener = execution_listener
         self._time_provider = time_provider or (lambda: datetime.now(timezone.utc))
+        # Allow-list for the histogram symbol label (falls back to config)
+        if metric_symbols is None:
+            metric_symbols = config.metric_symbols or ()
+        self._metric_symbols = frozenset(metric_symbols)
 
         self._lock = asyncio.Lock()
         self._market_state: Dict[str, MarketSnapshot] = {}
-        self._positions: Dict[str, _PositionState] = {}
+        # symbol -> (warmup ticks seen, summed signed flow) for OFI seeding
+        self._ofi_warmup: Dict[str, Tuple[int, float]] = {}
+        # Symbols whose latest book was crossed and left unrepaired
+        self._crossed_books: Set[str] = set()
+        # Symbols disabled at runtime; only reduce-only orders are accepted
+        self._disabled_symbols: Set[str] = set()
+        self._positions: Dict[str, _PositionState] = self._seed_positions()
+        # Signed fill quantities, replayed by reconcile_positions(); fills
+        # evicted from the bounded journal fold into the per-symbol baseline
+        self._fill_journal: Deque[Tuple[str, float]] = deque()
+        self._journal_baseline: Dict[str, float] = defaultdict(
+            float, {symbol: state.size for symbol, state in self._positions.items()}
+        )
         self._resting_limits: Dict[str, List[_RestingOrder]] = {}
         self._stop_orders: Dict[str, _StopOrder] = {}
         self._pending_markets: List[_PendingMarketOrder] = []
@@ -101,7 +215,21 @@ class PaperBroker:
             config.latency_ms.mean, config.latency_ms.p95
         )
         self._order_progress: Dict[str, float] = {}
-        self._random = random.Random(config.seed)
+        # Aliases already logged once, to keep per-tick normalisation quiet
+        self._logged_aliases: Set[str] = set()
+        # Quantity rejected per market order, reported once its fills complete
+        self._partial_rejects: Dict[str, float] = {}
+        # Synchronous callers awaiting an order's next report, by client_id
+        self._report_waiters: Dict[str, List["asyncio.Future[Dict[str, Any]]"]] = {}
+        # Effective seed, resolved once so a clock-seeded run can be replayed
+        self.seed: int = (
+            config.seed if config.seed is not None else time.time_ns() % 2**32
+        )
+        self._random = random.Random(self.seed)
+        # Decayed absolute last-price move (bps) per symbol, for synthetic spreads
+        self._last_move_bps: Dict[str, float] = {}
+        # Market-data time each symbol's post-gap cooldown ends
+        self._gap_cooldown_until: Dict[str, datetime] = {}
         self._max_leverage = max(float(config.max_leverage), 1.0)
         self._maintenance_margin_pct = max(float(config.maintenance_margin_pct), 0.0)
         self._initial_margin_pct = max(
@@ -111,22 +239,125 @@ class PaperBroker:
             float(risk_config.stops.hard_risk_percent) if risk_config else 0.02
         )
 
+        if config.force_liquidity != "auto":
+            logging.getLogger(__name__).warning(
+                "paper.force_liquidity=%s: every limit fill is classed %s; "
+                "for fee research only",
+                config.force_liquidity,
+                config.force_liquidity,
+            )
+
         self._maker_fills = 0
         self._taker_fills = 0
         self._maker_fills_by_symbol: Dict[str, int] = defaultdict(int)
         self._taker_fills_by_symbol: Dict[str, int] = defaultdict(int)
 
-    async def _sleep(self, delay_ms: float) -> None:
-        """Sleep wrapper to allow skipping in backtest mode."""
-        if self.mode == "backtest":
-            return
-        await asyncio.sleep(delay_ms / 1000.0)
+        # Fills wait in a heap ordered by due time (sequence breaks ties) and
+        # are applied by a fixed pool of workers instead of one task each.
+        # Each carries the epoch it was scheduled in; reset() bumps the epoch
+        # so fills a worker already holds are discarded, not applied.
+        self._scheduled_fills: List[Tuple[float, int, int, Dict[str, Any]]] = []
+        self._fill_seq = itertools.count()
+        self._fill_epoch = 0
+        self._fill_wakeup: Optional[asyncio.Event] = None
+        self._ready_fills: Optional["asyncio.Queue[Tuple[int, Dict[str, Any]]]"] = None
+        self._fill_tasks: List["asyncio.Task[None]"] = []
+
+    def _schedule_fill(self, **fill: Any) -> None:
+        """Queue a fill to be applied once its simulated latency elapses."""
+        forced = self.config.force_liquidity
+        if forced != "auto" and fill["order"].order_type == "limit":
+            fill["maker"] = forced == "maker"
+        loop = asyncio.get_running_loop()
+        if not self._fill_tasks:
+            self._start_fill_workers()
+        # Backtests skip latency; fills still apply in scheduling order.
+        delay_s = 0.0 if self.mode == "backtest" else fill["delay_ms"] / 1000.0
+        heapq.heappush(
+            self._scheduled_fills,
+            (loop.time() + delay_s, next(self._fill_seq), self._fill_epoch, fill),
+        )
+        self._update_fill_queue_depth()
+        assert self._fill_wakeup is not None
+        self._fill_wakeup.set()
+
+    def _start_fill_workers(self) -> None:
+        self._fill_wakeup = asyncio.Event()
+        self._ready_fills = asyncio.Queue()
+        self._fill_tasks = [asyncio.create_task(self._dispatch_fills())]
+        # One worker in backtests keeps fill order, and so the RNG draws made
+        # while filling, independent of database I/O timing.
+        workers = 1 if self.mode == "backtest" else self.config.fill_workers
+        self._fill_tasks.extend(
+            asyncio.create_task(self._fill_worker()) for _ in range(workers)
+        )
+
+    async def _dispatch_fills(self) -> None:
+        """Move fills from the schedule to the worker queue as they fall due."""
+        assert self._fill_wakeup is not None and self._ready_fills is not None
+        loop = asyncio.get_running_loop()
+        while True:
+            if not self._scheduled_fills:
+                await self._fill_wakeup.wait()
+                self._fill_wakeup.clear()
+                continue
+            wait_s = self._scheduled_fills[0][0] - loop.time()
+            if wait_s > 0:
+                # Wake early if a sooner fill is scheduled meanwhile
+                try:
+                    await asyncio.wait_for(self._fill_wakeup.wait(), wait_s)
+                except asyncio.TimeoutError:
+                    pass
+                self._fill_wakeup.clear()
+                continue
+            _, _, epoch, fill = heapq.heappop(self._scheduled_fills)
+            self._ready_fills.put_nowait((epoch, fill))
+
+    async def _fill_worker(self) -> None:
+        assert self._ready_fills is not None
+        while True:
+            epoch, fill = await self._ready_fills.get()
+            try:
+                await self._finalise_fill(epoch=epoch, **fill)
+            finally:
+                self._ready_fills.task_done()
+                self._update_fill_queue_depth()
+
+    def _update_in_flight_orders(self) -> None:
+        IN_FLIGHT_ORDERS.labels(mode=self.mode).set(len(self._order_progress))
+
+    def _update_fill_queue_depth(self) -> None:
+        pending = len(self._scheduled_fills)
+        if self._ready_fills is not None:
+            pending += self._ready_fills.qsize()
+        FILL_QUEUE_DEPTH.labels(mode=self.mode).set(pending)
+
+    async def wait_for_fills(self) -> None:
+        """Wait until every scheduled fill has been applied."""
+        loop = asyncio.get_running_loop()
+        while self._scheduled_fills:
+            # Sleep until the head fill falls due rather than spinning
+            await asyncio.sleep(max(self._scheduled_fills[0][0] - loop.time(), 0.0))
+        if self._ready_fills is not None:
+            await self._ready_fills.join()
+
+    async def close(self) -> None:
+        """Stop the fill workers; fills still scheduled are dropped."""
+        tasks, self._fill_tasks = self._fill_tasks, []
+        for task in tasks:
+            task.cancel()
+        await asyncio.gather(*tasks, return_exceptions=True)
+        self._scheduled_fills.clear()
+        self._fill_wakeup = None
+        self._ready_fills = None
+        self._update_fill_queue_depth()
 
     # ------------------------------------------------------------------ #
     # Public API
     # ------------------------------------------------------------------ #
 
     async def get_open_orders(self, symbol: Optional[str] = None) -> List[Order]:
+        symbol = self._canonical_symbol(symbol) if symbol else symbol
         async with self._lock:
             orders: List[Order] = []
             if symbol:
@@ -161,20 +392,109 @@ class PaperBroker:
         reduce_only: bool = False,
         is_shadow: bool = False,
         client_id: Optional[str] = None,
+        expires_at: Optional[datetime] = None,
+        ttl_seconds: Optional[float] = None,
+        price_offset_bps: Optional[float] = None,
     ) -> Order:
         """
         Submit an order into the paper broker.
+
+        Resting limit orders may carry an expiry, either absolute
+        (``expires_at``) or relative to the current snapshot timestamp
+        (``ttl_seconds``).  Expiry is evaluated against market-data time.
+
+        A limit order without ``price`` may give ``price_offset_bps`` instead;
+        the limit is then ``mid * (1 + offset / 10_000)`` at submission, so a
+        buy at +5 bps rests 5 bps above mid.
+
+        A reduce-only order larger than the open position is clamped to it or
+        rejected, per ``reduce_only_overflow``; under ``no_flip`` an order that
+        would take the position through zero is rejected.  Stops are checked
+        on trigger.
+
+        While the symbol is disabled (:meth:`set_symbol_enabled`) only
+        reduce-only orders are accepted.
         """
 
+        symbol = self._canonical_symbol(symbol)
         if quantity <= 0:
             raise ValueError("quantity must be positive")
+        if ttl_seconds is not None and ttl_seconds <= 0:
+            raise ValueError("ttl_seconds must be positive")
+
+        max_qty = self._max_order_qty(symbol)
+        if max_qty is not None and quantity > max_qty:
+            logging.getLogger(__name__).warning(
+                "Order rejected: %s qty=%.8f exceeds max_order_qty=%.8f",
+                symbol,
+                quantity,
+                max_qty,
+            )
+            raise ValueError("max_order_qty_exceeded")
 
         async with self._lock:
             snapshot = self._market_state.get(symbol)
             if not snapshot:
                 raise RuntimeError(f"No market data available for {symbol}")
+            if symbol in self._crossed_books:
+                raise ValueError("crossed_book")
+            if symbol in self._disabled_symbols and not reduce_only:
+                raise ValueError("symbol_disabled")
+
+            if price_offset_bps is not None and not price:
+                if order_type != "limit":
+                    raise ValueError("price_offset_bps requires a limit order")
+                mid = snapshot.mid_price
+                if mid <= 0:
+                    raise RuntimeError(f"No mid price available for {symbol}")
+                price = mid * (1 + price_offset_bps / 10_000)
+            if order_type == "limit" and price:
+                self._check_price_band(symbol, price, snapshot)
+
+            if order_type not in ("stop", "stop_market"):
+                if reduce_only:
+                    quantity = self._reduce_only_quantity(symbol, side, quantity)
+                self._check_flip(symbol, side, quantity)
+
+            no_liquidity = self._lacks_liquidity(side, order_type, price, snapshot)
+            if no_liquidity and self.config.zero_liquidity_policy == "reject":
+                raise ValueError("no_liquidity")
+            if self._in_gap_cooldown(symbol, snapshot) and self._takes_liquidity(
+                side, order_type, price, snapshot
+            ):
+                if self.config.post_gap_policy == "reject":
+                    POST_GAP_ORDERS.labels(mode=self.mode, action="rejected").inc()
+                    raise ValueError("post_gap_cooldown")
+                # Held like an order facing an empty book side
+                POST_GAP_ORDERS.labels(mode=self.mode, action="held").inc()
+                no_liquidity = True
+            self._check_slippage_band(side, order_type, price, snapshot)
+
+            rests = order_type == "limit" and price is not None and (
+                no_liquidity or not self._limit_crosses_spread(side, price, snapshot)
+            )
+            if rests:
+                self._check_open_order_limits(symbol)
+
+            if ttl_seconds is not None:
+                expires_at = _as_utc(snapshot.timestamp) + timedelta(
+                    seconds=ttl_seconds
+                )
 
             order_id = client_id or f"paper-{uuid.uuid4().hex[:12]}"
+            max_in_flight = self.config.max_in_flight_orders
+            if (
+                max_in_flight is not None
+                and order_id not in self._order_progress
+                and len(self._order_progress) >= max_in_flight
+            ):
+                logging.getLogger(__name__).warning(
+                    "Order rejected: %d orders in flight (max_in_flight_orders=%d)",
+                    len(self._order_progress),
+                    max_in_flight,
+                )
+                raise ValueError("too_many_in_flight")
+
             order = Order(
                 client_id=order_id,
                 order_id=order_id,
@@ -188,13 +508,19 @@ class PaperBroker:
                 mode=self.mode,
                 run_id=self.run_id,
                 is_shadow=is_shadow,
+                expires_at=_as_utc(expires_at) if expires_at else None,
+                price_offset_bps=price_offset_bps,
+                arrival_mid=snapshot.mid_price or None,
             )
 
             await self.database.create_order(order)
             self._order_progress[order.client_id] = order.quantity
+            self._update_in_flight_orders()
 
             if order_type in ("stop", "stop_market"):
                 if stop_price is None:
+                    self._order_progress.pop(order.client_id, None)
+                    self._update_in_flight_orders()
                     raise ValueError("stop orders must provide stop_price")
                 self._stop_orders[order.client_id] = _StopOrder(
                     order=order, stop_price=stop_price, reduce_only=reduce_only
@@ -202,22 +528,36 @@ class PaperBroker:
                 return order
 
             if order_type == "limit" and price is None:
+                self._order_progress.pop(order.client_id, None)
+                self._update_in_flight_orders()
                 raise ValueError("limit orders must provide price")
 
-            fills = self._simulate_order(snapshot, order, reduce_only=reduce_only)
+            if no_liquidity and order_type == "market":
+                # Delayed: fill on the first snapshot showing size to take
+                self._pending_markets.append(
+                    _PendingMarketOrder(
+                        order=order, remaining_qty=quantity, reduce_only=reduce_only
+                    )
+                )
+                return order
+
+            # A marketable limit facing an empty side waits on the book for size
+            fills = (
+                []
+                if no_liquidity
+                else self._simulate_order(snapshot, order, reduce_only=reduce_only)
+            )
             if fills:
                 for delay_ms, fill_qty, fill_price, maker, slippage_bps in fills:
-                    asyncio.create_task(
-                        self._finalise_fill(
-                            order=order,
-                            snapshot=snapshot,
-                            fill_qty=fill_qty,
-                            fill_price=fill_price,
-                            maker=maker,
-                            slippage_bps=slippage_bps,
-                            delay_ms=delay_ms,
-                            reduce_only=reduce_only,
-                        )
+                    self._schedule_fill(
+                        order=order,
+                        snapshot=snapshot,
+                        fill_qty=fill_qty,
+                        fill_price=fill_price,
+                        maker=maker,
+                        slippage_bps=slippage_bps,
+                        delay_ms=delay_ms,
+                        reduce_only=reduce_only,
                     )
             else:
                 # Resting limit order waiting for future fill
@@ -227,39 +567,229 @@ class PaperBroker:
                         limit_price=price if price is not None else 0.0,
                         remaining_qty=quantity,
                         reduce_only=reduce_only,
+                        rested_at=_as_utc(snapshot.timestamp),
                     )
                 )
 
             return order
 
+    def next_report(self, client_id: str) -> "asyncio.Future[Dict[str, Any]]":
+        """Future resolved with the next report emitted for ``client_id``.
+
+        Register before placing the order.  Cancelling the future (as
+        ``asyncio.wait_for`` does on timeout) unregisters it.
+        """
+        waiter: "asyncio.Future[Dict[str, Any]]" = (
+            asyncio.get_running_loop().create_future()
+        )
+        self._report_waiters.setdefault(client_id, []).append(waiter)
+        waiter.add_done_callback(lambda done: self._discard_waiter(client_id, done))
+        return waiter
+
+    def _discard_waiter(
+        self, client_id: str, waiter: "asyncio.Future[Dict[str, Any]]"
+    ) -> None:
+        waiters = self._report_waiters.get(client_id)
+        if waiters and waiter in waiters:
+            waiters.remove(waiter)
+            if not waiters:
+                del self._report_waiters[client_id]
+
+    async def place_order_and_wait(
+        self,
+        symbol: str,
+        side: Side,
+        order_type: OrderType,
+        quantity: float,
+        *,
+        timeout: float,
+        client_id: Optional[str] = None,
+        **kwargs: Any,
+    ) -> Tuple[Order, Optional[Dict[str, Any]]]:
+        """
+        Synchronous path: place an order and wait for its first execution report.
+
+        Returns the order and its first report (fill, partial reject or
+        liquidation-guard reject), or ``None`` when nothing is reported within
+        ``timeout`` seconds, e.g. a limit resting on the book.  The wait
+        includes the simulated latency of the first fill.
+        """
+
+        client_id = client_id or f"paper-{uuid.uuid4().hex[:12]}"
+        waiter = self.next_report(client_id)
+        try:
+            order = await self.place_order(
+                symbol, side, order_type, quantity, client_id=client_id, **kwargs
+            )
+        except BaseException:
+            waiter.cancel()
+            raise
+        try:
+            report = await asyncio.wait_for(waiter, timeout)
+        except asyncio.TimeoutError:
+            report = None
+        return order, report
+
+    async def simulate_order(
+        self,
+        symbol: str,
+        side: Side,
+        order_type: OrderType,
+        quantity: float,
+        *,
+        price: Optional[float] = None,
+    ) -> Dict[str, Any]:
+        """
+        Project the fills ``place_order`` would produce against current market
+        state, without executing anything.
+
+        Runs the same fill plan (slippage, partial-fill slicing, partial
+        rejects, fees) but persists nothing and leaves positions, balance and
+        the seeded RNG untouched, so a what-if never perturbs a replay.  A
+        limit that would rest on the book projects no fills.
+        """
+
+        symbol = self._canonical_symbol(symbol)
+        if quantity <= 0:
+            raise ValueError("quantity must be positive")
+        if order_type not in ("market", "limit"):
+            raise ValueError("only market and limit orders can be simulated")
+        if order_type == "limit" and price is None:
+            raise ValueError("limit orders must provide price")
+
+        async with self._lock:
+            snapshot = self._market_state.get(symbol)
+            if not snapshot:
+                raise RuntimeError(f"No market data available for {symbol}")
+            if symbol in self._crossed_books:
+                raise ValueError("crossed_book")
+            no_liquidity = self._lacks_liquidity(side, order_type, price, snapshot)
+            if no_liquidity and self.config.zero_liquidity_policy == "reject":
+                raise ValueError("no_liquidity")
+            self._check_slippage_band(side, order_type, price, snapshot)
+
+            order = Order(
+                client_id=f"simulate-{uuid.uuid4().hex[:12]}",
+                symbol=symbol,
+                side=side,
+                order_type=order_type,
+                quantity=quantity,
+                price=price,
+                status="simulated",
+                mode=self.mode,
+                run_id=self.run_id,
+                arrival_mid=snapshot.mid_price or None,
+            )
+            rng_state = self._random.getstate()
+            try:
+                plan = (
+                    []
+                    if no_liquidity
+                    else self._simulate_order(snapshot, order, reduce_only=False)
+                )
+            finally:
+                self._random.setstate(rng_state)
+                self._partial_rejects.pop(order.client_id, None)
+                self._order_progress.pop(order.client_id, None)
+                self._update_in_flight_orders()
+
+            fills = [
+                {
+                    "price": fill_price,
+                    "quantity": fill_qty,
+                    "fees": self._compute_fee(
+                        fill_price, fill_qty, maker, spread_bps=snapshot.spread_bps
+                    ),
+                    "slippage_bps": slippage_bps,
+                    "shortfall_bps": self._shortfall_bps(order, fill_price),
+                    "maker": maker,
+                    "latency_ms": delay_ms,
+                }
+                for delay_ms, fill_qty, fill_price, maker, slippage_bps in plan
+            ]
+
+        filled_qty = sum(fill["quantity"] for fill in fills)
+        return self._round_report(
+            {
+                "simulated": True,
+                "symbol": symbol,
+                "side": side,
+                "order_type": order_type,
+                "quantity": quantity,
+                "initial_price": price,
+                "arrival_mid": order.arrival_mid,
+                "rests": not fills,
+                "fills": [
+                    self._round_report({"symbol": symbol, **fill}) for fill in fills
+                ],
+                "filled_qty": filled_qty,
+                "rejected_qty": quantity - filled_qty if fills else 0.0,
+                "price": (
+                    sum(fill["price"] * fill["quantity"] for fill in fills) / filled_qty
+                    if filled_qty > 0
+                    else None
+                ),
+                "fees": sum(fill["fees"] for fill in fills),
+                "mode": self.mode,
+            }
+        )
+
     async def update_market(self, snapshot: MarketSnapshot) -> None:
         """
         Update the broker with the latest market snapshot.
 
         This drives mark-to-market calculation, stop triggers, and fills for
-        resting limit orders.
+        resting limit orders.  A crossed or locked book is never stored as
+        is; see :meth:`_repair_crossed_book`.
         """
 
         triggers: List[_StopOrder] = []
         fills: List[Tuple[_RestingOrder, MarketSnapshot]] = []
+        expired: List[_RestingOrder] = []
         pending_markets: List[_PendingMarketOrder] = []
 
+        canonical = self._canonical_symbol(snapshot.symbol)
+        if canonical != snapshot.symbol:
+            snapshot = snapshot.model_copy(update={"symbol": canonical})
+        if self.config.price_source == "bars":
+            snapshot = self._bar_snapshot(snapshot)
+        elif snapshot.best_bid <= 0 and snapshot.best_ask <= 0:
+            snapshot = self._last_only_snapshot(snapshot)
+
+        repaired = self._repair_crossed_book(snapshot)
+        if repaired is None:
+            async with self._lock:
+                if snapshot.symbol not in self._crossed_books:
+                    logging.getLogger(__name__).warning(
+                        "Crossed book for %s (bid=%.8f ask=%.8f); rejecting orders",
+                        snapshot.symbol,
+                        snapshot.best_bid,
+                        snapshot.best_ask,
+                    )
+                self._crossed_books.add(snapshot.symbol)
+            return
+        snapshot = repaired
+
         async with self._lock:
+            self._crossed_books.discard(snapshot.symbol)
             previous = self._market_state.get(snapshot.symbol)
+            self._detect_gap(previous, snapshot)
             snapshot.order_flow_imbalance = self._compute_order_flow(previous, snapshot)
             self._market_state[snapshot.symbol] = snapshot
 
             # Update marks
             position_state = self._positions.get(snapshot.symbol)
             if position_state:
-                position_state.update_mark(snapshot.mid_price)
+                mark_price = self._mark_price(snapshot)
+                position_state.update_mark(mark_price)
+                self._update_notional_gauges()
                 await self.database.update_position(
                     Position(
                         symbol=snapshot.symbol,
                         side="long" if position_state.size >= 0 else "short",
                         size=abs(position_state.size),
                         entry_price=position_state.avg_price,
-                        mark_price=snapshot.mid_price,
+                        mark_price=mark_price,
                         unrealized_pnl=position_state.unrealized_pnl,
                         percentage=self._position_return_pct(position_state),
                         mode=self.mode,
@@ -277,9 +807,16 @@ class PaperBroker:
             # Resting limit fills
             rest_list = self._resting_limits.get(snapshot.symbol, [])
             remaining_rest = []
+            now = _as_utc(snapshot.timestamp)
             for rest in rest_list:
-                if self._limit_crossed(rest, snapshot):
+                if rest.order.expires_at and now >= rest.order.expires_at:
+                    expired.append(rest)
+                    self._observe_rest_age(rest, "expired", now)
+                elif self._limit_crossed(rest, snapshot) and not self._book_side_empty(
+                    snapshot, cast(Side, rest.order.side)
+                ):
                     fills.append((rest, snapshot))
+                    self._observe_rest_age(rest, "filled", now)
                 else:
                     remaining_rest.append(rest)
             if remaining_rest:
@@ -288,8 +825,20 @@ class PaperBroker:
                 self._resting_limits.pop(snapshot.symbol, None)
 
             if self._pending_markets:
-                pending_markets = list(self._pending_markets)
-                self._pending_markets.clear()
+                waiting: List[_PendingMarketOrder] = []
+                for pending in self._pending_markets:
+                    if (
+                        pending.order.symbol != snapshot.symbol
+                        or self._book_side_empty(snapshot, cast(Side, pending.order.side))
+                        or self._in_gap_cooldown(snapshot.symbol, snapshot)
+                    ):
+                        waiting.append(pending)
+                    else:
+                        pending_markets.append(pending)
+                self._pending_markets = waiting
+
+        for rest in expired:
+            await self._expire_resting_limit(rest, snapshot)
 
         for stop in triggers:
             await self._execute_stop(stop, snapshot)
@@ -304,28 +853,29 @@ class PaperBroker:
                 reduce_only=pending.reduce_only,
             )
             for delay_ms, fill_qty, fill_price, maker, slippage_bps in sim_fills:
-                asyncio.create_task(
-                    self._finalise_fill(
-                        order=pending.order,
-                        snapshot=snapshot,
-                        fill_qty=fill_qty,
-                        fill_price=fill_price,
-                        maker=maker,
-                        slippage_bps=slippage_bps,
-                        delay_ms=delay_ms,
-                        reduce_only=pending.reduce_only,
-                    )
+                self._schedule_fill(
+                    order=pending.order,
+                    snapshot=snapshot,
+                    fill_qty=fill_qty,
+                    fill_price=fill_price,
+                    maker=maker,
+                    slippage_bps=slippage_bps,
+                    delay_ms=delay_ms,
+                    reduce_only=pending.reduce_only,
                 )
 
     async def cancel_all_orders(self, symbol: str) -> List[Dict[str, Any]]:
         """Cancel all open orders for a symbol."""
+        symbol = self._canonical_symbol(symbol)
         cancelled_orders = []
 
         async with self._lock:
             # 1. Cancel Resting Limits
             resting_list = self._resting_limits.pop(symbol, [])
             for rest in resting_list:
+                self._observe_rest_age(rest, "cancelled")
                 cancelled_orders.append(rest.order)
+                self._order_progress.pop(rest.order.client_id, None)
 
             # 2. Cancel Stop Orders
             keys_to_remove = []
@@ -336,6 +886,8 @@ class PaperBroker:
 
             for key in keys_to_remove:
                 del self._stop_orders[key]
+                self._order_progress.pop(key, None)
+            self._update_in_flight_orders()
 
         # 3. Update Status in DB
         results = []
@@ -349,6 +901,99 @@ class PaperBroker:
 
         return results
 
+    async def amend_order(
+        self,
+        client_id: str,
+        *,
+        new_price: Optional[float] = None,
+        new_quantity: Optional[float] = None,
+    ) -> Dict[str, Any]:
+        """Change a resting limit order's price and/or quantity in place.
+
+        A price change or a larger quantity sends the order to the back of
+        its symbol's book, as an exchange resets time priority; a smaller
+        quantity keeps its place.  Amends of orders not resting on the book
+        (unknown, filled or cancelled) are rejected with ``unknown_order``,
+        and a new price that would cross the spread with
+        ``amend_would_cross``.  Returns the confirmation report, which is
+        also emitted.
+        """
+        if new_price is None and new_quantity is None:
+            raise ValueError("amend needs new_price or new_quantity")
+        if new_price is not None and new_price <= 0:
+            raise ValueError("new_price must be positive")
+        if new_quantity is not None and new_quantity <= 0:
+            raise ValueError("new_quantity must be positive")
+
+        async with self._lock:
+            rest = next(
+                (
+                    candidate
+                    for rest_list in self._resting_limits.values()
+                    for candidate in rest_list
+                    if candidate.order.client_id == client_id
+                ),
+                None,
+            )
+            if rest is None:
+                raise ValueError("unknown_order")
+            order = rest.order
+            side = cast(Side, order.side)
+            snapshot = self._market_state.get(order.symbol)
+
+            price = rest.limit_price if new_price is None else new_price
+            quantity = rest.remaining_qty if new_quantity is None else new_quantity
+            if new_quantity is not None:
+                max_qty = self._max_order_qty(order.symbol)
+                if max_qty is not None and quantity > max_qty:
+                    raise ValueError("max_order_qty_exceeded")
+                if rest.reduce_only and quantity > rest.remaining_qty:
+                    quantity = self._reduce_only_quantity(order.symbol, side, quantity)
+            if new_price is not None and snapshot is not None:
+                self._check_price_band(order.symbol, price, snapshot)
+                if self._limit_crosses_spread(side, price, snapshot):
+                    raise ValueError("amend_would_cross")
+
+            loses_priority = (
+                price != rest.limit_price or quantity > rest.remaining_qty
+            )
+            previous_price, previous_qty = rest.limit_price, rest.remaining_qty
+            rest.limit_price = price
+            rest.remaining_qty = quantity
+            order.price = price
+            order.quantity = quantity
+            self._order_progress[order.client_id] = quantity
+            if loses_priority:
+                rest_list = self._resting_limits[order.symbol]
+                rest_list.remove(rest)
+                rest_list.append(rest)
+
+        report = {
+            "order_id": order.order_id or order.client_id,
+            "fill_id": self._next_fill_id(order),
+            "client_id": order.client_id,
+            "symbol": order.symbol,
+            "side": order.side,
+            "executed": False,
+            "status": "amended",
+            "order_type": order.order_type,
+            "price": price,
+            "quantity": quantity,
+            "remaining_qty": quantity,
+            "previous_price": previous_price,
+            "previous_quantity": previous_qty,
+            "priority_kept": not loses_priority,
+            "mode": self.mode,
+            "run_id": self.run_id,
+            "timestamp": (
+                _as_utc(snapshot.timestamp) if snapshot else self._time_provider()
+            ).isoformat(),
+            "is_shadow": order.is_shadow,
+            "reduce_only": rest.reduce_only,
+        }
+        await self._emit_report(report)
+        return report
+
     async def get_positions(self) -> List[Position]:
         async with self._lock:
             return [
@@ -357,19 +1002,21 @@ class PaperBroker:
                     side="long" if state.size >= 0 else "short",
                     size=abs(state.size),
                     entry_price=state.avg_price,
-                    mark_price=self._market_state.get(
-                        symbol,
-                        MarketSnapshot(
-                            symbol=symbol,
-                            best_bid=0,
-                            best_ask=0,
-                            bid_size=0,
-                            ask_size=0,
-                            last_price=0,
-                            timestamp=self._time_provider()
-                            or datetime.now(timezone.utc),
-                        ),
-                    ).mid_price,
+                    mark_price=self._mark_price(
+                        self._market_state.get(
+                            symbol,
+                            MarketSnapshot(
+                                symbol=symbol,
+                                best_bid=0,
+                                best_ask=0,
+                                bid_size=0,
+                                ask_size=0,
+                                last_price=0,
+                                timestamp=self._time_provider()
+                                or datetime.now(timezone.utc),
+                            ),
+                        )
+                    ),
                     unrealized_pnl=state.unrealized_pnl,
                     percentage=self._position_return_pct(state),
                     mode=self.mode,
@@ -380,6 +1027,7 @@ class PaperBroker:
             ]
 
     async def close_position(self, symbol: str) -> bool:
+        symbol = self._canonical_symbol(symbol)
         async with self._lock:
             position = self._positions.get(symbol)
             snapshot = self._market_state.get(symbol)
@@ -398,9 +1046,184 @@ class PaperBroker:
         )
         return True
 
+    async def set_symbol_enabled(self, symbol: str, enabled: bool) -> None:
+        """Allow or stop new orders for ``symbol``; resting orders are untouched."""
+        symbol = self._canonical_symbol(symbol)
+        async with self._lock:
+            if enabled:
+                self._disabled_symbols.discard(symbol)
+            else:
+                self._disabled_symbols.add(symbol)
+        SYMBOL_ENABLED.labels(mode=self.mode, symbol=symbol).set(1 if enabled else 0)
+        logging.getLogger(__name__).info(
+            "Symbol %s %s for new orders", symbol, "enabled" if enabled else "disabled"
+        )
+
+    async def reset(self) -> None:
+        """Return the broker to a clean slate without restarting the process.
+
+        Clears positions, resting/stop/pending orders, fills not yet applied
+        (scheduled, due or held by a worker) and fill statistics, restores
+        the initial balance and reseeds the RNG so scripted runs are
+        reproducible.  Persisted history in the database is left untouched,
+        as are symbols disabled at runtime.
+        """
+        async with self._lock:
+            self._balance = self._initial_balance
+            self._positions = self._seed_positions()
+            self._fill_journal.clear()
+            self._journal_baseline = defaultdict(
+                float, {symbol: state.size for symbol, state in self._positions.items()}
+            )
+            self._resting_limits.clear()
+            self._stop_orders.clear()
+            self._pending_markets.clear()
+            self._order_progress.clear()
+            self._update_in_flight_orders()
+            self._partial_rejects.clear()
+            self._fill_epoch += 1
+            self._scheduled_fills.clear()
+            # Fills already due but not yet picked up by a worker
+            if self._ready_fills is not None:
+                while not self._ready_fills.empty():
+                    self._ready_fills.get_nowait()
+                    self._ready_fills.task_done()
+            self._update_fill_queue_depth()
+            if self.config.seed is not None:
+                self.seed = self.config.seed
+            self._random = random.Random(self.seed)
+            self._last_move_bps.clear()
+            self._gap_cooldown_until.clear()
+            self._ofi_warmup.clear()
+            self._crossed_books.clear()
+            self._maker_fills = 0
+            self._taker_fills = 0
+            self._maker_fills_by_symbol.clear()
+            self._taker_fills_by_symbol.clear()
+            self._update_notional_gauges()
+        logging.getLogger(__name__).info(
+            "PaperBroker reset: balance=$%.2f seed=%d",
+            self._initial_balance,
+            self.seed,
+        )
+
+    async def cancel_open_orders(self, reason: str) -> int:
+        """Cancel every resting, stop and pending order, reporting each one.
+
+        Each report carries ``error=reason`` and the unfilled
+        ``remaining_qty`` so consumers can reconcile their order state.
+        Returns the number of orders cancelled.
+        """
+        cancelled: List[Tuple[Order, float, bool]] = []
+        async with self._lock:
+            for rest_list in self._resting_limits.values():
+                for rest in rest_list:
+                    self._observe_rest_age(rest, "cancelled")
+                    cancelled.append((rest.order, rest.remaining_qty, rest.reduce_only))
+            for stop in self._stop_orders.values():
+                cancelled.append((stop.order, stop.order.quantity, stop.reduce_only))
+            for pending in self._pending_markets:
+                cancelled.append(
+                    (pending.order, pending.remaining_qty, pending.reduce_only)
+                )
+            self._resting_limits.clear()
+            self._stop_orders.clear()
+            self._pending_markets.clear()
+            for order, _, _ in cancelled:
+                self._order_progress.pop(order.client_id, None)
+            self._update_in_flight_orders()
+
+        for order, remaining, reduce_only in cancelled:
+            await self._report_unfilled(
+                order,
+                remaining_qty=remaining,
+                status="canceled",
+                error=reason,
+                reduce_only=reduce_only,
+                snapshot=self._market_state.get(order.symbol),
+            )
+        return len(cancelled)
+
+    async def apply_config_patch(self, patch: Dict[str, Any]) -> PaperConfig:
+        """Merge a partial latency/slippage update into the live config.
+
+        Only keys in ``PATCHABLE_FIELDS`` are accepted; nested sections such
+        as ``latency_ms`` merge field by field.  The merged config is fully
+        validated before it replaces the current one, so a bad patch leaves
+        the broker unchanged.
+        """
+        unknown = set(patch) - PATCHABLE_FIELDS
+        if unknown:
+            raise ValueError(f"Unpatchable PaperConfig fields: {sorted(unknown)}")
+
+        async with self._lock:
+            merged = self.config.model_dump()
+            for key, value in patch.items():
+                if isinstance(value, dict) and isinstance(merged.get(key), dict):
+                    merged[key] = {**merged[key], **value}
+                else:
+                    merged[key] = value
+            config = PaperConfig.model_validate(merged)
+
+            self.config = config
+            self._latency_mu = config.latency_ms.mean
+            self._latency_sigma = self._derive_latency_sigma(
+                config.latency_ms.mean, config.latency_ms.p95
+            )
+        logging.getLogger(__name__).info(
+            "PaperBroker config patched: %s", ", ".join(sorted(patch))
+        )
+        return config
+
+    async def get_stats(self) -> Dict[str, Any]:
+        """Point-in-time counters describing the broker's state."""
+        async with self._lock:
+            open_orders = self._open_order_counts()
+            return {
+                "balance": self._balance,
+                "equity": self._equity(),
+                "positions": sum(1 for p in self._positions.values() if p.size),
+                "open_orders": sum(open_orders.values()),
+                "open_orders_by_symbol": open_orders,
+                "stop_orders": len(self._stop_orders),
+                "in_flight_orders": len(self._order_progress),
+                "scheduled_fills": len(self._scheduled_fills),
+                "maker_fills": self._maker_fills,
+                "taker_fills": self._taker_fills,
+                "seed": self.seed,
+                "disabled_symbols": sorted(self._disabled_symbols),
+            }
+
     async def get_account_balance(self) -> Dict[str, float]:
         async with self._lock:
-            return {"totalWalletBalance": self._balance}
+            return {
+                "totalWalletBalance": self._balance,
+                "totalMarginBalance": self._equity(),
+            }
+
+    async def account_snapshot(self) -> Dict[str, Any]:
+        """Open positions with cash balance and account equity, for publishing."""
+        async with self._lock:
+            positions = [
+                {
+                    "symbol": state.symbol,
+                    "size": state.size,
+                    "avg_price": state.avg_price,
+                    "mark_price": state.mark_price,
+                    "unrealized_pnl": state.unrealized_pnl,
+                    "notional": state.notional,
+                }
+                for state in self._positions.values()
+                if state.size
+            ]
+            return {
+                "balance": self._balance,
+                "equity": self._equity(),
+                "positions": positions,
+                "mode": self.mode,
+                "run_id": self.run_id,
+                "timestamp": self._time_provider().isoformat(),
+            }
 
     async def restore_state(self) -> None:
         logger = logging.getLogger(__name__)
@@ -426,7 +1249,10 @@ class PaperBroker:
         positions = await self.database.get_positions(
             mode=self.mode, run_id=self.run_id
         )
-        restored_positions: Dict[str, _PositionState] = {}
+        # A run with nothing persisted yet keeps its seeded book
+        restored_positions: Dict[str, _PositionState] = (
+            {} if positions else self._seed_positions()
+        )
         for pos in positions:
             direction = 1 if pos.side.lower() == "long" else -1
             restored_positions[pos.symbol] = _PositionState(
@@ -434,6 +1260,7 @@ class PaperBroker:
                 size=direction * pos.size,
                 avg_price=pos.entry_price,
                 unrealized_pnl=pos.unrealized_pnl,
+                mark_price=pos.mark_price,
             )
 
         open_orders: List[Order] = []
@@ -455,6 +1282,7 @@ class PaperBroker:
         ]
 
         filled_by_order: Dict[str, float] = {}
+        fills_by_order: Dict[str, int] = {}
         for is_shadow in (False, True):
             scoped_orders = [o for o in open_orders if o.is_shadow == is_shadow]
             order_ids = [o.order_id or o.client_id for o in scoped_orders]
@@ -468,6 +1296,7 @@ class PaperBroker:
                 filled_by_order[trade.order_id] = filled_by_order.get(
                     trade.order_id, 0.0
                 ) + trade.quantity
+                fills_by_order[trade.order_id] = fills_by_order.get(trade.order_id, 0) + 1
 
         restored_limits: Dict[str, List[_RestingOrder]] = {}
         restored_stops: Dict[str, _StopOrder] = {}
@@ -476,6 +1305,8 @@ class PaperBroker:
 
         for order in open_orders:
             order_id = order.order_id or order.client_id
+            # Continue fill_id numbering after the fills already persisted
+            order.report_seq = fills_by_order.get(order_id, 0)
             filled_qty = filled_by_order.get(order_id, 0.0)
             remaining = max(order.quantity - filled_qty, 0.0)
             if remaining <= 0:
@@ -525,6 +1356,7 @@ class PaperBroker:
                         limit_price=order.price,
                         remaining_qty=remaining,
                         reduce_only=reduce_only,
+                        rested_at=order.created_at,
                     )
                 )
             else:
@@ -539,10 +1371,17 @@ class PaperBroker:
         async with self._lock:
             self._balance = latest_balance
             self._positions = restored_positions
+            self._fill_journal.clear()
+            self._journal_baseline = defaultdict(
+                float,
+                {symbol: state.size for symbol, state in restored_positions.items()},
+            )
             self._resting_limits = restored_limits
             self._stop_orders = restored_stops
             self._pending_markets = restored_pending
             self._order_progress = restored_progress
+            self._update_in_flight_orders()
+            self._update_notional_gauges()
 
         logger.info(
             "PaperBroker restored: balance=$%.2f positions=%d open_orders=%d pending_markets=%d",
@@ -572,28 +1411,248 @@ class PaperBroker:
                 return True
         return False
 
+    def _equity(self) -> float:
+        """Cash balance plus unrealized PnL at the last marks; call under ``_lock``."""
+        return self._balance + sum(
+            state.unrealized_pnl for state in self._positions.values()
+        )
+
+    def _update_notional_gauges(self) -> None:
+        """Publish gross/net notional and equity; call under ``_lock``.
+
+        A pass over the in-memory positions (one per symbol) with no I/O, so
+        it adds nothing measurable to the lock hold time.
+        """
+        gross = 0.0
+        net = 0.0
+        for state in self._positions.values():
+            notional = state.notional
+            gross += abs(notional)
+            net += notional
+        GROSS_NOTIONAL.labels(mode=self.mode).set(gross)
+        NET_NOTIONAL.labels(mode=self.mode).set(net)
+        ACCOUNT_EQUITY.labels(mode=self.mode).set(self._equity())
+
+    def _seed_positions(self) -> Dict[str, _PositionState]:
+        """Fresh position states for ``config.initial_positions``."""
+        seeded: Dict[str, _PositionState] = {}
+        for symbol, seed in self.config.initial_positions.items():
+            symbol = self.config.symbol_aliases.get(symbol, symbol)
+            seeded[symbol] = _PositionState(
+                symbol=symbol,
+                size=seed.size,
+                avg_price=seed.avg_price,
+                mark_price=seed.avg_price,
+            )
+        return seeded
+
+    def _canonical_symbol(self, symbol: str) -> str:
+        canonical = self.config.symbol_aliases.get(symbol)
+        if canonical is None:
+            return symbol
+        if symbol not in self._logged_aliases:
+            self._logged_aliases.add(symbol)
+            logging.getLogger(__name__).info(
+                "Symbol alias applied: %s -> %s", symbol, canonical
+            )
+        return canonical
+
+    def _max_order_qty(self, symbol: str) -> Optional[float]:
+        return self.config.max_order_qty_by_symbol.get(
+            symbol, self.config.max_order_qty
+        )
+
     def _derive_latency_sigma(self, mean: float, p95: float) -> float:
         if p95 <= mean:
             return mean * 0.15 if mean > 0 else 1.0
         # Assuming normal distribution, z-score for 95th percentile ~1.645
         return max((p95 - mean) / 1.645, 1.0)
 
-    def _sample_latency_ms(self) -> float:
-        latency = self._random.gauss(self._latency_mu, self._latency_sigma)
-        return max(latency, 0.0)
+    def _latency_params(self, symbol: str) -> Tuple[float, float]:
+        """Return (mean, sigma) latency for ``symbol``, honouring overrides."""
+        override = self.config.per_symbol.get(symbol)
+        if override is None or (
+            override.latency_mean_ms is None and override.latency_p95_ms is None
+        ):
+            return self._latency_mu, self._latency_sigma
+        mean = (
+            override.latency_mean_ms
+            if override.latency_mean_ms is not None
+            else self.config.latency_ms.mean
+        )
+        p95 = (
+            override.latency_p95_ms
+            if override.latency_p95_ms is not None
+            else self.config.latency_ms.p95
+        )
+        return mean, self._derive_latency_sigma(mean, p95)
+
+    def _slippage_params(self, symbol: str) -> Tuple[float, float]:
+        """Return (base, max) slippage bps for ``symbol``, honouring overrides."""
+        base = self.config.slippage_bps
+        cap = self.config.max_slippage_bps
+        override = self.config.per_symbol.get(symbol)
+        if override is not None:
+            if override.slippage_bps is not None:
+                base = override.slippage_bps
+            if override.max_slippage_bps is not None:
+                cap = override.max_slippage_bps
+        return base, cap
+
+    def _sample_latency_ms(self, symbol: str) -> float:
+        mu, sigma = self._latency_params(symbol)
+        if self.config.deterministic_latency:
+            # Validation keeps every mean within [min_ms, max_ms]
+            return mu
+        latency = max(self._random.gauss(mu, sigma), self.config.latency_ms.min_ms)
+        max_ms = self.config.latency_ms.max_ms
+        return latency if max_ms is None else min(latency, max_ms)
+
+    def _repair_crossed_book(
+        self, snapshot: MarketSnapshot
+    ) -> Optional[MarketSnapshot]:
+        """Return ``snapshot`` usable for pricing, or ``None`` if it must be dropped.
+
+        A book with ``best_bid >= best_ask`` gives a meaningless mid and a
+        negative spread.  Under ``crossed_book_policy="last_price"`` both sides
+        collapse onto ``last_price``; otherwise (or without a last trade) the
+        snapshot is dropped and orders for the symbol are rejected with
+        ``crossed_book`` until a sane book arrives.
+        """
+        bid, ask = snapshot.best_bid, snapshot.best_ask
+        if bid <= 0 or ask <= 0 or bid < ask:
+            return snapshot
+        CROSSED_BOOKS.labels(mode=self.mode, symbol=snapshot.symbol).inc()
+        if self.config.crossed_book_policy == "last_price" and snapshot.last_price > 0:
+            last = snapshot.last_price
+            return snapshot.model_copy(update={"best_bid": last, "best_ask": last})
+        return None
+
+    def _bar_snapshot(self, snapshot: MarketSnapshot) -> MarketSnapshot:
+        """Rebuild top-of-book around the bar close for ``price_source="bars"``.
+
+        The spread is ``bar_spread_range_pct`` of the high-low range, floored
+        at ``_MIN_BAR_SPREAD_BPS``, so fills pay for intrabar movement instead
+        of trusting a quote the bar never carried.
+        """
+        close = snapshot.last_price
+        if close <= 0:
+            return snapshot
+        bar_range = 0.0
+        if snapshot.high is not None and snapshot.low is not None:
+            bar_range = max(snapshot.high - snapshot.low, 0.0)
+        spread = max(
+            bar_range * self.config.bar_spread_range_pct,
+            close * _MIN_BAR_SPREAD_BPS / 10_000,
+        )
+        return snapshot.model_copy(
+            update={"best_bid": close - spread / 2, "best_ask": close + spread / 2}
+        )
+
+    def _detect_gap(
+        self, previous: Optional[MarketSnapshot], snapshot: MarketSnapshot
+    ) -> None:
+        """Open a post-gap cooldown when ``snapshot`` follows a long silence."""
+        threshold = self.config.gap_threshold_s
+        if threshold is None or previous is None:
+            return
+        now = _as_utc(snapshot.timestamp)
+        gap = (now - _as_utc(previous.timestamp)).total_seconds()
+        if gap <= threshold:
+            return
+        self._gap_cooldown_until[snapshot.symbol] = now + timedelta(
+            seconds=self.config.post_gap_cooldown_s
+        )
+        logging.getLogger(__name__).warning(
+            "Market-data gap of %.1fs for %s; taker orders %s for %.1fs",
+            gap,
+            snapshot.symbol,
+            "rejected" if self.config.post_gap_policy == "reject" else "held",
+            self.config.post_gap_cooldown_s,
+        )
+
+    def _in_gap_cooldown(self, symbol: str, snapshot: MarketSnapshot) -> bool:
+        """True while ``snapshot`` falls inside ``symbol``'s post-gap cooldown.
+
+        Measured in market-data time: the window ends with the first tick
+        stamped at or after it.
+        """
+        until = self._gap_cooldown_until.get(symbol)
+        if until is None:
+            return False
+        if _as_utc(snapshot.timestamp) >= until:
+            del self._gap_cooldown_until[symbol]
+            return False
+        return True
+
+    def _last_only_snapshot(self, snapshot: MarketSnapshot) -> MarketSnapshot:
+        """Synthesise top-of-book around ``last_price`` for a quote-less tick.
+
+        Per ``synthetic_spread``: ``fixed`` spreads ``synthetic_spread_bps``
+        around the last trade; ``vol`` widens that to ``vol_mult`` times a
+        decayed average of absolute tick-to-tick moves.  ``off`` leaves the
+        tick alone, so taker fills price off the last trade with no spread.
+        """
+        last = snapshot.last_price
+        mode = self.config.synthetic_spread
+        if mode == "off" or last <= 0:
+            return snapshot
+        spread_bps = self.config.synthetic_spread_bps
+        if mode == "vol":
+            previous = self._market_state.get(snapshot.symbol)
+            move = self._last_move_bps.get(snapshot.symbol, 0.0)
+            if previous is not None and previous.last_price > 0:
+                step = abs(last / previous.last_price - 1) * 10_000
+                move = move * _SPREAD_VOL_DECAY + step * (1 - _SPREAD_VOL_DECAY)
+                self._last_move_bps[snapshot.symbol] = move
+            spread_bps = max(spread_bps, self.config.synthetic_spread_vol_mult * move)
+        half = last * spread_bps / 20_000
+        return snapshot.model_copy(
+            update={"best_bid": last - half, "best_ask": last + half}
+        )
+
+    def _mark_price(self, snapshot: MarketSnapshot) -> float:
+        """Mark price per ``mark_price_source``, falling back to mid."""
+        source = self.config.mark_price_source
+        if source == "last" and snapshot.last_price > 0:
+            return snapshot.last_price
+        if source == "index" and snapshot.index_price and snapshot.index_price > 0:
+            return snapshot.index_price
+        return snapshot.mid_price
 
     def _compute_order_flow(
         self, previous: Optional[MarketSnapshot], current: MarketSnapshot
     ) -> float:
-        if previous is None:
-            base = 0.0
-        else:
-            base = previous.order_flow_imbalance * 0.85  # decay
+        """Decayed signed trade flow, seeded per ``estimator_warmup``.
+
+        During a ``seed_from_first_n`` warmup the estimate is the steady state
+        of the average flow so far, ``avg / (1 - decay)``, rather than a
+        half-built accumulator ramping up from zero.  A symbol's first trade
+        is scaled per ``first_tick_flow`` so one large print cannot skew the
+        first fills.
+        """
+        flow = 0.0
         if current.last_side == "buy":
-            base += current.last_size
+            flow = current.last_size
         elif current.last_side == "sell":
-            base -= current.last_size
-        return base
+            flow = -current.last_size
+
+        warmup = self.config.estimator_warmup
+        if previous is None:
+            if warmup.first_tick_flow == "zero":
+                flow = 0.0
+            elif warmup.first_tick_flow == "normalized":
+                flow *= 1.0 - _OFI_DECAY
+        if warmup.seed_from_first_n > 0:
+            ticks, total = self._ofi_warmup.get(current.symbol, (0, 0.0))
+            if ticks < warmup.seed_from_first_n:
+                ticks, total = ticks + 1, total + flow
+                self._ofi_warmup[current.symbol] = (ticks, total)
+                return (total / ticks) / (1.0 - _OFI_DECAY)
+
+        if previous is None:
+            return warmup.ofi_initial + flow
+        return previous.order_flow_imbalance * _OFI_DECAY + flow
 
     def _simulate_order(
         self,
@@ -612,8 +1671,22 @@ class PaperBroker:
         if order.order_type == "market":
             slippage_bps = self._compute_slippage_bps(snapshot, order_side)
             price = self._apply_slippage(snapshot, order_side, slippage_bps)
+            quantity = order.quantity
+            rejected_qty = self._sample_partial_reject(quantity)
+            if rejected_qty > 0:
+                quantity -= rejected_qty
+                self._partial_rejects[order.client_id] = rejected_qty
+                self._order_progress[order.client_id] = max(
+                    self._order_progress.get(order.client_id, order.quantity)
+                    - rejected_qty,
+                    0.0,
+                )
             return self._plan_fills(
-                order.quantity, price, maker=False, slippage_bps=slippage_bps
+                order.symbol,
+                quantity,
+                price,
+                maker=False,
+                slippage_bps=slippage_bps,
             )
 
         if order.order_type == "limit":
@@ -621,10 +1694,23 @@ class PaperBroker:
                 raise ValueError("limit order missing price")
 
             if self._limit_crosses_spread(order_side, order.price, snapshot):
+                # Fills at the touch like a market order, so a limit priced
+                # through the book gets the better price, but never worse
+                # than the limit itself.
                 slippage_bps = self._compute_slippage_bps(snapshot, order_side)
                 price = self._apply_slippage(snapshot, order_side, slippage_bps)
+                if (order_side == "buy" and price > order.price) or (
+                    order_side == "sell" and price < order.price
+                ):
+                    price = order.price
+                    ref_price = self._reference_price(snapshot, order_side)
+                    slippage_bps = max(abs(price - ref_price) / ref_price * 10_000, 0.0)
                 return self._plan_fills(
-                    order.quantity, price, maker=False, slippage_bps=slippage_bps
+                    order.symbol,
+                    order.quantity,
+                    price,
+                    maker=False,
+                    slippage_bps=slippage_bps,
                 )
 
             # Resting on the book as maker
@@ -632,6 +1718,56 @@ class PaperBroker:
 
         return []
 
+    def _open_order_counts(self) -> Dict[str, int]:
+        return {
+            symbol: len(rest_list)
+            for symbol, rest_list in self._resting_limits.items()
+            if rest_list
+        }
+
+    def _check_open_order_limits(self, symbol: str) -> None:
+        """Reject a new resting order that would exceed the open-order caps."""
+        counts = self._open_order_counts()
+        per_symbol = self.config.max_open_orders_per_symbol
+        total = self.config.max_open_orders
+        if (per_symbol is not None and counts.get(symbol, 0) >= per_symbol) or (
+            total is not None and sum(counts.values()) >= total
+        ):
+            logging.getLogger(__name__).warning(
+                "Order rejected: %s has %d open orders (%d total)",
+                symbol,
+                counts.get(symbol, 0),
+                sum(counts.values()),
+            )
+            raise ValueError("max_open_orders")
+
+    def _check_price_band(
+        self, symbol: str, price: float, snapshot: MarketSnapshot
+    ) -> None:
+        """Reject a limit price further than ``price_band_bps`` from mid."""
+        band = self.config.price_band_bps
+        mid = snapshot.mid_price
+        if band is None or mid <= 0:
+            return
+        deviation_bps = abs(price - mid) / mid * 10_000
+        if deviation_bps > band:
+            logging.getLogger(__name__).warning(
+                "Order rejected: %s limit %.8f is %.1f bps from mid (price_band_bps=%.1f)",
+                symbol,
+                price,
+                deviation_bps,
+                band,
+            )
+            raise ValueError("price_out_of_band")
+
+    def _sample_partial_reject(self, quantity: float) -> float:
+        """Quantity to reject for a market order, or 0.0 for a full fill."""
+        rate = self.config.partial_reject_rate
+        if rate <= 0 or self._random.random() >= rate:
+            return 0.0
+        # Always fill something so the order is a partial, not a full, reject
+        return quantity * (1.0 - self._random.uniform(0.1, 0.9))
+
     def _limit_crosses_spread(
         self, side: Side, price: float, snapshot: MarketSnapshot
     ) -> bool:
@@ -648,40 +1784,60 @@ class PaperBroker:
         return False
 
     def _compute_slippage_bps(self, snapshot: MarketSnapshot, side: Side) -> float:
+        """Modelled slippage scaled by the side's coefficient, capped at max."""
+        _, max_bps = self._slippage_params(snapshot.symbol)
+        return min(self._raw_slippage_bps(snapshot, side), max_bps)
+
+    def _raw_slippage_bps(self, snapshot: MarketSnapshot, side: Side) -> float:
+        """Modelled slippage scaled by the side's coefficient, uncapped."""
         spread_term = snapshot.spread_bps * self.config.spread_slippage_coeff
         ofi = snapshot.order_flow_imbalance
         adverse_flow = max(0.0, -ofi) if side == "buy" else max(0.0, ofi)
         # normalise adverse flow to bps using total depth
         depth = max(snapshot.bid_size + snapshot.ask_size, 1.0)
         adverse_bps = (adverse_flow / depth) * 10_000
-        slippage = (
-            self.config.slippage_bps
+        base_bps, _ = self._slippage_params(snapshot.symbol)
+        side_coeff = (
+            self.config.buy_slippage_coeff
+            if side == "buy"
+            else self.config.sell_slippage_coeff
+        )
+        return side_coeff * (
+            base_bps
             + spread_term
             + adverse_bps * self.config.ofi_slippage_coeff
         )
-        return min(slippage, self.config.max_slippage_bps)
+
+    def _reference_price(self, snapshot: MarketSnapshot, side: Side) -> float:
+        """Taker reference per ``market_ref_price``: the touch or the mid.
+
+        Falls back to mid, then last trade, when the preferred quote is absent.
+        """
+        if self.config.market_ref_price == "touch":
+            touch = snapshot.best_ask if side == "buy" else snapshot.best_bid
+            if touch > 0:
+                return touch
+        return snapshot.mid_price
 
     def _apply_slippage(
         self, snapshot: MarketSnapshot, side: Side, slippage_bps: float
     ) -> float:
-        base_price = (
-            snapshot.best_ask
-            if side == "buy" and snapshot.best_ask > 0
-            else snapshot.best_bid
-        )
-        if base_price <= 0:
-            base_price = snapshot.mid_price
-        if base_price <= 0:
-            base_price = snapshot.last_price
-        if base_price <= 0:
+        """Taker fill price, slipped away from the reference price.
+
+        ``fill = ref * (1 + s / 10_000)`` for buys and ``ref * (1 - s / 10_000)``
+        for sells, where ``ref`` is :meth:`_reference_price` and ``s`` the
+        capped slippage from :meth:`_compute_slippage_bps`.
+        """
+        ref_price = self._reference_price(snapshot, side)
+        if ref_price <= 0:
             raise RuntimeError(
                 "Unable to determine base price for slippage computation"
             )
 
         multiplier = slippage_bps / 10_000
         if side == "buy":
-            return base_price * (1 + multiplier)
-        return base_price * (1 - multiplier)
+            return ref_price * (1 + multiplier)
+        return ref_price * (1 - multiplier)
 
     def _build_partial_fill_plan(self, quantity: float) -> List[float]:
         if not self.config.partial_fill.enabled or quantity <= 0:
@@ -719,23 +1875,51 @@ class PaperBroker:
 
     def _plan_fills(
         self,
+        symbol: str,
         quantity: float,
         price: float,
         *,
         maker: bool,
         slippage_bps: float,
     ) -> List[Tuple[float, float, float, bool, float]]:
+        partial = self.config.partial_fill
         return [
             (
-                self._sample_latency_ms(),
+                self._sample_latency_ms(symbol) * partial.slice_delay_factor(index),
                 fill_qty,
                 price,
                 maker,
                 slippage_bps,
             )
-            for fill_qty in self._build_partial_fill_plan(quantity)
+            for index, fill_qty in enumerate(
+                self._merge_dust_slices(self._build_partial_fill_plan(quantity), price)
+            )
         ]
 
+    def _merge_dust_slices(self, slices: List[float], price: float) -> List[float]:
+        """Fold slices below ``partial_fill.min_fill_notional`` into the next.
+
+        A short tail is folded back into the last kept slice, so the total
+        quantity is unchanged and only a single-slice plan can stay below
+        the minimum.
+        """
+        minimum = self.config.partial_fill.min_fill_notional
+        if minimum is None or len(slices) < 2 or price <= 0:
+            return slices
+        merged: List[float] = []
+        pending = 0.0
+        for qty in slices:
+            pending += qty
+            if pending * price >= minimum:
+                merged.append(pending)
+                pending = 0.0
+        if pending > 0:
+            if merged:
+                merged[-1] += pending
+            else:
+                merged.append(pending)
+        return merged
+
     async def _finalise_fill(
         self,
         *,
@@ -747,6 +1931,7 @@ class PaperBroker:
         slippage_bps: float,
         delay_ms: float,
         reduce_only: bool,
+        epoch: Optional[int] = None,
     ) -> None:
         try:
             await self._finalise_fill_inner(
@@ -758,6 +1943,7 @@ class PaperBroker:
                 slippage_bps=slippage_bps,
                 delay_ms=delay_ms,
                 reduce_only=reduce_only,
+                epoch=epoch,
             )
         except Exception:
             logger = logging.getLogger(__name__)
@@ -777,28 +1963,35 @@ class PaperBroker:
         slippage_bps: float,
         delay_ms: float,
         reduce_only: bool,
+        epoch: Optional[int] = None,
     ) -> None:
-        await self._sleep(delay_ms)
-
-        fee_rate_bps = self.config.maker_rebate_bps if maker else self.config.fee_bps
-        fee_amount = fill_price * fill_qty * fee_rate_bps / 10_000
+        prevailing = self._market_state.get(order.symbol, snapshot)
+        fee_amount = self._compute_fee(
+            fill_price, fill_qty, maker, spread_bps=prevailing.spread_bps
+        )
 
         execution_report: Optional[Dict[str, Any]] = None
+        reject_report: Optional[Dict[str, Any]] = None
 
         async with self._lock:
+            if epoch is not None and epoch != self._fill_epoch:
+                # Scheduled before a reset(); the book it belonged to is gone
+                return
             try:
                 position_state = self._positions.setdefault(
                     order.symbol, _PositionState(symbol=order.symbol)
                 )
 
+                previous_size = position_state.size
                 realized_pnl, updated_size, updated_price = self._apply_position_fill(
                     position_state, cast(Side, order.side), fill_qty, fill_price
                 )
                 position_state.size = updated_size
                 position_state.avg_price = updated_price
 
-                mark_price = snapshot.mid_price
+                mark_price = self._mark_price(snapshot)
                 position_state.update_mark(mark_price)
+                self._update_notional_gauges()
                 if not reduce_only:
                     self._enforce_liquidation_buffer(
                         position_state,
@@ -806,7 +1999,20 @@ class PaperBroker:
                         side=cast(Side, order.side),
                     )
 
-                funding = self._compute_funding(fill_price, fill_qty, snapshot)
+                funding = self._compute_funding(
+                    position_state,
+                    fill_price,
+                    fill_qty,
+                    snapshot,
+                    previous_size=previous_size,
+                    now=prevailing.timestamp,
+                )
+                if funding:
+                    FUNDING_TOTAL.labels(
+                        mode=self.mode,
+                        symbol=order.symbol,
+                        direction="paid" if funding > 0 else "received",
+                    ).inc(abs(funding))
                 net_cash = realized_pnl - fee_amount - funding
                 self._balance += net_cash
 
@@ -817,9 +2023,13 @@ class PaperBroker:
                         direction * (mark_price - fill_price) / mark_price * 10_000
                     )
 
+                shortfall_bps = self._shortfall_bps(order, fill_price)
+
+                fill_id = self._next_fill_id(order)
+                filled_at = self._fill_timestamp(snapshot, delay_ms)
                 trade = Trade(
                     client_id=f"{order.client_id}-{uuid.uuid4().hex[:6]}",
-                    trade_id=f"{order.order_id}-{uuid.uuid4().hex[:6]}",
+                    trade_id=fill_id,
                     order_id=order.order_id or order.client_id,
                     symbol=order.symbol,
                     side=order.side,
@@ -836,10 +2046,11 @@ class PaperBroker:
                     maker=maker,
                     mode=self.mode,
                     run_id=self.run_id,
-                    timestamp=self._time_provider(),
+                    timestamp=filled_at,
                     is_shadow=order.is_shadow,
                 )
                 await self.database.create_trade(trade)
+                self._journal_fill(order.symbol, cast(Side, order.side), fill_qty)
 
                 await self.database.add_pnl_entry(
                     PnLEntry(
@@ -854,7 +2065,7 @@ class PaperBroker:
                         balance=self._balance,
                         mode=self.mode,
                         run_id=self.run_id,
-                        timestamp=trade.timestamp,
+                        timestamp=filled_at,
                     )
                 )
 
@@ -863,18 +2074,33 @@ class PaperBroker:
                 )
                 self._order_progress[order.client_id] = remaining
                 status = "filled" if remaining <= 1e-8 else "partially_filled"
+                rejected_qty = (
+                    self._partial_rejects.pop(order.client_id, 0.0)
+                    if status == "filled"
+                    else 0.0
+                )
                 await self.database.update_order_status(
                     order_id=order.order_id or order.client_id,
-                    status=status,
+                    status="canceled" if rejected_qty > 0 else status,
                     is_shadow=order.is_shadow,
                 )
                 if status == "filled":
                     self._order_progress.pop(order.client_id, None)
+                    self._update_in_flight_orders()
 
-                SIGNAL_ACK_LATENCY.labels(mode=self.mode).observe(delay_ms / 1000.0)
+                metric_symbol = bounded_symbol(order.symbol, self._metric_symbols)
+                SIGNAL_ACK_LATENCY.labels(mode=self.mode, symbol=metric_symbol).observe(
+                    delay_ms / 1000.0
+                )
+                SLIPPAGE_BPS.labels(mode=self.mode, symbol=metric_symbol).observe(
+                    slippage_bps
+                )
                 AVERAGE_SLIPPAGE_BPS.labels(mode=self.mode, symbol=order.symbol).set(
                     slippage_bps
                 )
+                IMPLEMENTATION_SHORTFALL_BPS.labels(
+                    mode=self.mode, symbol=order.symbol
+                ).set(shortfall_bps)
                 if maker:
                     self._maker_fills += 1
                     self._maker_fills_by_symbol[order.symbol] += 1
@@ -892,6 +2118,7 @@ class PaperBroker:
 
                 execution_report = {
                     "order_id": order.order_id or order.client_id,
+                    "fill_id": fill_id,
                     "client_id": order.client_id,
                     "symbol": order.symbol,
                     "executed": True,
@@ -901,27 +2128,53 @@ class PaperBroker:
                     "fees": fee_amount,
                     "funding": funding,
                     "realized_pnl": realized_pnl,
+                    "balance": self._balance,
+                    "equity": self._equity(),
                     "slippage_bps": slippage_bps,
                     "achieved_vs_signal_bps": achieved_vs_signal,
+                    "arrival_mid": order.arrival_mid,
+                    "shortfall_bps": shortfall_bps,
                     "maker": maker,
                     "latency_ms": delay_ms,
                     "ack_latency_ms": delay_ms,
                     "mode": self.mode,
                     "run_id": self.run_id,
-                    "timestamp": self._time_provider().isoformat(),
+                    "timestamp": filled_at.isoformat(),
                     "is_shadow": order.is_shadow,
                     "error": "",
+                    "error_code": "",
                     "reduce_only": reduce_only,
                     "order_type": order.order_type,
                     "stop_price": order.stop_price,
                     "initial_price": order.price,
+                    "status": status,
                 }
+                if rejected_qty > 0:
+                    reject_report = {
+                        **execution_report,
+                        "fill_id": self._next_fill_id(order),
+                        "executed": False,
+                        "price": None,
+                        "quantity": 0.0,
+                        "rejected_qty": rejected_qty,
+                        "fees": 0.0,
+                        "funding": 0.0,
+                        "realized_pnl": 0.0,
+                        "slippage_bps": 0.0,
+                        "achieved_vs_signal_bps": 0.0,
+                        "shortfall_bps": 0.0,
+                        "error": "partial_reject",
+                        "error_code": error_code("partial_reject"),
+                        "reason": "partial_reject",
+                    }
             except RuntimeError as exc:
                 logger = logging.getLogger(__name__)
                 logger.warning(
                     "Order %s rejected by liquidation guard: %s", order.client_id, exc
                 )
                 self._order_progress.pop(order.client_id, None)
+                self._update_in_flight_orders()
+                self._partial_rejects.pop(order.client_id, None)
                 await self.database.update_order_status(
                     order_id=order.order_id or order.client_id,
                     status="rejected",
@@ -929,82 +2182,374 @@ class PaperBroker:
                 )
                 execution_report = {
                     "order_id": order.order_id or order.client_id,
+                    "fill_id": self._next_fill_id(order),
                     "client_id": order.client_id,
                     "symbol": order.symbol,
                     "executed": False,
                     "price": None,
-                    "mark_price": snapshot.mid_price,
+                    "mark_price": self._mark_price(snapshot),
                     "quantity": 0.0,
                     "fees": 0.0,
                     "funding": 0.0,
                     "realized_pnl": 0.0,
                     "slippage_bps": 0.0,
                     "achieved_vs_signal_bps": 0.0,
+                    "arrival_mid": order.arrival_mid,
+                    "shortfall_bps": 0.0,
                     "maker": False,
                     "latency_ms": delay_ms,
                     "ack_latency_ms": delay_ms,
                     "mode": self.mode,
                     "run_id": self.run_id,
-                    "timestamp": self._time_provider().isoformat(),
+                    "timestamp": self._fill_timestamp(snapshot, delay_ms).isoformat(),
                     "is_shadow": order.is_shadow,
                     "error": str(exc),
+                    "error_code": error_code("liquidation_guard"),
+                    "reason": "liquidation_guard",
                     "reduce_only": reduce_only,
                     "order_type": order.order_type,
                     "stop_price": order.stop_price,
                     "initial_price": order.price,
                 }
 
-        if execution_report and self._execution_listener:
-            try:
-                await self._execution_listener(execution_report)
-            except Exception:
-                logger = logging.getLogger(__name__)
-                logger.exception("Execution listener failed")
+        for report in (execution_report, reject_report):
+            if report:
+                await self._emit_report(report)
 
     async def _execute_stop(self, stop: _StopOrder, snapshot: MarketSnapshot) -> None:
         market_order = stop.order
-        await self.place_order(
-            symbol=market_order.symbol,
-            side=cast(Side, market_order.side),
-            order_type="market",
-            quantity=market_order.quantity,
-            reduce_only=stop.reduce_only,
-            is_shadow=market_order.is_shadow,
-            client_id=market_order.client_id,
+        try:
+            await self.place_order(
+                symbol=market_order.symbol,
+                side=cast(Side, market_order.side),
+                order_type="market",
+                quantity=market_order.quantity,
+                reduce_only=stop.reduce_only,
+                is_shadow=market_order.is_shadow,
+                client_id=market_order.client_id,
+            )
+        except ValueError as exc:
+            # Rejected on trigger (e.g. max_slippage_exceeded): report it
+            # instead of failing the market update that fired the stop
+            self._order_progress.pop(market_order.client_id, None)
+            self._update_in_flight_orders()
+            await self._report_unfilled(
+                market_order,
+                remaining_qty=market_order.quantity,
+                status="rejected",
+                error=str(exc),
+                reduce_only=stop.reduce_only,
+                snapshot=snapshot,
+            )
+
+    def _observe_rest_age(
+        self, rest: _RestingOrder, reason: str, now: Optional[datetime] = None
+    ) -> None:
+        """Record how long ``rest`` sat on the book, in market-data time."""
+        if rest.rested_at is None:
+            return
+        if now is None:
+            snapshot = self._market_state.get(rest.order.symbol)
+            now = _as_utc(snapshot.timestamp) if snapshot else self._time_provider()
+        age = (now - _as_utc(rest.rested_at)).total_seconds()
+        RESTING_ORDER_AGE.labels(mode=self.mode, reason=reason).observe(max(age, 0.0))
+
+    async def _expire_resting_limit(
+        self, rest: _RestingOrder, snapshot: MarketSnapshot
+    ) -> None:
+        self._order_progress.pop(rest.order.client_id, None)
+        self._update_in_flight_orders()
+        await self._report_unfilled(
+            rest.order,
+            remaining_qty=rest.remaining_qty,
+            status="expired",
+            error="expired",
+            reduce_only=rest.reduce_only,
+            snapshot=snapshot,
+        )
+
+    async def _report_unfilled(
+        self,
+        order: Order,
+        *,
+        remaining_qty: float,
+        status: str,
+        error: str,
+        reduce_only: bool,
+        snapshot: Optional[MarketSnapshot],
+    ) -> None:
+        """Persist ``status`` for an order leaving the book unfilled and report it."""
+        await self.database.update_order_status(
+            order_id=order.order_id or order.client_id,
+            status=status,
+            is_shadow=order.is_shadow,
+        )
+        timestamp = (
+            _as_utc(snapshot.timestamp) if snapshot else self._time_provider()
+        )
+        await self._emit_report(
+            {
+                "order_id": order.order_id or order.client_id,
+                "fill_id": self._next_fill_id(order),
+                "client_id": order.client_id,
+                "symbol": order.symbol,
+                "executed": False,
+                "price": None,
+                "mark_price": self._mark_price(snapshot) if snapshot else None,
+                "quantity": 0.0,
+                "remaining_qty": remaining_qty,
+                "mode": self.mode,
+                "run_id": self.run_id,
+                "timestamp": timestamp.isoformat(),
+                "is_shadow": order.is_shadow,
+                "error": error,
+                "error_code": error_code(error),
+                "reduce_only": reduce_only,
+                "order_type": order.order_type,
+                "stop_price": order.stop_price,
+                "initial_price": order.price,
+            }
         )
 
+    def _journal_fill(self, symbol: str, side: Side, quantity: float) -> None:
+        if len(self._fill_journal) >= self.config.fill_journal_size:
+            old_symbol, old_qty = self._fill_journal.popleft()
+            self._journal_baseline[old_symbol] += old_qty
+        self._fill_journal.append((symbol, quantity if side == "buy" else -quantity))
+
+    async def reconcile_positions(self) -> List[Dict[str, Any]]:
+        """Compare each position with the sum of its journalled fills.
+
+        Returns one entry per symbol whose live size differs from the size
+        rebuilt from the fill journal; an empty list means the books agree.
+        """
+        async with self._lock:
+            expected: Dict[str, float] = defaultdict(float, self._journal_baseline)
+            for symbol, signed_qty in self._fill_journal:
+                expected[symbol] += signed_qty
+            discrepancies = []
+            for symbol in sorted(set(expected) | set(self._positions)):
+                state = self._positions.get(symbol)
+                live = state.size if state else 0.0
+                if not math.isclose(live, expected[symbol], abs_tol=1e-9):
+                    discrepancies.append(
+                        {
+                            "symbol": symbol,
+                            "position_size": live,
+                            "journal_size": expected[symbol],
+                            "difference": live - expected[symbol],
+                        }
+                    )
+        return discrepancies
+
+    def _fill_timestamp(self, snapshot: MarketSnapshot, delay_ms: float) -> datetime:
+        """Timestamp for a fill planned against ``snapshot`` after ``delay_ms``.
+
+        In market-time mode it is the snapshot's data time plus the simulated
+        latency, so replayed fills line up with the replayed market instead
+        of with when the replay happened to run.
+        """
+        source = self.config.fill_timestamp_source
+        if source == "auto":
+            source = "market" if self.mode in ("replay", "backtest") else "wall"
+        if source == "market":
+            return _as_utc(snapshot.timestamp) + timedelta(milliseconds=delay_ms)
+        return self._time_provider()
+
+    @staticmethod
+    def _shortfall_bps(order: Order, fill_price: float) -> float:
+        """Implementation shortfall: fill vs arrival mid in bps, positive is a cost.
+
+        ``side * (fill_price - arrival_mid) / arrival_mid * 10_000``, so a buy
+        above or a sell below the arrival mid both come out positive.
+        """
+        if not order.arrival_mid:
+            return 0.0
+        direction = 1 if order.side == "buy" else -1
+        return direction * (fill_price - order.arrival_mid) / order.arrival_mid * 10_000
+
+    @staticmethod
+    def _next_fill_id(order: Order) -> str:
+        """``{order_id}-{seq}``: unique per report, grouped by ``order_id``."""
+        order.report_seq += 1
+        return f"{order.order_id or order.client_id}-{order.report_seq}"
+
+    async def _emit_report(self, report: Dict[str, Any]) -> None:
+        """Round ``report`` for display and hand it to waiters and the listener."""
+        report = self._round_report(report)
+        for waiter in self._report_waiters.pop(report.get("client_id", ""), []):
+            if not waiter.done():
+                waiter.set_result(report)
+        if not self._execution_listener:
+            return
+        try:
+            await self._execution_listener(report)
+        except Exception:
+            logging.getLogger(__name__).exception("Execution listener failed")
+
+    def _round_report(self, report: Dict[str, Any]) -> Dict[str, Any]:
+        """Round report figures to the symbol's precision; internals stay exact."""
+        override = self.config.per_symbol.get(report.get("symbol", ""))
+        if override is None:
+            return report
+        for precision, fields in (
+            (override.price_precision, _PRICE_REPORT_FIELDS),
+            (override.qty_precision, _QTY_REPORT_FIELDS),
+        ):
+            if precision is None:
+                continue
+            for field in fields:
+                value = report.get(field)
+                if isinstance(value, float):
+                    report[field] = round(value, precision)
+        return report
+
     async def _fill_resting_limit(
         self, rest: _RestingOrder, snapshot: MarketSnapshot
     ) -> None:
+        # The only maker path: a limit crossing on submission fills as taker
         price = rest.limit_price
         fills = self._plan_fills(
+            rest.order.symbol,
             rest.remaining_qty,
             price,
             maker=True,
             slippage_bps=0.0,
         )
         for delay_ms, qty, price, maker, slippage_bps in fills:
-            asyncio.create_task(
-                self._finalise_fill(
-                    order=rest.order,
-                    snapshot=snapshot,
-                    fill_qty=qty,
-                    fill_price=price,
-                    maker=maker,
-                    slippage_bps=slippage_bps,
-                    delay_ms=delay_ms,
-                    reduce_only=rest.reduce_only,
-                )
+            self._schedule_fill(
+                order=rest.order,
+                snapshot=snapshot,
+                fill_qty=qty,
+                fill_price=price,
+                maker=maker,
+                slippage_bps=slippage_bps,
+                delay_ms=delay_ms,
+                reduce_only=rest.reduce_only,
             )
 
     def _should_trigger_stop(self, stop: _StopOrder, snapshot: MarketSnapshot) -> bool:
-        mid = snapshot.mid_price
-        if mid <= 0:
+        """A stop fires once the trigger price reaches its level in its direction.
+
+        Buy stops trigger at or above ``stop_price`` and sell stops at or
+        below it; until then the order rests and cannot fill.
+        """
+        price = snapshot.last_price if self.config.stop_trigger_source == "last" else 0.0
+        if price <= 0:
+            price = snapshot.mid_price
+        if price <= 0:
             return False
         side = cast(Side, stop.order.side)
         if side == "sell":
-            return mid <= stop.stop_price
-        return mid >= stop.stop_price
+            return price <= stop.stop_price
+        return price >= stop.stop_price
+
+    def _book_side_empty(self, snapshot: MarketSnapshot, side: Side) -> bool:
+        """True when ``side`` would take from a zero-size book side and
+        ``zero_liquidity_policy`` says not to fill against it."""
+        if self.config.zero_liquidity_policy == "fill":
+            return False
+        return (snapshot.ask_size if side == "buy" else snapshot.bid_size) <= 0
+
+    def _takes_liquidity(
+        self,
+        side: Side,
+        order_type: OrderType,
+        price: Optional[float],
+        snapshot: MarketSnapshot,
+    ) -> bool:
+        return order_type == "market" or (
+            order_type == "limit"
+            and price is not None
+            and self._limit_crosses_spread(side, price, snapshot)
+        )
+
+    def _lacks_liquidity(
+        self,
+        side: Side,
+        order_type: OrderType,
+        price: Optional[float],
+        snapshot: MarketSnapshot,
+    ) -> bool:
+        return self._takes_liquidity(
+            side, order_type, price, snapshot
+        ) and self._book_side_empty(snapshot, side)
+
+    def _reduce_only_quantity(self, symbol: str, side: Side, quantity: float) -> float:
+        """``quantity`` limited to what a reduce-only order may close.
+
+        An order with no opposing position to reduce is always rejected; one
+        larger than the position is clamped to it unless
+        ``reduce_only_overflow="reject"``.
+        """
+        state = self._positions.get(symbol)
+        size = state.size if state else 0.0
+        reducible = max(0.0, -size if side == "buy" else size)
+        if quantity <= reducible:
+            return quantity
+        if reducible <= 0 or self.config.reduce_only_overflow == "reject":
+            logging.getLogger(__name__).warning(
+                "Order rejected: reduce-only %s %s qty=%.8f exceeds position %.8f",
+                symbol,
+                side,
+                quantity,
+                reducible,
+            )
+            raise ValueError("reduce_only_exceeds_position")
+        logging.getLogger(__name__).info(
+            "Reduce-only %s %s qty=%.8f clamped to position %.8f",
+            symbol,
+            side,
+            quantity,
+            reducible,
+        )
+        return reducible
+
+    def _check_flip(self, symbol: str, side: Side, quantity: float) -> None:
+        """Under ``no_flip``, reject an order larger than the position it closes."""
+        if not self.config.no_flip:
+            return
+        state = self._positions.get(symbol)
+        size = state.size if state else 0.0
+        closing = -size if side == "buy" else size
+        if closing > 0 and quantity > closing + 1e-12:
+            logging.getLogger(__name__).warning(
+                "Order rejected: %s %s qty=%.8f would flip position %.8f",
+                symbol,
+                side,
+                quantity,
+                size,
+            )
+            raise ValueError("would_flip_position")
+
+    def _check_slippage_band(
+        self,
+        side: Side,
+        order_type: OrderType,
+        price: Optional[float],
+        snapshot: MarketSnapshot,
+    ) -> None:
+        """Reject a taker whose modelled slippage overflows ``max_slippage_bps``.
+
+        Only under ``slippage_overflow="reject"``; otherwise the slippage is
+        clamped when the fill is planned.
+        """
+        if self.config.slippage_overflow != "reject":
+            return
+        if not self._takes_liquidity(side, order_type, price, snapshot):
+            return
+        _, max_bps = self._slippage_params(snapshot.symbol)
+        slippage = self._raw_slippage_bps(snapshot, side)
+        if slippage > max_bps:
+            SLIPPAGE_REJECTS.labels(mode=self.mode, symbol=snapshot.symbol).inc()
+            logging.getLogger(__name__).warning(
+                "Order rejected: %s %s slippage %.2f bps exceeds max_slippage_bps=%.2f",
+                snapshot.symbol,
+                side,
+                slippage,
+                max_bps,
+            )
+            raise ValueError("max_slippage_exceeded")
 
     def _limit_crossed(self, rest: _RestingOrder, snapshot: MarketSnapshot) -> bool:
         side = cast(Side, rest.order.side)
@@ -1056,6 +2601,26 @@ class PaperBroker:
 
         return realized, new_size, new_avg
 
+    def _compute_fee(
+        self,
+        price: float,
+        quantity: float,
+        maker: bool,
+        *,
+        spread_bps: Optional[float] = None,
+    ) -> float:
+        fee_rate_bps = self.config.maker_rebate_bps if maker else self.config.fee_bps
+        gate = self.config.min_maker_spread_bps
+        if maker and gate is not None and spread_bps is not None and spread_bps <= gate:
+            # Spread too tight to earn the rebate; a positive maker fee still applies
+            fee_rate_bps = max(fee_rate_bps, 0.0)
+        fee = price * quantity * fee_rate_bps / 10_000
+        # The floor is a minimum charge: rebates and zero fees pass through
+        floor = self.config.min_commission
+        if 0 < fee < floor:
+            fee = floor
+        return fee
+
     def _position_return_pct(self, position: _PositionState) -> float:
         if position.size == 0 or position.avg_price == 0:
             return 0.0
@@ -1064,14 +2629,55 @@ class PaperBroker:
         ) * 100
 
     def _compute_funding(
-        self, price: float, quantity: float, snapshot: MarketSnapshot
+        self,
+        state: _PositionState,
+        price: float,
+        quantity: float,
+        snapshot: MarketSnapshot,
+        *,
+        previous_size: float,
+        now: datetime,
     ) -> float:
-        if not self.config.funding_enabled or snapshot.funding_rate == 0:
-            return 0.0
-        notional = price * quantity
-        # funding applied on hourly basis relative to snapshot timestamp
-        hours = 1.0
-        return notional * snapshot.funding_rate * hours
+        """Funding for one fill; positive is paid, negative received.
+
+        A positive rate has longs pay shorts.  Size the fill opens or adds
+        prepays an hour at the side held after the fill and is kept as a
+        funding lot.  Size it closes consumes lots oldest first and accrues
+        nothing, unless ``funding_proration`` is ``"prorate"``: each lot then
+        refunds the part of its hour it was not held for, at the rate and
+        price it prepaid.  Size without a lot (seeded positions) refunds none.
+        """
+        position_size = state.size
+        closed = 0.0
+        if previous_size and (position_size - previous_size) * previous_size < 0:
+            closed = min(quantity, abs(previous_size))
+        opened = quantity - closed
+
+        funding = 0.0
+        prorate = self.config.funding_proration == "prorate"
+        direction = 1 if previous_size > 0 else -1
+        while closed > 1e-12 and state.funding_lots:
+            lot = state.funding_lots[0]
+            used = min(closed, lot.quantity)
+            if prorate:
+                held_hours = (now - lot.opened_at).total_seconds() / 3600
+                unheld = min(1.0, max(0.0, 1.0 - held_hours))
+                funding -= direction * lot.price * used * lot.rate * unheld
+            lot.quantity -= used
+            closed -= used
+            if lot.quantity <= 1e-12:
+                state.funding_lots.popleft()
+        if position_size == 0 or position_size * previous_size < 0:
+            state.funding_lots.clear()
+
+        if opened > 0 and position_size != 0:
+            rate = snapshot.funding_rate if self.config.funding_enabled else 0.0
+            direction = 1 if position_size > 0 else -1
+            # funding applied on hourly basis relative to snapshot timestamp
+            hours = 1.0
+            funding += direction * price * opened * rate * hours
+            state.funding_lots.append(_FundingLot(opened, price, rate, now))
+        return funding
 
     def _derive_stop_distance(
         self, avg_price: float, stop_price: Optional[float], direction: int
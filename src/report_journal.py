@@ -0,0 +1,143 @@
+"""
+Parquet journal of execution reports for post-run analysis.
+
+Reports are buffered in memory and appended to ``<directory>/<run_id>.parquet``
+as one row group per flush.  The file's footer is only written on
+:meth:`ParquetReportJournal.close`, so the journal must be closed on shutdown
+for the file to be readable.  :meth:`ParquetReportJournal.flush` and
+:meth:`ParquetReportJournal.close` may run in a worker thread while reports
+are appended on the event loop: the buffer is swapped under its own lock, so
+an append lands either in the batch being written or in the next one, and
+never waits on the Parquet write itself.
+"""
+
+from __future__ import annotations
+
+import logging
+import threading
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Report fields written as columns, with their Arrow type names; anything a
+# report lacks is null and anything else it carries is dropped.
+REPORT_COLUMNS = (
+    ("run_id", "string"),
+    ("mode", "string"),
+    ("timestamp", "timestamp"),
+    ("order_id", "string"),
+    ("fill_id", "string"),
+    ("client_id", "string"),
+    ("symbol", "string"),
+    ("side", "string"),
+    ("order_type", "string"),
+    ("executed", "bool"),
+    ("price", "float64"),
+    ("mark_price", "float64"),
+    ("quantity", "float64"),
+    ("remaining_qty", "float64"),
+    ("rejected_qty", "float64"),
+    ("fees", "float64"),
+    ("funding", "float64"),
+    ("realized_pnl", "float64"),
+    ("balance", "float64"),
+    ("equity", "float64"),
+    ("slippage_bps", "float64"),
+    ("shortfall_bps", "float64"),
+    ("maker", "bool"),
+    ("latency_ms", "float64"),
+    ("is_shadow", "bool"),
+    ("reduce_only", "bool"),
+    ("error", "string"),
+    ("error_code", "string"),
+    ("reason", "string"),
+)
+
+
+def _schema() -> Any:
+    import pyarrow as pa
+
+    types = {
+        "string": pa.string(),
+        "bool": pa.bool_(),
+        "float64": pa.float64(),
+        "timestamp": pa.timestamp("us", tz="UTC"),
+    }
+    return pa.schema([(name, types[kind]) for name, kind in REPORT_COLUMNS])
+
+
+def _row(report: Dict[str, Any]) -> Dict[str, Any]:
+    row: Dict[str, Any] = {}
+    for name, kind in REPORT_COLUMNS:
+        value = report.get(name)
+        if value is None:
+            row[name] = None
+        elif kind == "timestamp":
+            row[name] = _parse_timestamp(value)
+        elif kind == "float64":
+            row[name] = float(value)
+        elif kind == "bool":
+            row[name] = bool(value)
+        else:
+            row[name] = str(value)
+    return row
+
+
+def _parse_timestamp(value: Any) -> Optional[datetime]:
+    try:
+        parsed = datetime.fromisoformat(str(value).replace("Z", "+00:00"))
+    except ValueError:
+        return None
+    return parsed if parsed.tzinfo else parsed.replace(tzinfo=timezone.utc)
+
+
+class ParquetReportJournal:
+    """Buffered writer of one run's execution reports to a Parquet file."""
+
+    def __init__(self, directory: str, run_id: str) -> None:
+        self.path = Path(directory) / f"{run_id}.parquet"
+        self._rows: List[Dict[str, Any]] = []
+        self._writer: Any = None
+        # Guards the buffer swap; _write_lock serialises writer access
+        self._rows_lock = threading.Lock()
+        self._write_lock = threading.Lock()
+
+    def append(self, report: Dict[str, Any]) -> None:
+        try:
+            row = _row(report)
+        except (TypeError, ValueError) as exc:
+            logger.warning("Report not journalled (%s): %s", exc, report)
+            return
+        with self._rows_lock:
+            self._rows.append(row)
+
+    def flush(self) -> int:
+        """Write buffered reports as a row group; return how many were written."""
+        with self._write_lock:
+            return self._flush_locked()
+
+    def close(self) -> None:
+        """Flush what is buffered and finalise the file."""
+        with self._write_lock:
+            self._flush_locked()
+            if self._writer is not None:
+                self._writer.close()
+                self._writer = None
+                logger.info("Execution reports journalled to %s", self.path)
+
+    def _flush_locked(self) -> int:
+        with self._rows_lock:
+            rows, self._rows = self._rows, []
+        if not rows:
+            return 0
+        import pyarrow as pa
+        import pyarrow.parquet as pq
+
+        schema = _schema()
+        if self._writer is None:
+            self.path.parent.mkdir(parents=True, exist_ok=True)
+            self._writer = pq.ParquetWriter(self.path, schema)
+        self._writer.write_table(pa.Table.from_pylist(rows, schema=schema))
+        return len(rows)
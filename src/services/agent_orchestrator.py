@@ -38,8 +38,8 @@ from ..database import (
     TradeAttribution,
 )
 from ..llm_client import LLMClient, LLMError
-from ..messaging import MessagingClient
-from .base import BaseService, create_app
+from ..messaging import MessagingClient, decode_payload
+from .base import BaseService, create_app, run_service
 
 logger = logging.getLogger(__name__)
 
@@ -1275,7 +1275,7 @@ class AgentRunner:
     async def handle_market_data(self, msg: Any) -> None:
         """NATS callback: update internal market cache."""
         try:
-            data = json.loads(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
+            data = decode_payload(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
             symbol = data.get("symbol")
             if symbol:
                 # Normalize keys for strategy consumption
@@ -1292,7 +1292,7 @@ class AgentRunner:
     async def handle_execution_report(self, msg: Any) -> None:
         """NATS callback: process fill reports for this agent."""
         try:
-            data = json.loads(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
+            data = decode_payload(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
             if data.get("agent_id") == self.agent_id:
                 self._recent_fills.append(data)
                 logger.info(
@@ -1444,7 +1444,12 @@ class AgentOrchestratorService(BaseService):
         await self.database.initialize()
 
         # Messaging (NATS)
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
         # LLM client
@@ -1452,7 +1457,7 @@ class AgentOrchestratorService(BaseService):
 
         # Subscribe to market data and execution reports
         sub_market = await self.messaging.subscribe(
-            "market.data", self._on_market_data
+            self.config.messaging.market_data_subscription(), self._on_market_data
         )
         sub_exec = await self.messaging.subscribe(
             "trading.executions", self._on_execution_report
@@ -1730,7 +1735,7 @@ class AgentOrchestratorService(BaseService):
     async def _on_agent_command(self, msg: Any) -> None:
         """Handle agent lifecycle commands from the API server."""
         try:
-            data = json.loads(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
+            data = decode_payload(msg.data) if isinstance(msg.data, (bytes, str)) else msg.data
             command = data.get("command")
             agent_id = data.get("agent_id")
 
@@ -1758,3 +1763,7 @@ class AgentOrchestratorService(BaseService):
 # ---------------------------------------------------------------------------
 service = AgentOrchestratorService()
 app: FastAPI = create_app(service)
+
+
+if __name__ == "__main__":
+    run_service(app, key="orchestrator", default_port=8088)
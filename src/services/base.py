@@ -9,18 +9,46 @@ from __future__ import annotations
 
 import asyncio
 import logging
+import sys
+import threading
+import time
+import traceback
+import tracemalloc
 from abc import ABC, abstractmethod
+from collections import Counter
 from contextlib import asynccontextmanager
+from datetime import datetime, timezone
+from typing import Any, Optional
 
+import uvicorn
 from fastapi import FastAPI
-from fastapi.responses import JSONResponse, Response
+from fastapi.responses import JSONResponse, PlainTextResponse, Response
 from prometheus_client import CONTENT_TYPE_LATEST, generate_latest
 
-from ..logging_config import CorrelationIdMiddleware, setup_logging
-from ..metrics import TRADING_MODE
+from ..config import load_config
+from ..logging_config import CorrelationIdMiddleware, service_name_var, setup_logging
+from ..messaging import decode_payload
+from ..metrics import STALE_MESSAGES_DROPPED, TRADING_MODE, register_build_info
 
 logger = logging.getLogger(__name__)
 
+# Root handlers are process-wide; only the first app created in a process
+# installs them.  Each app tags its own records via ``service_name_var``.
+_logging_configured = False
+
+
+def _payload_timestamp(value: Any) -> Optional[datetime]:
+    """Parse an ISO-8601 string or epoch seconds into an aware UTC datetime."""
+    try:
+        if isinstance(value, (int, float)):
+            return datetime.fromtimestamp(float(value), tz=timezone.utc)
+        if isinstance(value, str):
+            parsed = datetime.fromisoformat(value.replace("Z", "+00:00"))
+            return parsed if parsed.tzinfo else parsed.replace(tzinfo=timezone.utc)
+    except (ValueError, OverflowError, OSError):
+        pass
+    return None
+
 
 class BaseService(ABC):
     """Abstract base class for FastAPI-powered services."""
@@ -29,15 +57,27 @@ class BaseService(ABC):
         self.name = name
         self._started = asyncio.Event()
         self._shutdown = asyncio.Event()
+        self._started_at: Optional[float] = None
+        self._mode: Optional[str] = None
+        self._health_sub: Any = None
 
     async def start(self) -> None:
         logger.info("%s service starting", self.name)
         await self.on_startup()
+        self._started_at = time.monotonic()
+        register_build_info(self.name)
         self._started.set()
+        await self._register_health_responder()
         logger.info("%s service ready", self.name)
 
     async def stop(self) -> None:
         logger.info("%s service shutting down", self.name)
+        if self._health_sub is not None:
+            try:
+                await self._health_sub.unsubscribe()
+            except Exception:
+                logger.debug("Failed to unsubscribe health responder", exc_info=True)
+            self._health_sub = None
         await self.on_shutdown()
         self._shutdown.set()
         logger.info("%s service stopped", self.name)
@@ -46,13 +86,42 @@ class BaseService(ABC):
     def started(self) -> asyncio.Event:
         return self._started
 
+    @property
+    def uptime_seconds(self) -> float:
+        if self._started_at is None:
+            return 0.0
+        return time.monotonic() - self._started_at
+
     def set_mode(self, mode: str) -> None:
         """Update Prometheus gauge for the active trading mode."""
+        self._mode = mode
         for candidate in ("live", "paper", "replay"):
             TRADING_MODE.labels(service=self.name, mode=candidate).set(
                 1 if candidate == mode else 0
             )
 
+    def drop_stale_message(self, subject: str, payload: Any) -> bool:
+        """True, and counted, when ``payload`` exceeds ``messaging.max_message_age_s``.
+
+        Age is measured from the payload's ``timestamp`` to now; payloads
+        without a parseable timestamp are kept.  Replay and backtest carry
+        historical timestamps, so the filter is off in those modes.
+        """
+        config = getattr(self, "config", None)
+        if config is None or config.app_mode in ("replay", "backtest"):
+            return False
+        max_age = getattr(config.messaging, "max_message_age_s", None)
+        if not isinstance(max_age, (int, float)) or not isinstance(payload, dict):
+            return False
+        sent_at = _payload_timestamp(payload.get("timestamp"))
+        if sent_at is None:
+            return False
+        if (datetime.now(timezone.utc) - sent_at).total_seconds() <= max_age:
+            return False
+        STALE_MESSAGES_DROPPED.labels(service=self.name, subject=subject).inc()
+        logger.debug("Dropped stale %s message from %s", subject, sent_at.isoformat())
+        return True
+
     async def health(self) -> JSONResponse:
         """Return basic health information."""
         return JSONResponse(
@@ -62,6 +131,41 @@ class BaseService(ABC):
             }
         )
 
+    def health_payload(self) -> dict:
+        """Health summary returned to ``health.ping`` requests."""
+        return {
+            "service": self.name,
+            "status": "ok" if self._started.is_set() else "starting",
+            "mode": self._mode,
+            "uptime_seconds": round(self.uptime_seconds, 3),
+        }
+
+    async def _register_health_responder(self) -> None:
+        """Answer ``health.ping`` requests on the service's messaging client.
+
+        Requests carry a ``reply_to`` subject; every service publishes its
+        :meth:`health_payload` there so callers can aggregate all replies.
+        """
+        messaging = getattr(self, "messaging", None)
+        config = getattr(self, "config", None)
+        if messaging is None or config is None:
+            return
+        subject = config.messaging.subjects.get("health_ping", "health.ping")
+
+        async def _reply(msg: Any) -> None:
+            try:
+                request = decode_payload(msg.data)
+            except (ValueError, AttributeError):
+                return
+            reply_to = request.get("reply_to") if isinstance(request, dict) else None
+            if reply_to:
+                await messaging.publish(reply_to, self.health_payload())
+
+        try:
+            self._health_sub = await messaging.subscribe(subject, _reply)
+        except Exception:
+            logger.exception("Failed to register health responder for %s", self.name)
+
     async def metrics(self) -> Response:
         """Return Prometheus metrics payload."""
         return Response(generate_latest(), media_type=CONTENT_TYPE_LATEST)
@@ -78,22 +182,41 @@ class BaseService(ABC):
 def create_app(service: BaseService) -> FastAPI:
     """Create a FastAPI application wired to the provided service."""
 
-    # Initialize structured JSON logging for this service
-    setup_logging(service.name)
+    global _logging_configured
+
+    # Initialize structured JSON logging once so co-hosted services don't
+    # clobber each other's root handlers.
+    if not _logging_configured:
+        setup_logging(service.name)
+        _logging_configured = True
 
     @asynccontextmanager
     async def lifespan(_: FastAPI):
-        await service.start()
+        # Tasks the service starts (loops, subscriptions) copy this context,
+        # so their records carry the service's name too
+        token = service_name_var.set(service.name)
         try:
-            yield
+            await service.start()
+            try:
+                yield
+            finally:
+                await service.stop()
         finally:
-            await service.stop()
+            service_name_var.reset(token)
 
     app = FastAPI(title=f"{service.name.title()} Service", lifespan=lifespan)
 
     # Add correlation ID middleware for request tracing
     app.add_middleware(CorrelationIdMiddleware)
 
+    @app.middleware("http")
+    async def service_name_middleware(request, call_next):
+        token = service_name_var.set(service.name)
+        try:
+            return await call_next(request)
+        finally:
+            service_name_var.reset(token)
+
     @app.get("/health")
     async def health_endpoint():
         return await service.health()
@@ -103,3 +226,97 @@ def create_app(service: BaseService) -> FastAPI:
         return await service.metrics()
 
     return app
+
+
+def create_debug_app() -> FastAPI:
+    """Create the profiling app served on the admin port.
+
+    It runs in the service's event loop, so ``/debug/pprof/tasks`` sees the
+    same asyncio tasks as the service (e.g. pending paper fills).
+    """
+
+    app = FastAPI(title="Debug", docs_url=None, redoc_url=None, openapi_url=None)
+
+    @app.get("/debug/pprof/tasks")
+    async def tasks_endpoint():
+        tasks = asyncio.all_tasks()
+        by_coro = Counter(
+            getattr(task.get_coro(), "__qualname__", repr(task.get_coro()))
+            for task in tasks
+        )
+        return {"total": len(tasks), "by_coroutine": dict(by_coro.most_common())}
+
+    @app.get("/debug/pprof/stacks")
+    async def stacks_endpoint():
+        names = {thread.ident: thread.name for thread in threading.enumerate()}
+        lines = []
+        for ident, frame in sys._current_frames().items():
+            lines.append(f"Thread {names.get(ident, ident)}:\n")
+            lines.extend(traceback.format_stack(frame))
+            lines.append("\n")
+        return PlainTextResponse("".join(lines))
+
+    @app.get("/debug/pprof/heap")
+    async def heap_endpoint(limit: int = 25):
+        if not tracemalloc.is_tracing():
+            return JSONResponse({"error": "tracemalloc not tracing"}, status_code=503)
+        stats = tracemalloc.take_snapshot().statistics("lineno")[:limit]
+        return {
+            "top": [
+                {"location": str(stat.traceback), "size": stat.size, "count": stat.count}
+                for stat in stats
+            ]
+        }
+
+    return app
+
+
+def build_debug_server() -> Optional[uvicorn.Server]:
+    """Build the profiling server when ``ENABLE_PPROF`` is set, else ``None``.
+
+    It binds ``PPROF_HOST:PPROF_PORT`` (default ``127.0.0.1:6060``), never the
+    public service/metrics port.
+    """
+
+    config = load_config()
+    if not config.enable_pprof:
+        return None
+    tracemalloc.start()
+    logger.info(
+        "Profiling endpoints enabled at http://%s:%d/debug/pprof/",
+        config.pprof_host,
+        config.pprof_port,
+    )
+    return uvicorn.Server(
+        uvicorn.Config(
+            create_debug_app(), host=config.pprof_host, port=config.pprof_port
+        )
+    )
+
+
+def build_server(app: FastAPI, *, key: str, default_port: int) -> uvicorn.Server:
+    """Build a dedicated uvicorn server for ``app``.
+
+    Host and port come from the ``<key>_host`` / ``<key>_port`` settings (and
+    therefore the ``<KEY>_HOST`` / ``<KEY>_PORT`` environment variables),
+    falling back to ``0.0.0.0`` and ``default_port``.
+    """
+
+    config = load_config()
+    host = getattr(config, f"{key}_host", None) or "0.0.0.0"
+    port = getattr(config, f"{key}_port", None) or default_port
+    return uvicorn.Server(uvicorn.Config(app, host=host, port=int(port)))
+
+
+async def serve(*servers: uvicorn.Server) -> None:
+    """Run several service servers side by side in the current event loop."""
+    await asyncio.gather(*(server.serve() for server in servers))
+
+
+def run_service(app: FastAPI, *, key: str, default_port: int) -> None:
+    """Serve a single service app (plus the opt-in debug server) until interrupted."""
+    servers = [build_server(app, key=key, default_port=default_port)]
+    debug_server = build_debug_server()
+    if debug_server is not None:
+        servers.append(debug_server)
+    asyncio.run(serve(*servers))
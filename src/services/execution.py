@@ -9,10 +9,13 @@ order book current.
 
 from __future__ import annotations
 
-import json
+import asyncio
 import logging
-from datetime import datetime, timezone
-from typing import Any, Dict, List, Optional
+import time
+import uuid
+from collections import deque
+from datetime import datetime, timedelta, timezone
+from typing import Any, Deque, Dict, FrozenSet, List, Optional, Set, Tuple
 
 from fastapi import FastAPI
 from nats.aio.msg import Msg
@@ -21,10 +24,12 @@ from prometheus_client import Counter, Histogram
 
 from ..config import TradingBotConfig, load_config
 from ..database import DatabaseManager
-from ..messaging import MessagingClient
-from ..metrics import REJECT_RATE
+from ..messaging import MessagingClient, decode_payload
+from ..metrics import REJECT_RATE, bounded_symbol
+from ..models import error_code
 from ..paper_trader import MarketSnapshot, PaperBroker
-from .base import BaseService, create_app
+from ..report_journal import ParquetReportJournal
+from .base import BaseService, _payload_timestamp, create_app, run_service
 
 logger = logging.getLogger(__name__)
 
@@ -34,9 +39,43 @@ ORDER_ACCEPTED = Counter(
     ["status"],
 )
 
+ORDER_REJECTS = Counter(
+    "execution_order_rejects_total",
+    "Rejected orders by reason",
+    ["mode", "reason"],
+)
+
+# Closed set of ``reason`` label values; anything else is counted as "other".
+REJECT_REASONS = frozenset(
+    {
+        "invalid_payload",
+        "invalid_order",
+        "no_market_data",
+        "market_data_stale",
+        "crossed_book",
+        "no_liquidity",
+        "max_order_qty_exceeded",
+        "max_slippage_exceeded",
+        "price_out_of_band",
+        "max_open_orders",
+        "reduce_only_exceeds_position",
+        "would_flip_position",
+        "unknown_order",
+        "post_gap_cooldown",
+        "symbol_disabled",
+        "size_factor_zero",
+        "amend_would_cross",
+        "too_many_in_flight",
+        "liquidation_guard",
+        "partial_reject",
+        "other",
+    }
+)
+
 FILL_LATENCY = Histogram(
     "execution_fill_latency_seconds",
     "Latency between order receipt and fill completion",
+    ["mode", "symbol"],
     buckets=(0.05, 0.1, 0.25, 0.5, 1.0, 2.0, 5.0),
 )
 
@@ -55,6 +94,24 @@ class ExecutionService(BaseService):
         self._order_rejections = 0
         # Map client_id → agent_id for execution report enrichment
         self._client_agent_map: Dict[str, int] = {}
+        # Latest position_size_factor from risk state (1.0 until one is seen)
+        self._size_factor = 1.0
+        # Monotonic receipt time of the last market.data message per symbol
+        self._last_market_data: Dict[str, float] = {}
+        # Symbols labelled individually on latency histograms
+        self._metric_symbols: FrozenSet[str] = frozenset()
+        # Last execution reports published, for trading.executions.recent
+        self._recent_reports: Deque[Dict[str, Any]] = deque(maxlen=500)
+        self._journal: Optional[ParquetReportJournal] = None
+        self._journal_task: Optional[asyncio.Task] = None
+        # Fills being coalesced per client_id, with their first fill's time
+        self._coalescing: Dict[str, Tuple[datetime, Dict[str, Any]]] = {}
+        # Wall-clock backstop per pending fill, so one is released even when
+        # no further report arrives to close its window
+        self._coalesce_timers: Dict[str, asyncio.TimerHandle] = {}
+        self._coalesce_flushes: Set[asyncio.Task] = set()
+        # Sync-mode replies waiting on an order's first fill
+        self._sync_replies: Set[asyncio.Task] = set()
 
     async def on_startup(self) -> None:
         self.config = load_config()
@@ -63,9 +120,18 @@ class ExecutionService(BaseService):
         self.database = DatabaseManager(self.config.database.url)
         await self.database.initialize()
 
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
+        self._recent_reports = deque(maxlen=self.config.paper.recent_reports_size)
+        self._metric_symbols = frozenset(
+            self.config.paper.metric_symbols or self.config.trading.symbols
+        )
         self.broker = PaperBroker(
             config=self.config.paper,
             database=self.database,
@@ -74,31 +140,152 @@ class ExecutionService(BaseService):
             initial_balance=self.config.trading.initial_capital,
             risk_config=self.config.risk_management,
             execution_listener=self._publish_execution_report,
+            metric_symbols=self._metric_symbols,
         )
+        journal_config = self.config.paper.report_journal
+        if journal_config is not None:
+            self._journal = ParquetReportJournal(
+                journal_config.directory, self.broker.run_id
+            )
+            self._journal_task = asyncio.create_task(
+                self._flush_journal_loop(journal_config.flush_interval_s)
+            )
         await self.broker.restore_state()
 
         orders_subject = self.config.messaging.subjects["orders"]
-        market_subject = self.config.messaging.subjects["market_data"]
+        market_subject = self.config.messaging.market_data_subscription()
+        control_subject = self.config.messaging.subjects.get(
+            "broker_control", "broker.control"
+        )
 
         order_sub = await self.messaging.subscribe(orders_subject, self._handle_order)
         market_sub = await self.messaging.subscribe(
             market_subject, self._handle_market_data
         )
-        if order_sub:
-            self._subscriptions.append(order_sub)
-        if market_sub:
-            self._subscriptions.append(market_sub)
+        control_sub = await self.messaging.subscribe(
+            control_subject, self._handle_control
+        )
+        patch_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get(
+                "paper_config_patch", "paper.config.patch"
+            ),
+            self._handle_config_patch,
+        )
+        risk_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get("risk", "risk.management"),
+            self._handle_risk_state,
+        )
+        simulate_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get(
+                "order_simulate", "trading.orders.simulate"
+            ),
+            self._handle_simulate,
+        )
+        reconcile_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get(
+                "positions_reconcile", "trading.positions.reconcile"
+            ),
+            self._handle_reconcile,
+        )
+        amend_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get("order_amend", "trading.amend"),
+            self._handle_amend,
+        )
+        recent_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get(
+                "executions_recent", "trading.executions.recent"
+            ),
+            self._handle_recent_executions,
+        )
+        for sub in (
+            order_sub,
+            market_sub,
+            control_sub,
+            risk_sub,
+            patch_sub,
+            simulate_sub,
+            reconcile_sub,
+            amend_sub,
+            recent_sub,
+        ):
+            if sub:
+                self._subscriptions.append(sub)
+
+        await self.messaging.publish(
+            self.config.messaging.subjects.get("run_started", "run.started"),
+            self.run_started_payload(),
+        )
+
+    def run_started_payload(self) -> Dict[str, Any]:
+        """Effective configuration of this run, published once on startup.
+
+        Venue credentials are left out; they never affect results.
+        """
+        if not self.config or not self.broker:
+            raise RuntimeError("ExecutionService started before initialisation")
+        return {
+            "run_id": self.broker.run_id,
+            "mode": self.config.app_mode,
+            "seed": self.broker.seed,
+            "paper": self.config.paper.model_dump(mode="json"),
+            "config": self.config.model_dump(
+                mode="json",
+                exclude={"exchange": {"api_key", "secret_key", "passphrase"}},
+            ),
+            "timestamp": datetime.now(timezone.utc).isoformat(),
+        }
 
     async def on_shutdown(self) -> None:
-        for sub in self._subscriptions:
+        # Report orders still on the book while messaging can publish, so
+        # strategies can reconcile instead of seeing them vanish.
+        if self.broker and self.messaging:
             try:
-                await sub.unsubscribe()
+                cancelled = await self.broker.cancel_open_orders(
+                    "cancelled_on_shutdown"
+                )
+                if cancelled:
+                    logger.info("Cancelled %d open orders on shutdown", cancelled)
             except Exception:
-                logger.exception("Failed to unsubscribe from %s", sub.subject)
-        self._subscriptions.clear()
+                logger.exception("Failed to cancel open orders on shutdown")
+        await self._flush_coalescing()
 
+        # Sync-mode replies finish within sync_ack_timeout_s; send them
+        # before messaging goes away
+        if self._sync_replies:
+            await asyncio.gather(*self._sync_replies, return_exceptions=True)
+
+        # Drain messaging next so in-flight orders finish against a live
+        # broker/database before those are torn down.
         if self.messaging:
             await self.messaging.close()
+        # Fills coalesced while the drain let in-flight orders finish; they
+        # still reach the journal even though messaging is closed
+        await self._flush_coalescing()
+
+        for sub in self._subscriptions:
+            try:
+                await sub.unsubscribe()
+            except Exception as exc:
+                logger.debug("Subscription %s already closed: %s", sub.subject, exc)
+        self._subscriptions.clear()
+
+        if self.broker:
+            await self.broker.close()
+
+        if self._journal_task:
+            self._journal_task.cancel()
+            try:
+                await self._journal_task
+            except asyncio.CancelledError:
+                pass
+            self._journal_task = None
+        if self._journal:
+            try:
+                await asyncio.to_thread(self._journal.close)
+            except Exception:
+                logger.exception("Failed to close the execution report journal")
+            self._journal = None
+
         if self.database:
             await self.database.close()
 
@@ -115,8 +302,125 @@ class ExecutionService(BaseService):
             rate = self._order_rejections / self._order_attempts
         REJECT_RATE.labels(mode=self.config.app_mode).set(rate)
 
+    def _record_reject(self, reason: str) -> str:
+        if reason not in REJECT_REASONS:
+            reason = "other"
+        mode = self.config.app_mode if self.config else "paper"
+        ORDER_REJECTS.labels(mode=mode, reason=reason).inc()
+        return reason
+
+    @staticmethod
+    def _reject_reason(exc: Exception) -> str:
+        message = str(exc)
+        if message in REJECT_REASONS:
+            return message
+        if isinstance(exc, RuntimeError) and message.startswith("No market data"):
+            return "no_market_data"
+        if isinstance(exc, (KeyError, ValueError, TypeError)):
+            return "invalid_order"
+        return "other"
+
     async def _publish_execution_report(self, report: Dict[str, Any]) -> None:
-        """Publish a simulated fill report."""
+        """Publish a simulated fill report, coalescing fills when configured."""
+        if self.config and self.config.paper.coalesce_fills:
+            for ready in self._coalesce(report):
+                await self._emit_execution_report(ready)
+        else:
+            await self._emit_execution_report(report)
+
+    def _coalesce(self, report: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Fold ``report`` into its order's pending fill; return reports to publish.
+
+        A pending fill is released when its order fills completely, when a
+        later fill or any other report for the order arrives, once a report
+        from any order is stamped past its window, or when the window passes
+        in wall-clock time with nothing else arriving.
+        """
+        window = timedelta(milliseconds=self.config.paper.coalesce_window_ms)
+        now = _payload_timestamp(report.get("timestamp"))
+        ready: List[Dict[str, Any]] = []
+        if now is not None:
+            for key, (started, pending) in list(self._coalescing.items()):
+                if now - started > window:
+                    ready.append(pending)
+                    self._pop_coalesced(key)
+
+        client_id = report.get("client_id")
+        current = self._pop_coalesced(client_id) if client_id else None
+        if not report.get("executed") or not client_id or now is None:
+            if current:
+                ready.append(current[1])
+            ready.append(report)
+            return ready
+
+        if current and now - current[0] <= window:
+            started, merged = current[0], self._merge_fills(current[1], report)
+        else:
+            if current:
+                ready.append(current[1])
+            started, merged = now, {**report, "coalesced_fills": 1}
+        if report.get("status") == "filled":
+            ready.append(merged)
+        else:
+            self._coalescing[client_id] = (started, merged)
+            self._coalesce_timers[client_id] = asyncio.get_running_loop().call_later(
+                window.total_seconds(), self._expire_coalesced, client_id
+            )
+        return ready
+
+    def _pop_coalesced(
+        self, client_id: str
+    ) -> Optional[Tuple[datetime, Dict[str, Any]]]:
+        timer = self._coalesce_timers.pop(client_id, None)
+        if timer is not None:
+            timer.cancel()
+        return self._coalescing.pop(client_id, None)
+
+    def _expire_coalesced(self, client_id: str) -> None:
+        """Publish ``client_id``'s pending fill once its window has passed idle."""
+        self._coalesce_timers.pop(client_id, None)
+        current = self._coalescing.pop(client_id, None)
+        if current is None:
+            return
+        task = asyncio.create_task(self._emit_execution_report(current[1]))
+        self._coalesce_flushes.add(task)
+        task.add_done_callback(self._coalesce_flushes.discard)
+
+    async def _flush_coalescing(self) -> None:
+        """Publish every pending coalesced fill now (shutdown)."""
+        for client_id in list(self._coalescing):
+            current = self._pop_coalesced(client_id)
+            if current is not None:
+                await self._emit_execution_report(current[1])
+        if self._coalesce_flushes:
+            await asyncio.gather(*self._coalesce_flushes, return_exceptions=True)
+
+    @staticmethod
+    def _merge_fills(pending: Dict[str, Any], fill: Dict[str, Any]) -> Dict[str, Any]:
+        """One report for two fills of an order: VWAP prices, summed cash flows.
+
+        Everything else (balance, equity, timestamp, status) is the later
+        fill's.
+        """
+        qty_a = float(pending.get("quantity") or 0.0)
+        qty_b = float(fill.get("quantity") or 0.0)
+        total = qty_a + qty_b
+        merged = {**fill, "quantity": total}
+        for field in ("price", "slippage_bps", "achieved_vs_signal_bps", "shortfall_bps"):
+            a, b = pending.get(field), fill.get(field)
+            if a is not None and b is not None and total > 0:
+                merged[field] = (float(a) * qty_a + float(b) * qty_b) / total
+        for field in ("fees", "funding", "realized_pnl"):
+            merged[field] = float(pending.get(field) or 0.0) + float(
+                fill.get(field) or 0.0
+            )
+        merged["maker"] = bool(pending.get("maker")) and bool(fill.get("maker"))
+        merged["coalesced_fills"] = int(pending.get("coalesced_fills", 1)) + 1
+        return merged
+
+    async def _emit_execution_report(self, report: Dict[str, Any]) -> None:
+        if self._journal:
+            self._journal.append(report)
         if not self.messaging or not self.config:
             return
 
@@ -127,54 +431,121 @@ class ExecutionService(BaseService):
             if agent_id is not None:
                 report["agent_id"] = agent_id
 
+            if report.get("reason"):
+                report["reason"] = self._record_reject(report["reason"])
+
             subject = (
                 self.config.messaging.subjects["executions_shadow"]
                 if report.get("is_shadow")
                 else self.config.messaging.subjects["executions"]
             )
             await self.messaging.publish(subject, report)
+            self._recent_reports.append(report)
+            if report.get("executed") and not report.get("is_shadow") and self.broker:
+                await self.messaging.publish(
+                    self.config.messaging.subjects.get("positions", "trading.positions"),
+                    await self.broker.account_snapshot(),
+                )
 
             latency = report.get("latency_ms")
             if latency is not None:
-                FILL_LATENCY.observe(float(latency) / 1000.0)
+                FILL_LATENCY.labels(
+                    mode=self.config.app_mode,
+                    symbol=bounded_symbol(
+                        report.get("symbol", ""), self._metric_symbols
+                    ),
+                ).observe(float(latency) / 1000.0)
         except Exception:
             logger.exception("Failed to publish execution report")
 
+    async def _flush_journal_loop(self, interval_s: float) -> None:
+        while True:
+            await asyncio.sleep(interval_s)
+            if self._journal:
+                try:
+                    # Parquet writes block; keep them off the event loop
+                    await asyncio.to_thread(self._journal.flush)
+                except Exception:
+                    logger.exception("Failed to flush the execution report journal")
+
     async def _handle_order(self, msg: Msg) -> None:
         if not self.broker or not self.messaging or not self.config:
             logger.warning("Execution service not fully initialised; dropping order")
             return
 
         try:
-            payload = json.loads(msg.data.decode("utf-8"))
-        except json.JSONDecodeError:
+            payload = decode_payload(msg.data)
+        except ValueError:
             logger.error("Received invalid order payload: %s", msg.data)
             self._order_attempts += 1
             self._order_rejections += 1
             ORDER_ACCEPTED.labels(status="invalid").inc()
+            self._record_reject("invalid_payload")
             self._update_reject_rate()
             return
 
+        if self.drop_stale_message("orders", payload):
+            logger.warning(
+                "Dropped stale order %s sent at %s",
+                payload.get("client_id") or payload.get("idempotency_key"),
+                payload.get("timestamp"),
+            )
+            return
+
         self._order_attempts += 1
 
+        # Request/reply submission: in sync ack mode the reply is the first fill
+        reply_to = (
+            payload.get("reply_to") if self.config.paper.ack_mode == "sync" else None
+        )
+
         # Track agent_id for execution report enrichment
         client_id = payload.get("client_id") or payload.get("idempotency_key")
+        if reply_to and not client_id:
+            client_id = f"paper-{uuid.uuid4().hex[:12]}"
         agent_id = payload.get("agent_id")
         if client_id and agent_id is not None:
             self._client_agent_map[client_id] = agent_id
 
         try:
-            order = await self.broker.place_order(
+            if self._market_data_stale(payload["symbol"]):
+                raise RuntimeError("market_data_stale")
+            quantity = float(payload["quantity"])
+            reduce_only = bool(payload.get("reduce_only", False))
+            # Reduce-only orders close risk, so throttling must not leave
+            # part of a position open
+            if self.config.paper.respect_size_factor and not reduce_only:
+                if self._size_factor <= 0:
+                    raise ValueError("size_factor_zero")
+                quantity *= self._size_factor
+            order_args: Dict[str, Any] = dict(
                 symbol=payload["symbol"],
                 side=payload["side"],
                 order_type=payload.get("order_type", payload.get("type", "market")),
-                quantity=float(payload["quantity"]),
+                quantity=quantity,
                 price=payload.get("price"),
                 stop_price=payload.get("stop_price"),
-                reduce_only=payload.get("reduce_only", False),
+                reduce_only=reduce_only,
                 is_shadow=payload.get("is_shadow", False),
                 client_id=client_id,
+                ttl_seconds=(
+                    float(payload["ttl_seconds"])
+                    if payload.get("ttl_seconds") is not None
+                    else None
+                ),
+                price_offset_bps=(
+                    float(payload["price_offset_bps"])
+                    if payload.get("price_offset_bps") is not None
+                    else None
+                ),
             )
+            first_fill = self.broker.next_report(client_id) if reply_to else None
+            try:
+                order = await self.broker.place_order(**order_args)
+            except BaseException:
+                if first_fill is not None:
+                    first_fill.cancel()
+                raise
 
             ORDER_ACCEPTED.labels(status="accepted").inc()
             self._update_reject_rate()
@@ -191,38 +562,296 @@ class ExecutionService(BaseService):
                 "order_type": order.order_type,
                 "stop_price": order.stop_price,
                 "price": order.price,
+                "price_offset_bps": order.price_offset_bps,
                 "quantity": order.quantity,
+                "requested_quantity": float(payload["quantity"]),
                 "is_shadow": payload.get("is_shadow", False),
                 "agent_id": agent_id,
+                "status": "accepted",
             }
 
-            await self.messaging.publish(
-                self.config.messaging.subjects["executions"], acknowledgement
-            )
+            await self.messaging.publish(self._ack_subject(), acknowledgement)
+            if first_fill is not None:
+                # Wait off the subscription callback so later orders on the
+                # subject are not queued behind this one's fill latency
+                task = asyncio.create_task(
+                    self._reply_with_first_fill(first_fill, acknowledgement, reply_to)
+                )
+                self._sync_replies.add(task)
+                task.add_done_callback(self._sync_replies.discard)
         except Exception as exc:
             self._order_rejections += 1
             ORDER_ACCEPTED.labels(status="rejected").inc()
+            reason = self._record_reject(self._reject_reason(exc))
             self._update_reject_rate()
             logger.exception("Failed to process order: %s", exc)
+            rejection = {
+                "order_id": payload.get("client_id"),
+                "client_id": payload.get("client_id"),
+                "symbol": payload.get("symbol"),
+                "executed": False,
+                "error": str(exc),
+                "error_code": error_code(reason),
+                "reason": reason,
+                "status": "rejected",
+                "timestamp": datetime.now(timezone.utc).isoformat(),
+                "mode": self.config.app_mode if self.config else "paper",
+            }
+            await self.messaging.publish(self._ack_subject(), rejection)
+            if reply_to:
+                await self.messaging.publish(reply_to, rejection)
+
+    async def _reply_with_first_fill(
+        self,
+        first_fill: "asyncio.Future[Dict[str, Any]]",
+        acknowledgement: Dict[str, Any],
+        reply_to: str,
+    ) -> None:
+        """Answer a sync-mode order with its first fill, or the ack on timeout."""
+        assert self.config is not None
+        try:
+            reply = await asyncio.wait_for(
+                first_fill, self.config.paper.sync_ack_timeout_s
+            )
+        except asyncio.TimeoutError:
+            reply = acknowledgement
+        if self.messaging is None:
+            return
+        try:
+            await self.messaging.publish(reply_to, reply)
+        except Exception:
+            logger.exception("Failed to send sync ack to %s", reply_to)
+
+    def _ack_subject(self) -> str:
+        """Subject for order accepted/rejected events.
+
+        ``subjects["order_acks"]`` when set, so fill consumers can skip the
+        lifecycle traffic; otherwise everything goes on ``executions``.
+        """
+        subjects = self.config.messaging.subjects
+        return subjects.get("order_acks") or subjects["executions"]
+
+    def _market_data_stale(self, symbol: str) -> bool:
+        """True when ``symbol`` has had no market data within the stale timeout.
+
+        Symbols never seen are left to the broker's own no-market-data check.
+        Age is wall-clock time since receipt, so replays and backtests, which
+        can pause or run faster than real time, are never flagged.
+        """
+        if not self.config or self.config.paper.market_data_stale_after_s <= 0:
+            return False
+        if self.config.app_mode in ("replay", "backtest"):
+            return False
+        symbol = self.config.paper.symbol_aliases.get(symbol, symbol)
+        last_seen = self._last_market_data.get(symbol)
+        if last_seen is None:
+            return False
+        age = time.monotonic() - last_seen
+        return age > self.config.paper.market_data_stale_after_s
+
+    async def _handle_control(self, msg: Msg) -> None:
+        if not self.broker:
+            return
+
+        try:
+            payload = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid broker control payload: %s", msg.data)
+            return
+
+        command = payload.get("command") if isinstance(payload, dict) else None
+        if command == "reset":
+            await self.broker.reset()
+            self._order_attempts = 0
+            self._order_rejections = 0
+            self._client_agent_map.clear()
+            self._update_reject_rate()
+        elif command in ("disable_symbol", "enable_symbol"):
+            symbol = payload.get("symbol")
+            if not symbol:
+                logger.error("Broker control %s without a symbol", command)
+                return
+            await self.broker.set_symbol_enabled(symbol, command == "enable_symbol")
+        else:
+            logger.warning("Unsupported broker control command: %s", command)
+
+    async def _handle_amend(self, msg: Msg) -> None:
+        """Amend a resting limit order; the broker reports the confirmation.
+
+        Payload: ``{client_id, new_price?, new_quantity?}``.  A failed amend
+        is published on ``trading.executions`` as a reject report; the order
+        itself is left unchanged.
+        """
+        if not self.broker or not self.messaging or not self.config:
+            return
+
+        try:
+            payload = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid amend payload: %s", msg.data)
+            return
+        if not isinstance(payload, dict):
+            return
+
+        client_id = payload.get("client_id")
+        try:
+            if not client_id:
+                raise ValueError("amend needs a client_id")
+            await self.broker.amend_order(
+                str(client_id),
+                new_price=(
+                    float(payload["new_price"])
+                    if payload.get("new_price") is not None
+                    else None
+                ),
+                new_quantity=(
+                    float(payload["new_quantity"])
+                    if payload.get("new_quantity") is not None
+                    else None
+                ),
+            )
+        except (TypeError, ValueError) as exc:
+            reason = self._record_reject(self._reject_reason(exc))
+            logger.warning("Amend of %s rejected: %s", client_id, exc)
             await self.messaging.publish(
-                self.config.messaging.subjects["executions"],
+                self._ack_subject(),
                 {
-                    "order_id": payload.get("client_id"),
-                    "client_id": payload.get("client_id"),
-                    "symbol": payload.get("symbol"),
+                    "order_id": client_id,
+                    "client_id": client_id,
                     "executed": False,
+                    "status": "amend_rejected",
                     "error": str(exc),
+                    "error_code": error_code(reason),
+                    "reason": reason,
                     "timestamp": datetime.now(timezone.utc).isoformat(),
-                    "mode": self.config.app_mode if self.config else "paper",
+                    "mode": self.config.app_mode,
                 },
             )
 
+    async def _handle_simulate(self, msg: Msg) -> None:
+        """Reply to a what-if order with projected fills; nothing is executed."""
+        if not self.broker or not self.messaging:
+            return
+
+        try:
+            payload = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid order simulation payload: %s", msg.data)
+            return
+        reply_to = payload.get("reply_to") if isinstance(payload, dict) else None
+        if not reply_to:
+            return
+
+        try:
+            simulation = await self.broker.simulate_order(
+                symbol=payload["symbol"],
+                side=payload["side"],
+                order_type=payload.get("type", "market"),
+                quantity=float(payload["quantity"]),
+                price=float(payload["price"]) if payload.get("price") else None,
+            )
+            reply: Dict[str, Any] = {"simulation": simulation}
+        except (KeyError, TypeError, ValueError, RuntimeError) as exc:
+            reply = {
+                "simulation": None,
+                "error": str(exc),
+                "error_code": error_code(self._reject_reason(exc)),
+            }
+        await self.messaging.publish(reply_to, reply)
+
+    async def _handle_reconcile(self, msg: Msg) -> None:
+        """Reply with positions that disagree with the broker's fill journal."""
+        if not self.broker or not self.messaging:
+            return
+
+        try:
+            payload = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid reconcile request: %s", msg.data)
+            return
+        reply_to = payload.get("reply_to") if isinstance(payload, dict) else None
+        if not reply_to:
+            return
+
+        discrepancies = await self.broker.reconcile_positions()
+        if discrepancies:
+            logger.warning(
+                "Position reconciliation found %d discrepancies", len(discrepancies)
+            )
+        await self.messaging.publish(reply_to, {"discrepancies": discrepancies})
+
+    async def _handle_recent_executions(self, msg: Msg) -> None:
+        """Reply with the latest execution reports, oldest first.
+
+        The request may narrow them to a ``symbol`` and cap them at ``count``.
+        ``warming_up`` is set until the first market tick has been seen.
+        """
+        if not self.messaging:
+            return
+
+        try:
+            payload = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid recent executions request: %s", msg.data)
+            return
+        reply_to = payload.get("reply_to") if isinstance(payload, dict) else None
+        if not reply_to:
+            return
+
+        reports = list(self._recent_reports)
+        symbol = payload.get("symbol")
+        if symbol:
+            reports = [report for report in reports if report.get("symbol") == symbol]
+        try:
+            count = int(payload["count"]) if payload.get("count") is not None else None
+        except (TypeError, ValueError):
+            count = None
+        if count is not None:
+            reports = reports[-count:] if count > 0 else []
+        await self.messaging.publish(
+            reply_to,
+            {"reports": reports, "warming_up": not self._last_market_data},
+        )
+
+    async def _handle_config_patch(self, msg: Msg) -> None:
+        if not self.broker:
+            return
+
+        try:
+            patch = decode_payload(msg.data)
+        except ValueError:
+            logger.error("Received invalid paper config patch: %s", msg.data)
+            return
+        if not isinstance(patch, dict) or not patch:
+            logger.error("Paper config patch must be a non-empty object: %s", patch)
+            return
+
+        try:
+            await self.broker.apply_config_patch(patch)
+        except ValueError as exc:
+            logger.error("Rejected paper config patch %s: %s", patch, exc)
+
+    async def _handle_risk_state(self, msg: Msg) -> None:
+        try:
+            payload = decode_payload(msg.data)
+            factor = float(payload["position_size_factor"])
+        except (KeyError, TypeError, ValueError):
+            logger.error("Received invalid risk state payload: %s", msg.data)
+            return
+
+        if factor < 0:
+            logger.warning("Ignoring negative position_size_factor: %s", factor)
+            return
+        self._size_factor = factor
+
     async def _handle_market_data(self, msg: Msg) -> None:
         if not self.broker:
             return
 
         try:
-            data = json.loads(msg.data.decode("utf-8"))
+            data = decode_payload(msg.data)
+            if self.drop_stale_message("market_data", data):
+                return
             timestamp = data.get("timestamp")
             if timestamp:
                 try:
@@ -242,6 +871,13 @@ class ExecutionService(BaseService):
                 last_side=data.get("last_side"),
                 last_size=float(data.get("last_size", 0.0)),
                 funding_rate=float(data.get("funding_rate", 0.0)),
+                index_price=(
+                    float(data["index_price"])
+                    if data.get("index_price") is not None
+                    else None
+                ),
+                high=float(data["high"]) if data.get("high") is not None else None,
+                low=float(data["low"]) if data.get("low") is not None else None,
                 timestamp=ts,
                 order_flow_imbalance=float(data.get("order_flow_imbalance", 0.0)),
             )
@@ -249,8 +885,16 @@ class ExecutionService(BaseService):
             logger.exception("Invalid market data payload: %s", msg.data)
             return
 
+        symbol = snapshot.symbol
+        if self.config:
+            symbol = self.config.paper.symbol_aliases.get(symbol, symbol)
+        self._last_market_data[symbol] = time.monotonic()
         await self.broker.update_market(snapshot)
 
 
 service = ExecutionService()
 app: FastAPI = create_app(service)
+
+
+if __name__ == "__main__":
+    run_service(app, key="exec", default_port=8080)
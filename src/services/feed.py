@@ -2,22 +2,26 @@
 Real-time market data feed using CCXT.
 
 This service fetches live ticker and order book data from the configured exchange
-and publishes it to NATS for the strategy and execution services.
+and publishes it to NATS for the strategy and execution services.  An optional
+``feed.publish_delay_ms`` holds each snapshot back before publishing, to model
+decision latency on stale data.
 """
 
 from __future__ import annotations
 
 import asyncio
 import logging
+import time
 from datetime import datetime, timezone
-from typing import Optional
+from typing import Dict, Optional, Set
 
 from fastapi import FastAPI
 
 from ..config import TradingBotConfig, load_config
 from ..exchanges.ccxt_client import CCXTClient
 from ..messaging import MessagingClient
-from .base import BaseService, create_app
+from ..metrics import BOOK_IMBALANCE, FEED_HEALTHY
+from .base import BaseService, create_app, run_service
 
 logger = logging.getLogger(__name__)
 
@@ -30,24 +34,58 @@ class FeedService(BaseService):
         self.config: Optional[TradingBotConfig] = None
         self.messaging: Optional[MessagingClient] = None
         self.exchange_client: Optional[CCXTClient] = None
+        # Fetches in flight per client, so a replaced client is only closed
+        # once they finish
+        self._client_fetches: Dict[CCXTClient, Set[asyncio.Task]] = {}
         self._task: Optional[asyncio.Task] = None
+        self._watchdog_task: Optional[asyncio.Task] = None
+        self._last_publish_at = time.monotonic()
+        self._healthy = True
 
     async def on_startup(self) -> None:
         self.config = load_config()
         self.set_mode(self.config.app_mode)
 
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
+        subject = self._publish_subject()
+        if subject is None:
+            logger.info(
+                "app_mode=replay: feed idle, replay service is the market data producer"
+            )
+            return
+        logger.info(
+            "app_mode=%s: feed is publishing market data on %s",
+            self.config.app_mode,
+            subject,
+        )
+
         # Initialize CCXT client
         # For feed, we might want to use a public client (no keys) if possible,
         # but using the configured credentials ensures higher rate limits.
         self.exchange_client = CCXTClient(self.config.exchange)
         await self.exchange_client.initialize()
 
+        self._last_publish_at = time.monotonic()
+        self._set_healthy(True)
         self._task = asyncio.create_task(self._run())
+        self._watchdog_task = asyncio.create_task(self._watchdog())
 
     async def on_shutdown(self) -> None:
+        if self._watchdog_task:
+            self._watchdog_task.cancel()
+            try:
+                await self._watchdog_task
+            except asyncio.CancelledError:
+                pass
+            self._watchdog_task = None
+
         if self._task:
             self._task.cancel()
             try:
@@ -59,6 +97,7 @@ class FeedService(BaseService):
         if self.exchange_client:
             await self.exchange_client.close()
             self.exchange_client = None
+        self._client_fetches.clear()
 
         if self.messaging:
             await self.messaging.close()
@@ -73,7 +112,9 @@ class FeedService(BaseService):
             raise RuntimeError("FeedService started before initialisation")
 
         symbols = self.config.trading.symbols
-        subject = self.config.messaging.subjects["market_data"]
+        subject = self._publish_subject()
+        if subject is None:
+            return
 
         logger.info(f"Starting feed for symbols: {symbols}")
 
@@ -83,7 +124,10 @@ class FeedService(BaseService):
         while True:
             try:
                 # Fetch data for all symbols concurrently
-                tasks = [self._fetch_and_publish(symbol, subject) for symbol in symbols]
+                tasks = [
+                    self._fetch_and_publish(symbol, self._symbol_subject(subject, symbol))
+                    for symbol in symbols
+                ]
                 results = await asyncio.gather(*tasks, return_exceptions=True)
 
                 # Track failures
@@ -117,7 +161,14 @@ class FeedService(BaseService):
             if exchange_client is None or messaging is None:
                 raise RuntimeError("FeedService started before initialisation")
 
-            ticker = await exchange_client.get_ticker(symbol)
+            fetches = self._client_fetches.setdefault(exchange_client, set())
+            fetch = asyncio.current_task()
+            if fetch is not None:
+                fetches.add(fetch)
+            try:
+                ticker = await exchange_client.get_ticker(symbol)
+            finally:
+                fetches.discard(fetch)
             if not ticker:
                 return
 
@@ -135,13 +186,18 @@ class FeedService(BaseService):
 
             # Estimate spread
             spread = best_ask - best_bid
+            bid_size = ticker.get("bidVolume") or 0.0
+            ask_size = ticker.get("askVolume") or 0.0
+            BOOK_IMBALANCE.labels(symbol=symbol).set(
+                self._book_imbalance(bid_size, ask_size)
+            )
 
             snapshot = {
                 "symbol": symbol,
                 "best_bid": best_bid,
                 "best_ask": best_ask,
-                "bid_size": ticker.get("bidVolume", 0.0),
-                "ask_size": ticker.get("askVolume", 0.0),
+                "bid_size": bid_size,
+                "ask_size": ask_size,
                 "spread": spread,
                 "last_price": last_price,
                 "last_side": "buy",  # inferred or unavailable in simple ticker
@@ -151,11 +207,108 @@ class FeedService(BaseService):
                 "order_flow_imbalance": 0.0,  # requires L2 book
             }
 
+            delay_ms = self.config.feed.publish_delay_ms if self.config else 0.0
+            if delay_ms > 0:
+                await asyncio.sleep(delay_ms / 1000.0)
             await messaging.publish(subject, snapshot)
+            self._last_publish_at = time.monotonic()
+            if not self._healthy:
+                logger.info("Market data feed recovered")
+            self._set_healthy(True)
 
         except Exception as e:
             logger.warning(f"Failed to fetch/publish for {symbol}: {e}")
 
 
+    def _publish_subject(self) -> Optional[str]:
+        """Subject for feed snapshots, or ``None`` when the feed must stay idle."""
+        if self.config is None:
+            raise RuntimeError("FeedService started before initialisation")
+        if self.config.app_mode != "replay":
+            return self.config.messaging.subjects["market_data"]
+        if self.config.feed.replay_mode == "separate_subject":
+            return self.config.feed.replay_subject
+        return None
+
+    def _symbol_subject(self, subject: str, symbol: str) -> str:
+        """Route ``symbol`` per ``messaging.market_data_subject_template``.
+
+        The replay-time ``feed.replay_subject`` is not routed.
+        """
+        if subject != self.config.messaging.subjects["market_data"]:
+            return subject
+        return self.config.messaging.market_data_subject(symbol)
+
+    @staticmethod
+    def _book_imbalance(bid_size: float, ask_size: float) -> float:
+        """Return (bid - ask) / (bid + ask), or 0.0 for an empty book."""
+        total = bid_size + ask_size
+        if total <= 0:
+            return 0.0
+        return (bid_size - ask_size) / total
+
+    def _set_healthy(self, healthy: bool) -> None:
+        self._healthy = healthy
+        mode = self.config.app_mode if self.config else "paper"
+        FEED_HEALTHY.labels(mode=mode).set(1 if healthy else 0)
+
+    async def _watchdog(self) -> None:
+        """Flag and recover from a feed that has stopped publishing."""
+        if self.config is None:
+            raise RuntimeError("FeedService started before initialisation")
+        timeout = self.config.feed.stall_timeout_seconds
+
+        while True:
+            await asyncio.sleep(timeout / 2)
+            idle = time.monotonic() - self._last_publish_at
+            if idle < timeout:
+                continue
+
+            logger.error(
+                "Market data feed stalled: no publish for %.1fs (timeout %.1fs)",
+                idle,
+                timeout,
+            )
+            self._set_healthy(False)
+            await self._reconnect()
+            # Give the fresh connection a full window before flagging again
+            self._last_publish_at = time.monotonic()
+
+    async def _reconnect(self) -> None:
+        if self.config is None:
+            return
+        old_client = self.exchange_client
+        try:
+            client = CCXTClient(self.config.exchange)
+            await client.initialize()
+        except Exception as e:
+            logger.error(f"Feed reconnect failed: {e}")
+            return
+
+        # Swap first so new fetches use the fresh client, then let fetches
+        # already running on the old one finish before closing it
+        self.exchange_client = client
+        if old_client:
+            pending = self._client_fetches.pop(old_client, set())
+            if pending:
+                _, stuck = await asyncio.wait(
+                    pending, timeout=self.config.feed.stall_timeout_seconds
+                )
+                if stuck:
+                    logger.warning(
+                        "Closing stale exchange client with %d fetch(es) still running",
+                        len(stuck),
+                    )
+            try:
+                await old_client.close()
+            except Exception:
+                logger.exception("Failed to close stale exchange client")
+        logger.info("Feed exchange client reconnected")
+
+
 service = FeedService()
 app: FastAPI = create_app(service)
+
+
+if __name__ == "__main__":
+    run_service(app, key="feed", default_port=8081)
@@ -22,7 +22,8 @@ class MarketDataPublisher:
         self.messaging = messaging
         self.running = False
         self.task: Optional[asyncio.Task] = None
-        self.symbol = config.trading.symbols[0]
+        self.symbol = config.feed.default_symbol or config.trading.symbols[0]
+        self.subject = config.messaging.market_data_subject(self.symbol)
 
     async def start(self):
         if self.running:
@@ -57,7 +58,10 @@ class MarketDataPublisher:
                         "timestamp": datetime.now(timezone.utc).isoformat(),
                     }
 
-                    await self.messaging.publish("market.data", order_book_data)
+                    delay_ms = self.config.feed.publish_delay_ms
+                    if delay_ms > 0:
+                        await asyncio.sleep(delay_ms / 1000.0)
+                    await self.messaging.publish(self.subject, order_book_data)
 
                 await asyncio.sleep(1.0)
             except Exception as e:
@@ -32,7 +32,12 @@ class MonitorService(BaseService):
         self.config = config
         self.set_mode(config.app_mode)
 
-        self.messaging = MessagingClient({"servers": config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": config.messaging.servers,
+                "encoding": config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
         logger.info("Monitor service starting checks...")
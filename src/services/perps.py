@@ -1,7 +1,6 @@
 from __future__ import annotations
 
 import asyncio
-import json
 import logging
 from datetime import datetime, timedelta, timezone
 from pathlib import Path
@@ -24,7 +23,7 @@ from src.engine.perps_executor import (
 from src.engine.pnl_tracker import PnLTracker
 from src.exchange import ExchangeClient
 from src.exchanges.zoomex_v3 import ZoomexError
-from src.messaging import MessagingClient
+from src.messaging import MessagingClient, decode_payload
 from src.risk.risk_manager import RiskManager
 from src.signal_generator import SignalGenerator
 from src.state.perps_state_store import (
@@ -276,7 +275,7 @@ class PerpsService:
         try:
             # Decode NATS message
             if hasattr(msg, "data"):
-                payload = json.loads(msg.data.decode())
+                payload = decode_payload(msg.data)
             else:
                 payload = msg  # Direct dict if memory mode or test
 
@@ -2,28 +2,44 @@
 Market replay service implemented with FastAPI.
 
 Streams historical OHLCV data as tick snapshots over NATS for replay
-and shadow testing workflows.
+and shadow testing workflows.  ``replay.control`` accepts plain-text
+``pause``/``resume`` and ``{"action": "load", "source": ...}`` to swap the
+dataset without a restart.  With a ``replay.max_duration_s`` or
+``replay.record_budget`` the stream stops once either runs out and a
+``completed`` event is published on ``replay.status``.
 """
 
 from __future__ import annotations
 
 import asyncio
+import hashlib
+import heapq
+import json
 import logging
+import math
+import random
+import statistics
+import time
+from concurrent.futures import ThreadPoolExecutor
 from datetime import datetime, timezone
 from pathlib import Path
-from typing import Any, Dict, List, Optional, Tuple
+from typing import Any, Dict, Iterator, List, Optional, Tuple
 
 import pandas as pd
 from fastapi import Body, FastAPI, HTTPException
 from nats.aio.msg import Msg
 from nats.aio.subscription import Subscription
 
-from ..config import TradingBotConfig, load_config
-from ..messaging import MessagingClient
-from .base import BaseService, create_app
+from ..config import SessionFilterConfig, TradingBotConfig, load_config
+from ..messaging import MessagingClient, decode_payload
+from ..metrics import REPLAY_DATA_GAP_SECONDS, REPLAY_DATA_GAPS
+from .base import BaseService, create_app, run_service
 
 logger = logging.getLogger(__name__)
 
+# Largest gaps kept per pass for the end-of-pass log
+_WORST_GAPS_LOGGED = 5
+
 
 class ReplayService(BaseService):
     """FastAPI wrapper around the paper trading replay stream."""
@@ -39,12 +55,32 @@ class ReplayService(BaseService):
         self._interval = 0.5
         self._last_control: Optional[str] = None
         self._last_control_at: Optional[datetime] = None
+        self._position = 0
+        self._random = random.Random()
+        # Source swapped in by a ``load`` command; None means replay.source
+        self._source: Optional[str] = None
+        self._load_lock = asyncio.Lock()
+        # Per-pass data-gap tracking: last record time per symbol and the
+        # largest gaps as (seconds, symbol, previous, current) min-heap
+        self._gap_threshold_s = math.inf
+        self._last_record_ts: Dict[str, datetime] = {}
+        self._worst_gaps: List[Tuple[float, str, str, str]] = []
+        # Run budget: why the stream completed (None until it does), records
+        # published and monotonic start time since the last (re)load
+        self._stop_reason: Optional[str] = None
+        self._published = 0
+        self._budget_started: Optional[float] = None
 
     async def on_startup(self) -> None:
         self.config = load_config()
         self.set_mode(self.config.app_mode)
 
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
         try:
@@ -64,6 +100,8 @@ class ReplayService(BaseService):
             return
 
         self._interval = self._derive_interval()
+        self._random = random.Random(self.config.replay.seed)
+        self._position = self._load_checkpoint() if self.config.replay.resume else 0
         self._running.set()
 
         control_subject = self.config.messaging.subjects.get(
@@ -99,22 +137,314 @@ class ReplayService(BaseService):
         if config is None or messaging is None:
             raise RuntimeError("ReplayService started before initialisation")
 
-        subject = config.messaging.subjects["market_data"]
+        routing = config.messaging
+        checkpoint_every = config.replay.checkpoint_every
+        logger.info(
+            "Replay is the market data producer on %s",
+            routing.market_data_subscription(),
+        )
 
+        self._gap_threshold_s = self._derive_gap_threshold()
+        if self._budget_started is None:
+            self._budget_started = time.monotonic()
         while True:
-            for snapshot in self._dataset:
-                await self._running.wait()
-                await messaging.publish(subject, snapshot)
-                await asyncio.sleep(self._interval)
+            self._last_record_ts.clear()
+            self._worst_gaps = []
+            for index in range(self._position, len(self._dataset)):
+                snapshot = self._dataset[index]
+                if not await self._wait_running():
+                    await self._complete("max_duration")
+                    return
+                self._observe_gap(snapshot)
+                await messaging.publish(
+                    routing.market_data_subject(snapshot["symbol"]), snapshot
+                )
+                self._position = index + 1
+                self._published += 1
+                if config.replay.resume and self._position % checkpoint_every == 0:
+                    self._write_checkpoint(self._position, str(snapshot["timestamp"]))
+                reason = self._budget_exhausted()
+                if reason is not None:
+                    await self._complete(reason)
+                    return
+                await asyncio.sleep(self._next_delay())
+            self._log_worst_gaps()
+            self._position = 0
+
+    def _budget_elapsed(self) -> float:
+        if self._budget_started is None:
+            return 0.0
+        return time.monotonic() - self._budget_started
+
+    async def _wait_running(self) -> bool:
+        """Wait out a pause; ``False`` if ``max_duration_s`` runs out meanwhile."""
+        if self._running.is_set():
+            return True
+        limit = self.config.replay.max_duration_s if self.config else None
+        if limit is None:
+            await self._running.wait()
+            return True
+        try:
+            await asyncio.wait_for(
+                self._running.wait(), timeout=max(limit - self._budget_elapsed(), 0.0)
+            )
+        except asyncio.TimeoutError:
+            return False
+        return True
+
+    def _budget_exhausted(self) -> Optional[str]:
+        """``"record_budget"`` or ``"max_duration"`` once a run budget is spent."""
+        replay = self.config.replay if self.config else None
+        if replay is None:
+            return None
+        if replay.record_budget is not None and self._published >= replay.record_budget:
+            return "record_budget"
+        if replay.max_duration_s is not None and self._budget_elapsed() >= replay.max_duration_s:
+            return "max_duration"
+        return None
+
+    async def _complete(self, reason: str) -> None:
+        """Stop the stream for good and announce it on ``replay_status``.
+
+        The service then reports ``completed`` until a ``load`` restarts it;
+        pause and resume are ignored.
+        """
+        self._stop_reason = reason
+        elapsed = self._budget_elapsed()
+        logger.info(
+            "Replay completed (%s) after %d records in %.1fs",
+            reason,
+            self._published,
+            elapsed,
+        )
+        self._log_worst_gaps()
+        if self.messaging is None or self.config is None:
+            return
+        subject = self.config.messaging.subjects.get("replay_status", "replay.status")
+        await self.messaging.publish(
+            subject,
+            {
+                "event": "completed",
+                "reason": reason,
+                "records": self._published,
+                "elapsed_s": round(elapsed, 3),
+                "position": self._position,
+                "timestamp": datetime.now(timezone.utc).isoformat(),
+            },
+        )
+
+    def _derive_gap_threshold(self) -> float:
+        """``replay.gap_alert_seconds``, else ``max_gap_multiplier`` x median spacing."""
+        config = self.config
+        if config is None:
+            return math.inf
+        if config.replay.gap_alert_seconds:
+            return float(config.replay.gap_alert_seconds)
+
+        last_seen: Dict[str, datetime] = {}
+        deltas: List[float] = []
+        for snapshot in self._dataset:
+            symbol = str(snapshot["symbol"])
+            ts = self._coerce_timestamp(snapshot["timestamp"])
+            previous = last_seen.get(symbol)
+            if previous is not None and ts > previous:
+                deltas.append((ts - previous).total_seconds())
+            last_seen[symbol] = ts
+        if not deltas:
+            return math.inf
+        return statistics.median(deltas) * config.replay.max_gap_multiplier
+
+    def _observe_gap(self, snapshot: Dict[str, Any]) -> None:
+        """Record the data-time gap since the symbol's previous replayed record."""
+        symbol = str(snapshot["symbol"])
+        ts = self._coerce_timestamp(snapshot["timestamp"])
+        previous = self._last_record_ts.get(symbol)
+        self._last_record_ts[symbol] = ts
+        if previous is None:
+            return
+        gap = (ts - previous).total_seconds()
+        REPLAY_DATA_GAP_SECONDS.labels(symbol=symbol).observe(gap)
+        if gap <= self._gap_threshold_s:
+            return
+        REPLAY_DATA_GAPS.labels(symbol=symbol).inc()
+        entry = (gap, symbol, previous.isoformat(), ts.isoformat())
+        if len(self._worst_gaps) < _WORST_GAPS_LOGGED:
+            heapq.heappush(self._worst_gaps, entry)
+        elif entry > self._worst_gaps[0]:
+            heapq.heapreplace(self._worst_gaps, entry)
+        else:
+            return
+        # Only gaps entering the worst-N set are logged, to bound the noise
+        logger.warning(
+            "Replay data gap: %s %.0fs between %s and %s (threshold %.0fs)",
+            symbol,
+            gap,
+            entry[2],
+            entry[3],
+            self._gap_threshold_s,
+        )
+
+    def _log_worst_gaps(self) -> None:
+        if not self._worst_gaps:
+            return
+        logger.warning(
+            "Replay data gaps over %.0fs this pass, worst first: %s",
+            self._gap_threshold_s,
+            "; ".join(
+                f"{symbol} {gap:.0f}s {previous} -> {current}"
+                for gap, symbol, previous, current in sorted(self._worst_gaps, reverse=True)
+            ),
+        )
+
+    def _next_delay(self) -> float:
+        """Seconds until the next record, with optional seeded jitter."""
+        jitter_ms = self.config.replay.timestamp_jitter_ms if self.config else 0.0
+        if jitter_ms <= 0:
+            return self._interval
+        jitter_s = self._random.uniform(-jitter_ms, jitter_ms) / 1000.0
+        return max(self._interval + jitter_s, 0.0)
+
+    def _write_checkpoint(self, index: int, timestamp: str) -> None:
+        config = self.config
+        if config is None:
+            return
+        path = Path(config.replay.checkpoint_path)
+        payload = {
+            "source": self.source,
+            "settings": self._checkpoint_settings_hash(),
+            "index": index,
+            "timestamp": timestamp,
+        }
+        try:
+            path.parent.mkdir(parents=True, exist_ok=True)
+            tmp_path = path.with_suffix(path.suffix + ".tmp")
+            tmp_path.write_text(json.dumps(payload), encoding="utf-8")
+            tmp_path.replace(path)
+        except OSError as exc:
+            logger.warning("Failed to write replay checkpoint %s: %s", path, exc)
+
+    def _checkpoint_settings_hash(self) -> str:
+        """Digest of the settings that decide which record sits at each index.
+
+        A checkpoint index only means the same record when the session
+        filter, dedupe, slice and downsample settings are unchanged.
+        """
+        replay = self.config.replay
+        session = replay.session
+        settings = {
+            "default_symbol": replay.default_symbol or self.config.trading.symbols[0],
+            "session": session.model_dump(mode="json") if session is not None else None,
+            "dedupe_timestamps": replay.dedupe_timestamps,
+            "start_index": replay.start_index,
+            "max_records": replay.max_records,
+            "downsample": replay.downsample,
+        }
+        encoded = json.dumps(settings, sort_keys=True).encode("utf-8")
+        return hashlib.sha256(encoded).hexdigest()
+
+    def _load_checkpoint(self) -> int:
+        """Return the index to resume from, or 0 if no usable checkpoint exists."""
+        config = self.config
+        if config is None:
+            return 0
+        path = Path(config.replay.checkpoint_path)
+        if not path.exists():
+            return 0
+        try:
+            checkpoint = json.loads(path.read_text(encoding="utf-8"))
+            index = int(checkpoint.get("index", 0))
+        except (OSError, ValueError, TypeError) as exc:
+            logger.warning("Ignoring unreadable replay checkpoint %s: %s", path, exc)
+            return 0
+
+        if checkpoint.get("source") != self.source:
+            logger.info("Replay checkpoint is for a different source; starting fresh")
+            return 0
+        if checkpoint.get("settings") != self._checkpoint_settings_hash():
+            logger.info(
+                "Replay checkpoint was taken with different filter/slice settings; "
+                "starting fresh"
+            )
+            return 0
+        if not 0 <= index < len(self._dataset):
+            logger.info("Replay checkpoint index %d out of range; starting fresh", index)
+            return 0
+
+        logger.info(
+            "Resuming replay at index %d (%s)", index, checkpoint.get("timestamp")
+        )
+        return index
 
     async def _handle_control(self, msg: Msg) -> None:
         try:
-            payload = msg.data.decode("utf-8").strip().lower()
-        except Exception:
+            command = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            command = None
+        if not isinstance(command, dict):
+            try:
+                action = msg.data.decode("utf-8").strip().lower()
+            except Exception:
+                return
+            if action in {"pause", "resume"}:
+                await self.set_state(action)
+            return
+
+        if str(command.get("action", "")).lower() != "load":
+            logger.warning("Unsupported replay control command: %s", command)
             return
+        try:
+            result: Dict[str, Any] = {
+                "ok": True,
+                **await self.load_source(str(command.get("source") or "")),
+            }
+        except Exception as exc:
+            logger.error("Replay load of %r failed: %s", command.get("source"), exc)
+            result = {"ok": False, "error": str(exc), **self.status_payload()}
+        if self.messaging is not None and self.config is not None:
+            reply_to = command.get("reply_to") or self.config.messaging.subjects.get(
+                "replay_status", "replay.status"
+            )
+            await self.messaging.publish(reply_to, result)
+
+    async def load_source(self, source: str) -> Dict[str, Any]:
+        """Swap the replayed dataset for ``source`` and restart from its first record.
+
+        The new data is loaded with the configured filters (validation,
+        slicing, funding) before anything is touched, so a bad path or an
+        empty file raises and leaves the active replay running.
+        """
+        if self.config is None or self.messaging is None:
+            raise RuntimeError("ReplayService started before initialisation")
+        if not source:
+            raise ValueError("load requires a source")
 
-        if payload in {"pause", "resume"}:
-            await self.set_state(payload)
+        async with self._load_lock:
+            dataset = await asyncio.to_thread(self._load_dataset, source)
+            if not dataset:
+                raise ValueError(f"Replay source {source} has no records")
+
+            if self._loop_task:
+                self._loop_task.cancel()
+                try:
+                    await self._loop_task
+                except asyncio.CancelledError:
+                    pass
+
+            self._dataset = dataset
+            self._source = source
+            self._position = 0
+            self._stop_reason = None
+            self._published = 0
+            self._budget_started = None
+            self._interval = self._derive_interval()
+            self._random = random.Random(self.config.replay.seed)
+            self._running.set()
+            self._last_control = "load"
+            self._last_control_at = datetime.now(timezone.utc)
+            self._loop_task = asyncio.create_task(self._run_loop())
+
+        logger.info("Replay switched to %s (%d records)", source, len(dataset))
+        return self.status_payload()
 
     def _derive_interval(self) -> float:
         config = self.config
@@ -128,74 +458,367 @@ class ReplayService(BaseService):
         base_interval = 1.0  # seconds between ticks before speedup
         return max(base_interval / multiplier, 0.05)
 
-    def _load_dataset(self) -> List[Dict[str, float | str]]:
+    def _load_dataset(self, source: Optional[str] = None) -> List[Dict[str, float | str]]:
+        """Build the snapshot list for ``source`` (default ``replay.source``).
+
+        Large parquet files are read one batch at a time.  Batches are
+        validated independently, so gaps and duplicates spanning a row-group
+        boundary are not counted.
+        """
         config = self.config
         if config is None:
             raise RuntimeError("ReplayService started before initialisation")
 
-        source = config.replay.source
-        scheme, path = self._parse_source(source)
+        funding_schedule = (
+            self._read_source(config.replay.funding_source)
+            if config.replay.funding_source
+            else None
+        )
+        default_symbol = config.replay.default_symbol or config.trading.symbols[0]
 
-        if path.is_dir():
-            df = self._load_directory(path, scheme)
-        elif scheme == "parquet":
-            df = pd.read_parquet(path)
-        elif scheme == "csv":
-            df = pd.read_csv(path)
-        else:
-            df = (
-                pd.read_parquet(path)
-                if path.suffix == ".parquet"
-                else pd.read_csv(path)
+        issues: Dict[str, int] = {}
+        funding_carry: Dict[str, float] = {}
+        filtered = 0
+        dataset: List[Dict[str, float | str]] = []
+        batches = 0
+        for df in self._iter_source(
+            source or config.replay.source,
+            batch_rows=config.replay.stream_batch_rows,
+            workers=config.replay.read_workers,
+        ):
+            if df.empty:
+                continue
+            if "timestamp" not in df.columns:
+                raise ValueError("Replay dataset must include a 'timestamp' column")
+
+            df["timestamp"] = pd.to_datetime(df["timestamp"], utc=True)
+            df = df.sort_values("timestamp")
+            if config.replay.session is not None:
+                in_session = self._session_mask(df["timestamp"], config.replay.session)
+                filtered += int((~in_session).sum())
+                df = df[in_session]
+                if df.empty:
+                    continue
+            if config.replay.validate_data:
+                for name, count in self._validate_dataset(
+                    df, config.replay.max_gap_multiplier
+                ).items():
+                    issues[name] = issues.get(name, 0) + count
+
+            df = self._attach_funding(df, funding_schedule, carry=funding_carry)
+            dataset.extend(
+                self._frame_to_snapshots(
+                    df,
+                    default_symbol,
+                    preserve_zero_sizes=config.replay.preserve_zero_sizes,
+                )
+            )
+            batches += 1
+
+        if batches > 1:
+            # ISO-8601 UTC strings order chronologically; the sort is stable
+            dataset.sort(key=lambda snapshot: str(snapshot["timestamp"]))
+
+        if config.replay.session is not None:
+            logger.info(
+                "Replay session filter dropped %d out-of-session records (%d kept)",
+                filtered,
+                len(dataset),
             )
 
-        if df.empty:
-            return []
+        if config.replay.validate_data and dataset:
+            total = sum(issues.values())
+            if total:
+                logger.warning(
+                    "Replay dataset integrity issues: %s",
+                    ", ".join(f"{name}={count}" for name, count in issues.items()),
+                )
+                if config.replay.reject_invalid:
+                    raise ValueError(
+                        f"Replay dataset failed validation with {total} issue(s)"
+                    )
+            else:
+                logger.info(
+                    "Replay dataset passed integrity checks (%d rows)", len(dataset)
+                )
+
+        if config.replay.dedupe_timestamps:
+            dataset = self._dedupe_dataset(dataset)
+        dataset = self._slice_dataset(
+            dataset, config.replay.start_index, config.replay.max_records
+        )
+        return self._downsample_dataset(dataset, config.replay.downsample)
 
-        if "timestamp" not in df.columns:
-            raise ValueError("Replay dataset must include a 'timestamp' column")
+    @staticmethod
+    def _session_mask(
+        timestamps: pd.Series, session: SessionFilterConfig
+    ) -> pd.Series:
+        """True for timestamps inside the session's local windows and weekdays."""
+        local = timestamps.dt.tz_convert(session.timezone)
+        minutes = local.dt.hour * 60 + local.dt.minute
+        in_window = pd.Series(False, index=timestamps.index)
+        for window in session.windows:
+            start, end = SessionFilterConfig.parse_window(window)
+            if start < end:
+                in_window |= (minutes >= start) & (minutes < end)
+            else:
+                in_window |= (minutes >= start) | (minutes < end)
+        if session.weekdays is not None:
+            in_window &= local.dt.weekday.isin(session.weekdays)
+        return in_window
 
-        df["timestamp"] = pd.to_datetime(df["timestamp"], utc=True)
-        df = df.sort_values("timestamp")
+    @staticmethod
+    def _dedupe_dataset(
+        dataset: List[Dict[str, float | str]],
+    ) -> List[Dict[str, float | str]]:
+        """Keep only the last record per ``(symbol, timestamp)``."""
+        last = {
+            (snapshot["symbol"], snapshot["timestamp"]): index
+            for index, snapshot in enumerate(dataset)
+        }
+        if len(last) == len(dataset):
+            return dataset
+        kept = [
+            snapshot
+            for index, snapshot in enumerate(dataset)
+            if last[(snapshot["symbol"], snapshot["timestamp"])] == index
+        ]
+        logger.info(
+            "Replay removed %d duplicate (symbol, timestamp) records (%d kept)",
+            len(dataset) - len(kept),
+            len(kept),
+        )
+        return kept
 
-        dataset: List[Dict[str, float | str]] = []
+    @staticmethod
+    def _slice_dataset(
+        dataset: List[Dict[str, float | str]],
+        start_index: int,
+        max_records: Optional[int],
+    ) -> List[Dict[str, float | str]]:
+        """Apply ``start_index``/``max_records`` to the sorted dataset."""
+        if not dataset or (start_index == 0 and max_records is None):
+            return dataset
+        if start_index >= len(dataset):
+            raise ValueError(
+                f"Replay start_index {start_index} is beyond the dataset "
+                f"({len(dataset)} records)"
+            )
+        end = len(dataset) if max_records is None else min(
+            start_index + max_records, len(dataset)
+        )
+        logger.info(
+            "Replay range: records %d-%d of %d (%s -> %s)",
+            start_index,
+            end - 1,
+            len(dataset),
+            dataset[start_index]["timestamp"],
+            dataset[end - 1]["timestamp"],
+        )
+        return dataset[start_index:end]
+
+    @staticmethod
+    def _downsample_dataset(
+        dataset: List[Dict[str, float | str]], every: int
+    ) -> List[Dict[str, float | str]]:
+        """Keep every ``every``-th record, starting with the first."""
+        if every <= 1 or not dataset:
+            return dataset
+        kept = dataset[::every]
+        logger.info(
+            "Replay downsampled 1-in-%d: %d of %d records kept",
+            every,
+            len(kept),
+            len(dataset),
+        )
+        return kept
+
+    @classmethod
+    def _frame_to_snapshots(
+        cls, df: pd.DataFrame, default_symbol: str, *, preserve_zero_sizes: bool = False
+    ) -> List[Dict[str, float | str]]:
+        snapshots: List[Dict[str, float | str]] = []
         for _, row in df.iterrows():
-            ts = self._coerce_timestamp(row["timestamp"])
-            symbol = row.get("symbol", config.trading.symbols[0])
+            ts = cls._coerce_timestamp(row["timestamp"])
+            symbol = row.get("symbol", default_symbol)
             open_price = float(row.get("open", row.get("close", 0)))
             high = float(row.get("high", open_price))
             low = float(row.get("low", open_price))
             close = float(row.get("close", open_price))
             volume = float(row.get("volume", 1))
-            snapshot = self._build_snapshot(
-                symbol, ts, open_price, high, low, close, volume
+            snapshots.append(
+                cls._build_snapshot(
+                    symbol,
+                    ts,
+                    open_price,
+                    high,
+                    low,
+                    close,
+                    volume,
+                    funding_rate=float(row.get("funding_rate", 0.0)),
+                    preserve_zero_sizes=preserve_zero_sizes,
+                )
             )
-            dataset.append(snapshot)
+        return snapshots
+
+    @classmethod
+    def _iter_source(
+        cls, source: str, *, batch_rows: int = 0, workers: int = 1
+    ) -> Iterator[pd.DataFrame]:
+        """Yield ``source`` as DataFrames: batched for large parquet files.
+
+        Small files and directories are yielded whole via :meth:`_read_source`.
+        """
+        scheme, path = cls._parse_source(source)
+        is_parquet = scheme == "parquet" or (not scheme and path.suffix == ".parquet")
+        if batch_rows > 0 and is_parquet and path.is_file():
+            import pyarrow.parquet as pq
+
+            parquet = pq.ParquetFile(path)
+            total_rows = parquet.metadata.num_rows
+            if total_rows > batch_rows:
+                logger.info(
+                    "Streaming %s: %d rows in batches of %d",
+                    path.name,
+                    total_rows,
+                    batch_rows,
+                )
+                for batch in parquet.iter_batches(
+                    batch_size=batch_rows, use_threads=workers > 1
+                ):
+                    yield batch.to_pandas()
+                return
+        yield cls._read_source(source, workers=workers)
+
+    @classmethod
+    def _read_source(cls, source: str, workers: int = 1) -> pd.DataFrame:
+        scheme, path = cls._parse_source(source)
+        if path.is_dir():
+            return cls._load_directory(path, scheme, workers=workers)
+        if scheme == "parquet":
+            return pd.read_parquet(path)
+        if scheme == "csv":
+            return pd.read_csv(path)
+        return pd.read_parquet(path) if path.suffix == ".parquet" else pd.read_csv(path)
 
-        return dataset
+    @staticmethod
+    def _attach_funding(
+        df: pd.DataFrame,
+        schedule: Optional[pd.DataFrame] = None,
+        carry: Optional[Dict[str, float]] = None,
+    ) -> pd.DataFrame:
+        """Populate ``funding_rate`` per row, forward-filling sparse observations.
+
+        Rates come from a ``funding``/``funding_rate`` column in the bars or,
+        when given, a separate schedule (``timestamp``, ``funding_rate`` and
+        optionally ``symbol``) matched as-of each bar.  Rows before the first
+        observation get zero.  ``carry`` holds the last rate per symbol across
+        successive batches of one dataset and is updated in place.
+        """
+        df = df.rename(columns={"funding": "funding_rate"})
+        if schedule is not None and not schedule.empty:
+            schedule = schedule.rename(columns={"funding": "funding_rate"})
+            if "funding_rate" not in schedule.columns:
+                raise ValueError("Funding schedule must include a 'funding_rate' column")
+            schedule = schedule.copy()
+            schedule["timestamp"] = pd.to_datetime(schedule["timestamp"], utc=True)
+            schedule = schedule.sort_values("timestamp")
+            by = "symbol" if "symbol" in schedule.columns and "symbol" in df.columns else None
+            columns = ["timestamp", "funding_rate"] + ([by] if by else [])
+            df = pd.merge_asof(
+                df.drop(columns=["funding_rate"], errors="ignore"),
+                schedule[columns],
+                on="timestamp",
+                by=by,
+                direction="backward",
+            )
+        if "funding_rate" not in df.columns:
+            return df
+        if "symbol" in df.columns:
+            df["funding_rate"] = df.groupby("symbol")["funding_rate"].ffill()
+        else:
+            df["funding_rate"] = df["funding_rate"].ffill()
+        if carry is not None:
+            keys = (
+                df["symbol"].astype(str)
+                if "symbol" in df.columns
+                else pd.Series("", index=df.index)
+            )
+            df["funding_rate"] = df["funding_rate"].fillna(keys.map(carry))
+            carry.update(df.groupby(keys)["funding_rate"].last().dropna().to_dict())
+        df["funding_rate"] = df["funding_rate"].fillna(0.0)
+        return df
 
     @staticmethod
-    def _load_directory(path: Path, scheme: str) -> pd.DataFrame:
+    def _load_directory(path: Path, scheme: str, workers: int = 1) -> pd.DataFrame:
         """Load and concatenate all data files from a directory."""
-        frames: List[pd.DataFrame] = []
         extensions = {"parquet": [".parquet"], "csv": [".csv"]}.get(
             scheme, [".parquet", ".csv"]
         )
-        for ext in extensions:
-            for fp in sorted(path.glob(f"*{ext}")):
-                try:
-                    df = pd.read_parquet(fp) if ext == ".parquet" else pd.read_csv(fp)
-                    # Infer symbol from filename if column missing
-                    if "symbol" not in df.columns:
-                        df["symbol"] = fp.stem
-                    frames.append(df)
-                    logger.info("Loaded %d rows from %s", len(df), fp.name)
-                except Exception as e:
-                    logger.warning("Failed to load %s: %s", fp, e)
+        files = [fp for ext in extensions for fp in sorted(path.glob(f"*{ext}"))]
+
+        def _load(fp: Path) -> Optional[pd.DataFrame]:
+            try:
+                df = pd.read_parquet(fp) if fp.suffix == ".parquet" else pd.read_csv(fp)
+            except Exception as e:
+                logger.warning("Failed to load %s: %s", fp, e)
+                return None
+            # Infer symbol from filename if column missing
+            if "symbol" not in df.columns:
+                df["symbol"] = fp.stem
+            logger.info("Loaded %d rows from %s", len(df), fp.name)
+            return df
+
+        if workers > 1 and len(files) > 1:
+            with ThreadPoolExecutor(max_workers=workers) as pool:
+                loaded = list(pool.map(_load, files))
+        else:
+            loaded = [_load(fp) for fp in files]
+        frames = [df for df in loaded if df is not None]
         if not frames:
             return pd.DataFrame()
         return pd.concat(frames, ignore_index=True)
 
+    @staticmethod
+    def _validate_dataset(df: pd.DataFrame, max_gap_multiplier: float) -> Dict[str, int]:
+        """Count duplicate timestamps, negative prices, crossed quotes and gaps.
+
+        Duplicates and gaps are evaluated per symbol so multi-symbol
+        directories are not flagged for sharing bar timestamps.  A gap is any
+        interval larger than ``max_gap_multiplier`` times the median interval.
+        """
+        issues = {
+            "duplicate_timestamps": 0,
+            "negative_prices": 0,
+            "crossed_quotes": 0,
+            "time_gaps": 0,
+        }
+
+        price_columns = [
+            col
+            for col in ("open", "high", "low", "close", "best_bid", "best_ask")
+            if col in df.columns
+        ]
+        if price_columns:
+            issues["negative_prices"] = int((df[price_columns] < 0).any(axis=1).sum())
+
+        if "best_bid" in df.columns and "best_ask" in df.columns:
+            issues["crossed_quotes"] = int((df["best_bid"] > df["best_ask"]).sum())
+
+        groups = df.groupby("symbol") if "symbol" in df.columns else [(None, df)]
+        for _, group in groups:
+            timestamps = group["timestamp"]
+            issues["duplicate_timestamps"] += int(timestamps.duplicated().sum())
+            deltas = timestamps.drop_duplicates().diff().dropna()
+            if deltas.empty:
+                continue
+            median = deltas.median()
+            if median.total_seconds() <= 0:
+                continue
+            issues["time_gaps"] += int((deltas > median * max_gap_multiplier).sum())
+
+        return issues
+
     @staticmethod
     def _coerce_timestamp(value) -> datetime:
         if isinstance(value, datetime):
@@ -224,14 +847,18 @@ class ReplayService(BaseService):
         low: float,
         close: float,
         volume: float,
+        funding_rate: float = 0.0,
+        preserve_zero_sizes: bool = False,
     ) -> Dict[str, float | str]:
         spread = max((high - low) * 0.2, max(close * 0.0004, 0.5))
         best_bid = close - spread / 2
         best_ask = close + spread / 2
-        bid_size = max(volume * 0.25, 1)
-        ask_size = max(volume * 0.25, 1)
+        # Sizes are floored at 1 unless a no-trade bar should read as illiquid
+        min_size = 0.0 if preserve_zero_sizes else 1.0
+        bid_size = max(volume * 0.25, min_size)
+        ask_size = max(volume * 0.25, min_size)
         side = "buy" if close >= open_price else "sell"
-        last_size = max(volume * 0.1, 1)
+        last_size = max(volume * 0.1, min_size)
         ofi = (bid_size - ask_size) * spread
 
         return {
@@ -249,19 +876,28 @@ class ReplayService(BaseService):
             "volume": volume,
             "last_side": side,
             "last_size": last_size,
-            "funding_rate": 0.0,
+            "funding_rate": funding_rate,
             "timestamp": timestamp.isoformat(),
             "order_flow_imbalance": ofi,
         }
 
     @property
     def state(self) -> str:
+        if self._stop_reason is not None:
+            return "completed"
         return "running" if self._running.is_set() else "paused"
 
     @property
     def interval(self) -> float:
         return self._interval
 
+    @property
+    def source(self) -> Optional[str]:
+        """Dataset being replayed: the last ``load`` source, else ``replay.source``."""
+        if self._source is not None:
+            return self._source
+        return self.config.replay.source if self.config else None
+
     @property
     def dataset_size(self) -> int:
         return len(self._dataset)
@@ -278,6 +914,9 @@ class ReplayService(BaseService):
 
     async def set_state(self, action: str) -> None:
         normalized = action.lower()
+        if normalized in {"pause", "resume"} and self._stop_reason is not None:
+            logger.info("Replay completed (%s); ignoring %s", self._stop_reason, normalized)
+            return
         if normalized == "pause":
             self._running.clear()
         elif normalized == "resume":
@@ -291,12 +930,15 @@ class ReplayService(BaseService):
         return {
             "state": self.state,
             "interval": self._interval,
+            "source": self.source,
             "dataset_size": self.dataset_size,
+            "position": self._position,
             "speed": (
                 getattr(self.config.replay, "speed", None) if self.config else None
             ),
             "last_control": self._last_control,
             "last_control_at": self.last_control_at,
+            "stop_reason": self._stop_reason,
         }
 
 
@@ -310,9 +952,18 @@ async def replay_status() -> Dict[str, Any]:
 
 
 @app.post("/control")
-async def replay_control(action: str = Body(..., embed=True)) -> Dict[str, Any]:
+async def replay_control(
+    action: str = Body(..., embed=True),
+    source: Optional[str] = Body(None, embed=True),
+) -> Dict[str, Any]:
     try:
+        if action.lower() == "load":
+            return await service.load_source(source or "")
         await service.set_state(action)
-    except ValueError as exc:
+    except (ValueError, FileNotFoundError) as exc:
         raise HTTPException(status_code=400, detail=str(exc)) from exc
     return service.status_payload()
+
+
+if __name__ == "__main__":
+    run_service(app, key="replay", default_port=8085)
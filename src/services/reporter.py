@@ -1,24 +1,175 @@
 """
 Reporter service implemented with FastAPI.
 
-Consumes strategy performance metrics from NATS and periodically emits
-summary reports for downstream monitoring dashboards.
+Consumes strategy performance metrics and execution reports from NATS and
+periodically emits summary reports for downstream monitoring dashboards.
+Fills are aggregated into a per-symbol breakdown: implementation shortfall
+(fill price vs arrival mid, quantity weighted), realized PnL and funding paid
+and received, kept apart from trading PnL.  The key parameters of the run,
+taken from its ``run.started`` message, are attached to every summary, as is
+the latest account balance and equity seen on a fill.
+
+When shadow orders run alongside the primary, shadow and primary reports are
+paired by ``client_id`` and the summary carries how far shadow diverges:
+fill price (bps), first-fill latency and fill rate.
+
+Key metrics are also kept per ``run_id`` for the most recent runs, so two runs
+can be compared side by side with ``GET /compare?a=<run>&b=<run>``.
+
+With ``reporting.currency`` set, per-symbol PnL and funding are also given
+converted from each symbol's quote currency into that currency, and summed.
+Trailing windows (``reporting.windows_s``, measured in fill time so replays
+use simulated time) give PnL, win rate and Sharpe over recent fills.
 """
 
 from __future__ import annotations
 
 import asyncio
-import json
+import logging
+import math
+from collections import OrderedDict, deque
+from dataclasses import dataclass
 from datetime import datetime, timezone
-from typing import Optional
+from typing import Any, Deque, Dict, List, Optional, Tuple
 
-from fastapi import FastAPI
+from fastapi import FastAPI, HTTPException, Query
 from nats.aio.msg import Msg
 from nats.aio.subscription import Subscription
 
 from ..config import TradingBotConfig, load_config
-from ..messaging import MessagingClient
-from .base import BaseService, create_app
+from ..messaging import MessagingClient, decode_payload
+from .base import BaseService, _payload_timestamp, create_app, run_service
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class _SymbolStats:
+    fills: int = 0
+    quantity: float = 0.0
+    weighted_shortfall_bps: float = 0.0
+    realized_pnl: float = 0.0
+    funding_paid: float = 0.0
+    funding_received: float = 0.0
+
+    def summary(self) -> Dict[str, float]:
+        return {
+            "fills": self.fills,
+            "quantity": self.quantity,
+            "avg_shortfall_bps": (
+                round(self.weighted_shortfall_bps / self.quantity, 4)
+                if self.quantity > 0
+                else 0.0
+            ),
+            "realized_pnl": self.realized_pnl,
+            "funding_paid": self.funding_paid,
+            "funding_received": self.funding_received,
+            "funding_net": self.funding_paid - self.funding_received,
+        }
+
+
+@dataclass
+class _FillLeg:
+    """Fills of one side (primary or shadow) of a ``client_id``."""
+
+    quantity: float = 0.0
+    notional: float = 0.0
+    latency_ms: Optional[float] = None
+
+    @property
+    def vwap(self) -> float:
+        return self.notional / self.quantity if self.quantity > 0 else 0.0
+
+
+@dataclass
+class _RunStats:
+    """Running metrics of one run, updated fill by fill."""
+
+    trades: int = 0
+    fees: float = 0.0
+    pnl: float = 0.0
+    last_equity: Optional[float] = None
+    peak_equity: float = 0.0
+    max_drawdown: float = 0.0
+    # Welford accumulators over fill-to-fill equity returns
+    returns: int = 0
+    mean_return: float = 0.0
+    return_m2: float = 0.0
+
+    def add_fill(self, fees: float, pnl: float, equity: Optional[float]) -> None:
+        self.trades += 1
+        self.fees += fees
+        self.pnl += pnl
+        if equity is None:
+            return
+        if self.last_equity:
+            ret = equity / self.last_equity - 1.0
+            self.returns += 1
+            delta = ret - self.mean_return
+            self.mean_return += delta / self.returns
+            self.return_m2 += delta * (ret - self.mean_return)
+        self.last_equity = equity
+        self.peak_equity = max(self.peak_equity, equity)
+        if self.peak_equity > 0:
+            self.max_drawdown = max(
+                self.max_drawdown, (self.peak_equity - equity) / self.peak_equity
+            )
+
+    def summary(self) -> Dict[str, float]:
+        sharpe = 0.0
+        if self.returns >= 2:
+            stdev = math.sqrt(self.return_m2 / (self.returns - 1))
+            if stdev > 0:
+                sharpe = self.mean_return / stdev
+        return {
+            "pnl": self.pnl,
+            "sharpe": round(sharpe, 4),
+            "max_drawdown": round(self.max_drawdown, 6),
+            "fees": self.fees,
+            "trades": self.trades,
+        }
+
+
+def _window_stats(pnls: List[Tuple[float, float]]) -> Dict[str, float]:
+    """PnL, win rate (share of fills that realized a profit, among those
+    realizing any) and unannualised Sharpe of per-fill net PnL."""
+    closing = [pnl for pnl, realized in pnls if realized != 0]
+    net = [pnl for pnl, _ in pnls]
+    sharpe = 0.0
+    if len(net) >= 2:
+        mean = sum(net) / len(net)
+        stdev = math.sqrt(sum((pnl - mean) ** 2 for pnl in net) / (len(net) - 1))
+        if stdev > 0:
+            sharpe = mean / stdev
+    return {
+        "pnl": sum(net),
+        "trades": len(net),
+        "win_rate": (
+            round(sum(1 for _, realized in pnls if realized > 0) / len(closing), 4)
+            if closing
+            else 0.0
+        ),
+        "sharpe": round(sharpe, 4),
+    }
+
+
+# Runs whose metrics are retained for comparison; the oldest are dropped first
+_RUN_RETENTION = 50
+
+# Client ids tracked for shadow/primary pairing; the oldest are dropped first
+_DIVERGENCE_WINDOW = 10_000
+
+# Paper parameters from run.started that are copied into summaries
+_RUN_PAPER_PARAMS = (
+    "fee_bps",
+    "maker_rebate_bps",
+    "slippage_bps",
+    "max_slippage_bps",
+    "latency_ms",
+    "partial_fill",
+    "partial_reject_rate",
+    "price_source",
+)
 
 
 class ReporterService(BaseService):
@@ -30,26 +181,51 @@ class ReporterService(BaseService):
         self.messaging: Optional[MessagingClient] = None
         self._summary_task: Optional[asyncio.Task[None]] = None
         self._subscription: Optional[Subscription] = None
+        self._executions_sub: Optional[Subscription] = None
+        self._run_sub: Optional[Subscription] = None
+        self._shadow_sub: Optional[Subscription] = None
         self._latest_metrics: Optional[dict] = None
+        self._run_params: Optional[Dict[str, Any]] = None
+        self._account: Optional[Dict[str, Any]] = None
+        self._symbols: Dict[str, _SymbolStats] = {}
+        # client_id -> {is_shadow: fills}
+        self._legs: OrderedDict[str, Dict[bool, _FillLeg]] = OrderedDict()
+        self._runs: OrderedDict[str, _RunStats] = OrderedDict()
+        # (fill time, net PnL, realized PnL) within the largest trailing window
+        self._window_fills: Deque[Tuple[datetime, float, float]] = deque()
 
     async def on_startup(self) -> None:
         self.config = load_config()
         self.set_mode(self.config.app_mode)
 
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
 
         subject = self.config.messaging.subjects["performance"]
         self._subscription = await self.messaging.subscribe(
             subject, self._handle_metrics
         )
+        self._executions_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects["executions"], self._handle_execution
+        )
+        self._run_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get("run_started", "run.started"),
+            self._handle_run_started,
+        )
+        self._shadow_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects.get(
+                "executions_shadow", "trading.executions.shadow"
+            ),
+            self._handle_shadow_execution,
+        )
         self._summary_task = asyncio.create_task(self._publish_summary_loop())
 
     async def on_shutdown(self) -> None:
-        if self._subscription:
-            await self._subscription.unsubscribe()
-            self._subscription = None
-
         if self._summary_task:
             self._summary_task.cancel()
             try:
@@ -58,17 +234,290 @@ class ReporterService(BaseService):
                 pass
             self._summary_task = None
 
+        # Closing drains the subscriptions so in-flight callbacks finish;
+        # the handles are only released afterwards.
         if self.messaging:
             await self.messaging.close()
             self.messaging = None
 
+        for sub in (
+            self._subscription,
+            self._executions_sub,
+            self._run_sub,
+            self._shadow_sub,
+        ):
+            if sub is None:
+                continue
+            try:
+                await sub.unsubscribe()
+            except Exception as exc:
+                logger.debug("Subscription %s already closed: %s", sub.subject, exc)
+        self._subscription = None
+        self._executions_sub = None
+        self._run_sub = None
+        self._shadow_sub = None
+
         self._latest_metrics = None
+        self._run_params = None
+        self._account = None
+        self._symbols.clear()
+        self._legs.clear()
+        self._runs.clear()
+        self._window_fills.clear()
 
     async def _handle_metrics(self, msg: Msg) -> None:
         try:
-            self._latest_metrics = json.loads(msg.data.decode("utf-8"))
-        except json.JSONDecodeError:
+            metrics = decode_payload(msg.data)
+        except ValueError:
             self._latest_metrics = None
+            return
+        if not self.drop_stale_message("performance", metrics):
+            self._latest_metrics = metrics
+
+    async def _handle_execution(self, msg: Msg) -> None:
+        try:
+            report = decode_payload(msg.data)
+        except ValueError:
+            return
+        if isinstance(report, dict):
+            self.record_leg(report)
+            self.record_execution(report)
+
+    async def _handle_shadow_execution(self, msg: Msg) -> None:
+        try:
+            report = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        if isinstance(report, dict):
+            self.record_leg(report)
+
+    async def _handle_run_started(self, msg: Msg) -> None:
+        try:
+            payload = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        if isinstance(payload, dict):
+            self._run_params = self.run_params(payload)
+            if payload.get("run_id"):
+                self._run_stats(str(payload["run_id"]))
+
+    @staticmethod
+    def run_params(run_started: Dict[str, Any]) -> Dict[str, Any]:
+        """Key parameters of a ``run.started`` message for the summary."""
+        paper = run_started.get("paper") or {}
+        return {
+            "run_id": run_started.get("run_id"),
+            "mode": run_started.get("mode"),
+            "seed": run_started.get("seed"),
+            **{key: paper[key] for key in _RUN_PAPER_PARAMS if key in paper},
+        }
+
+    def record_execution(self, report: Dict[str, Any]) -> None:
+        """Fold a fill into its symbol's breakdown; rejects carry nothing."""
+        if not report.get("executed"):
+            return
+        try:
+            quantity = float(report.get("quantity") or 0.0)
+            shortfall = float(report.get("shortfall_bps") or 0.0)
+            realized_pnl = float(report.get("realized_pnl") or 0.0)
+            funding = float(report.get("funding") or 0.0)
+            fees = float(report.get("fees") or 0.0)
+            equity = (
+                float(report["equity"]) if report.get("equity") is not None else None
+            )
+        except (TypeError, ValueError):
+            return
+        if quantity <= 0:
+            return
+        if report.get("run_id") and not report.get("is_shadow"):
+            self._run_stats(str(report["run_id"])).add_fill(
+                fees, realized_pnl - fees - funding, equity
+            )
+        if not report.get("is_shadow"):
+            filled_at = _payload_timestamp(report.get("timestamp")) or datetime.now(
+                timezone.utc
+            )
+            self._record_window_fill(filled_at, realized_pnl - fees - funding, realized_pnl)
+        if report.get("equity") is not None and not report.get("is_shadow"):
+            self._account = {
+                "balance": report.get("balance"),
+                "equity": report["equity"],
+                "timestamp": report.get("timestamp"),
+            }
+        stats = self._symbols.setdefault(report.get("symbol", ""), _SymbolStats())
+        stats.fills += 1
+        stats.quantity += quantity
+        stats.weighted_shortfall_bps += shortfall * quantity
+        stats.realized_pnl += realized_pnl
+        # Positive funding is paid (longs at a positive rate), negative received
+        if funding > 0:
+            stats.funding_paid += funding
+        else:
+            stats.funding_received -= funding
+
+    def record_leg(self, report: Dict[str, Any]) -> None:
+        """Track a report against its ``client_id`` for shadow/primary pairing."""
+        client_id = report.get("client_id")
+        if not client_id:
+            return
+        legs = self._legs.get(client_id)
+        if legs is None:
+            legs = self._legs[client_id] = {}
+            while len(self._legs) > _DIVERGENCE_WINDOW:
+                self._legs.popitem(last=False)
+        leg = legs.setdefault(bool(report.get("is_shadow")), _FillLeg())
+        if not report.get("executed"):
+            return
+        try:
+            quantity = float(report.get("quantity") or 0.0)
+            price = float(report.get("price") or 0.0)
+            latency = report.get("latency_ms")
+            latency = float(latency) if latency is not None else None
+        except (TypeError, ValueError):
+            return
+        if quantity <= 0 or price <= 0:
+            return
+        leg.quantity += quantity
+        leg.notional += quantity * price
+        if leg.latency_ms is None:
+            leg.latency_ms = latency
+
+    def divergence_summary(self) -> Optional[Dict[str, Any]]:
+        """Shadow minus primary over client ids seen on both; ``None`` if none are.
+
+        Price and latency differences average the pairs where both sides
+        filled; fill rates are the share of paired client ids with any fill.
+        """
+        pairs = [
+            (legs[False], legs[True])
+            for legs in self._legs.values()
+            if False in legs and True in legs
+        ]
+        if not pairs:
+            return None
+        filled = [
+            (primary, shadow)
+            for primary, shadow in pairs
+            if primary.quantity > 0 and shadow.quantity > 0
+        ]
+        price_diffs = [
+            (shadow.vwap - primary.vwap) / primary.vwap * 10_000
+            for primary, shadow in filled
+        ]
+        latency_diffs = [
+            shadow.latency_ms - primary.latency_ms
+            for primary, shadow in filled
+            if primary.latency_ms is not None and shadow.latency_ms is not None
+        ]
+        primary_rate = sum(primary.quantity > 0 for primary, _ in pairs) / len(pairs)
+        shadow_rate = sum(shadow.quantity > 0 for _, shadow in pairs) / len(pairs)
+        return {
+            "pairs": len(pairs),
+            "filled_pairs": len(filled),
+            "avg_price_diff_bps": (
+                round(sum(price_diffs) / len(price_diffs), 4) if price_diffs else 0.0
+            ),
+            "max_abs_price_diff_bps": (
+                round(max(abs(diff) for diff in price_diffs), 4) if price_diffs else 0.0
+            ),
+            "avg_latency_diff_ms": (
+                round(sum(latency_diffs) / len(latency_diffs), 3)
+                if latency_diffs
+                else 0.0
+            ),
+            "primary_fill_rate": round(primary_rate, 4),
+            "shadow_fill_rate": round(shadow_rate, 4),
+            "fill_rate_diff": round(shadow_rate - primary_rate, 4),
+        }
+
+    def _windows(self) -> Dict[str, float]:
+        return self.config.reporting.windows_s if self.config else {}
+
+    def _record_window_fill(
+        self, filled_at: datetime, net_pnl: float, realized_pnl: float
+    ) -> None:
+        windows = self._windows()
+        if not windows:
+            return
+        self._window_fills.append((filled_at, net_pnl, realized_pnl))
+        horizon = max(windows.values())
+        while (filled_at - self._window_fills[0][0]).total_seconds() > horizon:
+            self._window_fills.popleft()
+
+    def window_summary(self) -> Dict[str, Dict[str, float]]:
+        """Stats over each trailing window, ending at the latest fill time."""
+        if not self._window_fills:
+            return {}
+        latest = max(filled_at for filled_at, _, _ in self._window_fills)
+        return {
+            name: _window_stats(
+                [
+                    (net, realized)
+                    for filled_at, net, realized in self._window_fills
+                    if (latest - filled_at).total_seconds() <= seconds
+                ]
+            )
+            for name, seconds in self._windows().items()
+        }
+
+    def _run_stats(self, run_id: str) -> _RunStats:
+        stats = self._runs.get(run_id)
+        if stats is None:
+            stats = self._runs[run_id] = _RunStats()
+            while len(self._runs) > _RUN_RETENTION:
+                self._runs.popitem(last=False)
+        return stats
+
+    def compare_runs(self, a: str, b: str) -> Optional[Dict[str, Any]]:
+        """Key metrics of runs ``a`` and ``b`` and their deltas (b - a).
+
+        ``pnl`` is net of fees and funding; ``sharpe`` is the unannualised
+        ratio of fill-to-fill equity returns.  ``None`` if either run is unknown.
+        """
+        if a not in self._runs or b not in self._runs:
+            return None
+        metrics_a = self._runs[a].summary()
+        metrics_b = self._runs[b].summary()
+        return {
+            "a": {"run_id": a, **metrics_a},
+            "b": {"run_id": b, **metrics_b},
+            "delta": {key: metrics_b[key] - metrics_a[key] for key in metrics_a},
+        }
+
+    def symbol_summary(self) -> Dict[str, Dict[str, Any]]:
+        """Per-symbol fills, quantity-weighted shortfall, realized PnL and funding.
+
+        Under a reporting currency each entry adds its quote currency and the
+        PnL and funding converted at the configured FX rate.
+        """
+        reporting = self.config.reporting if self.config else None
+        summary: Dict[str, Dict[str, Any]] = {}
+        for symbol, stats in self._symbols.items():
+            entry: Dict[str, Any] = dict(stats.summary())
+            if reporting is not None and reporting.currency:
+                rate = reporting.fx_rate(symbol)
+                entry["quote_currency"] = reporting.quote_currency(symbol)
+                entry["realized_pnl_converted"] = stats.realized_pnl * rate
+                entry["funding_net_converted"] = entry["funding_net"] * rate
+            summary[symbol] = entry
+        return summary
+
+    def converted_pnl(
+        self, per_symbol: Dict[str, Dict[str, Any]]
+    ) -> Optional[Dict[str, Any]]:
+        """Realized PnL and net funding summed in the reporting currency."""
+        reporting = self.config.reporting if self.config else None
+        if reporting is None or not reporting.currency:
+            return None
+        return {
+            "currency": reporting.currency,
+            "realized_pnl": sum(
+                entry["realized_pnl_converted"] for entry in per_symbol.values()
+            ),
+            "funding_net": sum(
+                entry["funding_net_converted"] for entry in per_symbol.values()
+            ),
+        }
 
     async def _publish_summary_loop(self) -> None:
         if self.config is None or self.messaging is None:
@@ -76,8 +525,24 @@ class ReporterService(BaseService):
         subject = self.config.messaging.subjects.get("reports", "reports.performance")
 
         while True:
-            if self._latest_metrics:
-                summary = dict(self._latest_metrics)
+            if self._latest_metrics or self._symbols:
+                summary = dict(self._latest_metrics or {})
+                if self._symbols:
+                    per_symbol = self.symbol_summary()
+                    summary["per_symbol"] = per_symbol
+                    converted = self.converted_pnl(per_symbol)
+                    if converted:
+                        summary["converted_pnl"] = converted
+                windows = self.window_summary()
+                if windows:
+                    summary["windows"] = windows
+                if self._run_params:
+                    summary["run"] = self._run_params
+                if self._account:
+                    summary["account"] = self._account
+                divergence = self.divergence_summary()
+                if divergence:
+                    summary["shadow_divergence"] = divergence
                 summary.setdefault("timestamp", datetime.now(timezone.utc).isoformat())
                 await self.messaging.publish(subject, summary)
             await asyncio.sleep(60.0)
@@ -85,3 +550,19 @@ class ReporterService(BaseService):
 
 service = ReporterService()
 app: FastAPI = create_app(service)
+
+
+@app.get("/compare")
+async def compare_runs(
+    a: str = Query(...), b: str = Query(...)
+) -> Dict[str, Any]:
+    """Side-by-side metrics of runs ``a`` and ``b``; 404 if either is unknown."""
+    comparison = service.compare_runs(a, b)
+    if comparison is None:
+        unknown = [run for run in (a, b) if run not in service._runs]
+        raise HTTPException(status_code=404, detail=f"Unknown run: {', '.join(unknown)}")
+    return comparison
+
+
+if __name__ == "__main__":
+    run_service(app, key="reporter", default_port=8083)
@@ -2,26 +2,34 @@
 Risk state publisher implemented with FastAPI.
 
 Computes real risk metrics from database positions and PnL, then publishes
-to downstream consumers (strategy, dashboard) at a fixed cadence.
+to downstream consumers (strategy, dashboard) at a fixed cadence.  The
+published ``position_size_factor`` targets a configured volatility using the
+realized volatility of the market-data stream.
 """
 
 from __future__ import annotations
 
 import asyncio
 import logging
+import math
+import statistics
+import time
+from collections import deque
 from datetime import datetime, timezone
-from typing import Optional
+from typing import Any, Deque, Dict, Optional, Tuple
 
 from fastapi import FastAPI
 
 from ..config import TradingBotConfig, load_config
 from ..database import DatabaseManager
-from ..messaging import MessagingClient
+from ..messaging import MessagingClient, decode_payload
 from ..metrics import CIRCUIT_BREAKERS
-from .base import BaseService, create_app
+from .base import BaseService, create_app, run_service
 
 logger = logging.getLogger(__name__)
 
+_SECONDS_PER_YEAR = 365.0 * 24 * 3600
+
 
 class RiskService(BaseService):
     """Real risk-state publisher derived from database positions and PnL."""
@@ -36,6 +44,14 @@ class RiskService(BaseService):
         self._peak_equity: float = 0.0
         self._consecutive_losses: int = 0
         self._crisis: bool = False
+        # Monotonic time of the last crisis trip, for the cooldown
+        self._crisis_tripped_at: Optional[float] = None
+        self._latest_state: Optional[Dict[str, Any]] = None
+        self._query_sub: Any = None
+        self._market_sub: Any = None
+        # Per-symbol (log return, seconds elapsed) samples for realized vol
+        self._returns: Dict[str, Deque[Tuple[float, float]]] = {}
+        self._last_marks: Dict[str, Tuple[float, datetime]] = {}
 
     async def on_startup(self) -> None:
         self.config = load_config()
@@ -47,8 +63,19 @@ class RiskService(BaseService):
             f"{self.name}-{datetime.now(timezone.utc).strftime('%Y%m%d%H%M%S')}"
         )
 
-        self.messaging = MessagingClient({"servers": self.config.messaging.servers})
+        self.messaging = MessagingClient(
+            {
+                "servers": self.config.messaging.servers,
+                "encoding": self.config.messaging.encoding,
+            }
+        )
         await self.messaging.connect()
+        self._query_sub = await self.messaging.subscribe(
+            self.config.messaging.subjects["risk_query"], self._handle_query
+        )
+        self._market_sub = await self.messaging.subscribe(
+            self.config.messaging.market_data_subscription(), self._handle_market_data
+        )
 
         self._task = asyncio.create_task(self._run())
 
@@ -61,14 +88,133 @@ class RiskService(BaseService):
                 pass
             self._task = None
 
+        # Closing drains the subscriptions so in-flight callbacks finish;
+        # the handles are only released afterwards.
         if self.messaging:
             await self.messaging.close()
             self.messaging = None
 
+        for sub in (self._query_sub, self._market_sub):
+            if sub is None:
+                continue
+            try:
+                await sub.unsubscribe()
+            except Exception:
+                logger.debug("Failed to unsubscribe %s", getattr(sub, "subject", sub))
+        self._query_sub = None
+        self._market_sub = None
+
         if self.database:
             await self.database.close()
             self.database = None
 
+    async def _handle_query(self, msg: Any) -> None:
+        """Reply to ``risk.query`` with the last published state (``None`` before the first)."""
+        if self.messaging is None:
+            return
+        try:
+            request = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        reply_to = request.get("reply_to") if isinstance(request, dict) else None
+        if reply_to:
+            await self.messaging.publish(reply_to, {"state": self._latest_state})
+
+    async def _handle_market_data(self, msg: Any) -> None:
+        try:
+            data = decode_payload(msg.data)
+        except (ValueError, AttributeError):
+            return
+        if not isinstance(data, dict) or not data.get("symbol"):
+            return
+        price = float(data.get("last_price") or 0.0)
+        if price <= 0:
+            bid = float(data.get("best_bid") or 0.0)
+            ask = float(data.get("best_ask") or 0.0)
+            price = (bid + ask) / 2 if bid > 0 and ask > 0 else 0.0
+        if price <= 0:
+            return
+        try:
+            ts = datetime.fromisoformat(data["timestamp"])
+        except (KeyError, TypeError, ValueError):
+            ts = datetime.now(timezone.utc)
+        self.record_price(data["symbol"], price, ts)
+
+    def record_price(self, symbol: str, price: float, timestamp: datetime) -> None:
+        """Add a mark to ``symbol``'s realized-vol window."""
+        if timestamp.tzinfo is None:
+            timestamp = timestamp.replace(tzinfo=timezone.utc)
+        previous = self._last_marks.get(symbol)
+        self._last_marks[symbol] = (price, timestamp)
+        if previous is None:
+            return
+        prev_price, prev_ts = previous
+        elapsed = (timestamp - prev_ts).total_seconds()
+        if elapsed <= 0:
+            return
+        window = self.config.risk_management.vol_target.window if self.config else 60
+        samples = self._returns.get(symbol)
+        if samples is None or samples.maxlen != window:
+            samples = deque(samples or (), maxlen=window)
+            self._returns[symbol] = samples
+        samples.append((math.log(price / prev_price), elapsed))
+
+    def realized_vol(self) -> float:
+        """Annualised realized volatility, the highest across tracked symbols."""
+        highest = 0.0
+        for samples in self._returns.values():
+            if len(samples) < 2:
+                continue
+            returns = [r for r, _ in samples]
+            mean_elapsed = sum(dt for _, dt in samples) / len(samples)
+            vol = statistics.stdev(returns) * math.sqrt(_SECONDS_PER_YEAR / mean_elapsed)
+            highest = max(highest, vol)
+        return highest
+
+    def vol_target_factor(self, realized_vol: float) -> float:
+        """``min(1, target_vol / realized_vol)`` clamped to the configured range."""
+        settings = self.config.risk_management.vol_target
+        factor = 1.0 if realized_vol <= 0 else min(1.0, settings.target_vol / realized_vol)
+        return min(settings.max_factor, max(settings.min_factor, factor))
+
+    def _update_crisis(self, drawdown: float, now: float) -> float:
+        """Trip or clear crisis mode; return the seconds of cooldown left.
+
+        Crisis trips on excessive drawdown or consecutive losses and clears
+        once both recover, but not before ``cooldown_seconds`` after the trip.
+        """
+        previous_crisis = self._crisis
+        crisis_threshold = self.config.risk.get("crisis_drawdown", 0.10) if hasattr(self.config, "risk") and isinstance(getattr(self.config, "risk", None), dict) else 0.10
+        loss_threshold = 5
+        cooldown = self.config.risk_management.crisis_mode.cooldown_seconds
+
+        if drawdown >= crisis_threshold or self._consecutive_losses >= loss_threshold:
+            if not self._crisis:
+                self._crisis_tripped_at = now
+            self._crisis = True
+
+        remaining = 0.0
+        if self._crisis and self._crisis_tripped_at is not None:
+            remaining = max(0.0, cooldown - (now - self._crisis_tripped_at))
+
+        recovered = (
+            drawdown < crisis_threshold * 0.5
+            # At least one loss-free update, however low the loss trip is
+            and self._consecutive_losses < max(loss_threshold // 2, 1)
+        )
+        if self._crisis and recovered and remaining <= 0:
+            self._crisis = False
+            self._crisis_tripped_at = None
+
+        mode = self.config.app_mode
+        if self._crisis and not previous_crisis:
+            CIRCUIT_BREAKERS.labels(mode=mode).inc()
+            logger.warning("Crisis mode ACTIVATED: drawdown=%.2f%%, consecutive_losses=%d",
+                           drawdown * 100, self._consecutive_losses)
+        elif not self._crisis and previous_crisis:
+            logger.info("Crisis mode deactivated")
+        return remaining
+
     async def _run(self) -> None:
         if self.config is None or self.messaging is None or self.database is None:
             raise RuntimeError("RiskService started before initialisation")
@@ -115,33 +261,31 @@ class RiskService(BaseService):
             except Exception as exc:
                 logger.debug("Position query failed (ok on first run): %s", exc)
 
-            # Crisis mode: triggered by excessive drawdown or consecutive losses
-            previous_crisis = self._crisis
-            crisis_threshold = self.config.risk.get("crisis_drawdown", 0.10) if hasattr(self.config, "risk") and isinstance(getattr(self.config, "risk", None), dict) else 0.10
-            loss_threshold = 5
-
-            if drawdown >= crisis_threshold or self._consecutive_losses >= loss_threshold:
-                self._crisis = True
-            elif drawdown < crisis_threshold * 0.5 and self._consecutive_losses < loss_threshold // 2:
-                self._crisis = False
+            # Target-vol sizing, further reduced by the exposure brake above
+            volatility = self.realized_vol()
+            vol_settings = self.config.risk_management.vol_target
+            position_factor = min(
+                vol_settings.max_factor,
+                max(
+                    vol_settings.min_factor,
+                    self.vol_target_factor(volatility) * position_factor,
+                ),
+            )
 
-            if self._crisis and not previous_crisis:
-                CIRCUIT_BREAKERS.labels(mode=mode).inc()
-                logger.warning("Crisis mode ACTIVATED: drawdown=%.2f%%, consecutive_losses=%d",
-                               drawdown * 100, self._consecutive_losses)
-            elif not self._crisis and previous_crisis:
-                logger.info("Crisis mode deactivated")
+            cooldown_remaining = self._update_crisis(drawdown, time.monotonic())
 
             payload = {
                 "crisis_mode": self._crisis,
+                "crisis_cooldown_remaining_s": round(cooldown_remaining, 1),
                 "consecutive_losses": self._consecutive_losses,
                 "drawdown": round(drawdown, 6),
-                "volatility": 0.0,  # Populated by downstream market data consumers
+                "volatility": round(volatility, 6),
                 "position_size_factor": round(position_factor, 4),
                 "timestamp": datetime.now(timezone.utc).isoformat(),
             }
 
             await self.messaging.publish(subject, payload)
+            self._latest_state = payload
 
             if self._run_id and hasattr(self.database, "record_risk_snapshot"):
                 try:
@@ -156,3 +300,7 @@ class RiskService(BaseService):
 
 service = RiskService()
 app: FastAPI = create_app(service)
+
+
+if __name__ == "__main__":
+    run_service(app, key="risk", default_port=8084)
@@ -4,7 +4,6 @@ ladder entries, dual stops, and crisis mode.
 """
 
 import asyncio
-import json
 import logging
 from datetime import datetime, timezone
 from typing import Any, Dict, List, Optional
@@ -16,7 +15,7 @@ from .database import DatabaseManager, Order, Position, Trade
 from .dynamic_strategy import DynamicStrategyEngine
 from .exchange import IExchange
 from .indicators import TechnicalIndicators
-from .messaging import MessagingClient
+from .messaging import MessagingClient, decode_payload
 from .models import (
     ConfidenceScore,
     MarketRegime,
@@ -151,7 +150,7 @@ class TradingStrategy:
     async def _handle_execution_report(self, msg):
         "Handle execution reports received from the messaging system."
         try:
-            report = json.loads(msg.data)
+            report = decode_payload(msg.data)
             is_shadow = report.get("is_shadow", False)
 
             if report["executed"]:
@@ -0,0 +1,48 @@
+"""Tests for GET /api/config — the effective configuration endpoint."""
+
+import json
+from unittest.mock import patch
+
+from src.api.routes.system import effective_config
+from src.config import PaperConfig, TradingBotConfig
+
+
+def _config():
+    config = TradingBotConfig(
+        config_paths={
+            "strategy": "config/strategy.yaml",
+            "risk": "config/risk.yaml",
+            "venues": "config/venues.yaml",
+        },
+        paper=PaperConfig(seed=7),
+        api_port=8000,
+        db_url="postgresql://bot:hunter2@db:5432/trading",
+    )
+    config.exchange.api_key = "key"
+    return config
+
+
+class TestConfigEndpoint:
+
+    async def test_returns_full_config_without_secrets(self, monkeypatch):
+        monkeypatch.setenv("API_KEY", "ops-token")
+        with patch("src.api.routes.system.get_config", return_value=_config()):
+            body = await effective_config()
+
+        assert body["paper"]["seed"] == 7
+        assert body["api_port"] == 8000
+        assert "subjects" in body["messaging"]
+        assert "api_key" not in body["exchange"]
+        assert body["exchange"]["api_key_configured"] is True
+        assert body["exchange"]["secret_key_configured"] is False
+        assert body["api_key_configured"] is True
+        assert body["db_url"] == "postgresql://bot:***@db:5432/trading"
+        assert "hunter2" not in json.dumps(body)
+        assert "ops-token" not in json.dumps(body)
+
+    async def test_reports_missing_api_key(self, monkeypatch):
+        monkeypatch.delenv("API_KEY", raising=False)
+        with patch("src.api.routes.system.get_config", return_value=_config()):
+            body = await effective_config()
+
+        assert body["api_key_configured"] is False
@@ -46,3 +46,15 @@ def test_app_mode_override():
         assert "API key" in str(e)
     finally:
         del os.environ["APP_MODE"]
+
+
+def test_default_symbol_rejects_blank():
+    """Blank default symbols are rejected; valid ones are stripped."""
+    from src.config import FeedConfig, ReplayConfig
+
+    assert ReplayConfig(default_symbol=" ETHUSDT ").default_symbol == "ETHUSDT"
+    assert FeedConfig().default_symbol is None
+    with pytest.raises(ValueError):
+        ReplayConfig(default_symbol="  ")
+    with pytest.raises(ValueError):
+        FeedConfig(default_symbol="")
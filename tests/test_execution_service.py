@@ -0,0 +1,575 @@
+"""Tests for src/services/execution.py."""
+
+import asyncio
+import json
+import threading
+from collections import deque
+from datetime import datetime, timedelta, timezone
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+from prometheus_client import REGISTRY
+
+from src.config import LatencyConfig, PaperConfig, PartialFillConfig, TradingBotConfig
+from src.database import DatabaseManager
+from src.models import MarketSnapshot
+from src.paper_trader import PaperBroker
+from src.services.execution import ExecutionService
+
+
+def _msg(payload):
+    msg = MagicMock()
+    msg.data = json.dumps(payload).encode("utf-8")
+    return msg
+
+
+def _service(stale_after=30.0):
+    svc = ExecutionService()
+    svc.config = MagicMock()
+    svc.config.app_mode = "paper"
+    svc.config.paper = PaperConfig(
+        market_data_stale_after_s=stale_after,
+        symbol_aliases={"XBTUSD": "BTCUSDT"},
+    )
+    svc.config.messaging.subjects = {"executions": "trading.executions"}
+    svc.config.messaging.max_message_age_s = None
+    svc.broker = MagicMock()
+    svc.broker.update_market = AsyncMock()
+    svc.broker.place_order = AsyncMock()
+    svc.messaging = MagicMock()
+    svc.messaging.publish = AsyncMock()
+    return svc
+
+
+_TICK = {"symbol": "XBTUSD", "best_bid": 100.0, "best_ask": 101.0, "last_price": 100.5}
+_ORDER = {"symbol": "BTCUSDT", "side": "buy", "quantity": 0.01, "client_id": "c1"}
+
+
+class TestMarketDataStaleness:
+
+    async def test_order_rejected_once_data_goes_stale(self):
+        svc = _service(stale_after=30.0)
+        with patch("src.services.execution.time.monotonic", return_value=1000.0):
+            await svc._handle_market_data(_msg(_TICK))
+        with patch("src.services.execution.time.monotonic", return_value=1031.0):
+            await svc._handle_order(_msg(_ORDER))
+
+        svc.broker.place_order.assert_not_called()
+        report = svc.messaging.publish.call_args.args[1]
+        assert report["error"] == "market_data_stale"
+        assert report["reason"] == "market_data_stale"
+        assert report["error_code"] == "ERR_MARKET_DATA_STALE"
+
+    async def test_fresh_data_lets_order_through(self):
+        svc = _service(stale_after=30.0)
+        with patch("src.services.execution.time.monotonic", return_value=1000.0):
+            await svc._handle_market_data(_msg(_TICK))
+        with patch("src.services.execution.time.monotonic", return_value=1010.0):
+            assert not svc._market_data_stale("BTCUSDT")
+            assert not svc._market_data_stale("XBTUSD")
+
+    def test_guard_disabled_with_zero_timeout(self):
+        svc = _service(stale_after=0.0)
+        svc._last_market_data["BTCUSDT"] = 0.0
+        assert not svc._market_data_stale("BTCUSDT")
+
+    def test_unseen_symbol_left_to_broker(self):
+        svc = _service()
+        assert not svc._market_data_stale("ETHUSDT")
+
+    def test_guard_off_by_default(self):
+        assert PaperConfig().market_data_stale_after_s == 0.0
+
+    async def test_paused_replay_does_not_reject_orders(self):
+        svc = _service(stale_after=30.0)
+        svc.config.app_mode = "replay"
+        with patch("src.services.execution.time.monotonic", return_value=1000.0):
+            await svc._handle_market_data(_msg(_TICK))
+        # Replay paused for ten minutes before the next order arrives
+        with patch("src.services.execution.time.monotonic", return_value=1600.0):
+            await svc._handle_order(_msg(_ORDER))
+
+        svc.broker.place_order.assert_awaited_once()
+
+
+def _aged(payload, seconds):
+    sent_at = datetime.now(timezone.utc) - timedelta(seconds=seconds)
+    return {**payload, "timestamp": sent_at.isoformat()}
+
+
+class TestMaxMessageAge:
+
+    async def test_backlogged_order_dropped(self):
+        svc = _service(stale_after=0.0)
+        svc.config.messaging.max_message_age_s = 5.0
+        await svc._handle_order(_msg(_aged(_ORDER, 60)))
+
+        svc.broker.place_order.assert_not_called()
+        svc.messaging.publish.assert_not_called()
+
+    async def test_backlogged_market_data_dropped(self):
+        svc = _service()
+        svc.config.messaging.max_message_age_s = 5.0
+        await svc._handle_market_data(_msg(_aged(_TICK, 60)))
+        await svc._handle_market_data(_msg(_aged(_TICK, 1)))
+
+        assert svc.broker.update_market.await_count == 1
+
+    def test_filter_off_in_replay_and_without_timestamp(self):
+        svc = _service()
+        svc.config.messaging.max_message_age_s = 5.0
+        assert not svc.drop_stale_message("orders", _ORDER)
+        svc.config.app_mode = "replay"
+        assert not svc.drop_stale_message("orders", _aged(_ORDER, 60))
+
+    def test_filter_off_by_default(self):
+        svc = _service()
+        assert not svc.drop_stale_message("orders", _aged(_ORDER, 3600))
+
+
+class TestSizeFactor:
+
+    @staticmethod
+    def _scaled_service(factor):
+        svc = _service()
+        svc.config.paper = PaperConfig(respect_size_factor=True)
+        svc._size_factor = factor
+        return svc
+
+    async def test_opening_order_scaled(self):
+        svc = self._scaled_service(0.5)
+
+        await svc._handle_order(_msg(_ORDER))
+
+        assert svc.broker.place_order.await_args.kwargs["quantity"] == pytest.approx(0.005)
+
+    async def test_reduce_only_order_not_scaled(self):
+        svc = self._scaled_service(0.5)
+
+        await svc._handle_order(_msg({**_ORDER, "reduce_only": True}))
+
+        kwargs = svc.broker.place_order.await_args.kwargs
+        assert kwargs["quantity"] == pytest.approx(0.01)
+        assert kwargs["reduce_only"] is True
+
+    async def test_zero_factor_blocks_opening_orders_only(self):
+        svc = self._scaled_service(0.0)
+
+        await svc._handle_order(_msg(_ORDER))
+
+        svc.broker.place_order.assert_not_called()
+        report = svc.messaging.publish.call_args.args[1]
+        assert report["reason"] == "size_factor_zero"
+        assert report["error_code"] == "ERR_SIZE_FACTOR_ZERO"
+
+        await svc._handle_order(_msg({**_ORDER, "reduce_only": True}))
+        assert svc.broker.place_order.await_args.kwargs["quantity"] == pytest.approx(0.01)
+
+
+def _rejects(reason):
+    return REGISTRY.get_sample_value(
+        "execution_order_rejects_total", {"mode": "paper", "reason": reason}
+    ) or 0.0
+
+
+class TestRejectReasons:
+
+    async def test_undecodable_payload_counted(self):
+        svc = _service()
+        before = _rejects("invalid_payload")
+        msg = MagicMock()
+        msg.data = b"not json"
+
+        await svc._handle_order(msg)
+
+        assert _rejects("invalid_payload") == before + 1
+        svc.messaging.publish.assert_not_called()
+
+    @pytest.mark.parametrize(
+        "order, broker_error, reason",
+        [
+            ({"side": "buy", "quantity": 0.01, "client_id": "c1"}, None, "invalid_order"),
+            (_ORDER, RuntimeError("No market data for BTCUSDT"), "no_market_data"),
+            (_ORDER, ValueError("max_order_qty_exceeded"), "max_order_qty_exceeded"),
+            (_ORDER, RuntimeError("broker exploded"), "other"),
+        ],
+    )
+    async def test_reject_reported_and_counted(self, order, broker_error, reason):
+        svc = _service()
+        svc.broker.place_order.side_effect = broker_error
+        before = _rejects(reason)
+
+        await svc._handle_order(_msg(order))
+
+        assert _rejects(reason) == before + 1
+        report = svc.messaging.publish.call_args.args[1]
+        assert report["status"] == "rejected"
+        assert report["reason"] == reason
+
+    def test_unknown_reason_recorded_as_other(self):
+        svc = _service()
+        before = _rejects("other")
+
+        assert svc._record_reject("not_a_reason") == "other"
+        assert _rejects("other") == before + 1
+
+
+class TestSyncAckMode:
+
+    @staticmethod
+    async def _sync_service(database, ack_mode="sync"):
+        svc = _service()
+        svc.config.paper = PaperConfig(
+            ack_mode=ack_mode,
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        )
+        svc.broker = PaperBroker(
+            config=svc.config.paper,
+            database=database,
+            mode="paper",
+            run_id="sync_ack",
+            initial_balance=100000.0,
+        )
+        await svc.broker.update_market(
+            MarketSnapshot(
+                symbol="BTCUSDT",
+                best_bid=49995.0,
+                best_ask=50005.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50000.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        return svc
+
+    @staticmethod
+    def _published_to(svc, subject):
+        return [
+            call.args[1]
+            for call in svc.messaging.publish.call_args_list
+            if call.args[0] == subject
+        ]
+
+    async def test_reply_is_first_fill(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        svc = await self._sync_service(database)
+        try:
+            await svc._handle_order(_msg({**_ORDER, "reply_to": "inbox.1"}))
+            await asyncio.gather(*svc._sync_replies)
+
+            [reply] = self._published_to(svc, "inbox.1")
+            assert reply["executed"] is True
+            assert reply["client_id"] == "c1"
+            assert reply["price"] > 50005.0
+        finally:
+            await svc.broker.close()
+            await database.close()
+
+    async def test_wait_does_not_block_later_orders(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        svc = await self._sync_service(database)
+        svc.config.paper = svc.config.paper.model_copy(
+            update={"sync_ack_timeout_s": 0.05}
+        )
+        try:
+            # Resting limits never fill, so each reply waits out the timeout
+            for client_id in ("c1", "c2"):
+                await svc._handle_order(
+                    _msg(
+                        {
+                            **_ORDER,
+                            "client_id": client_id,
+                            "order_type": "limit",
+                            "price": 40000.0,
+                            "reply_to": f"inbox.{client_id}",
+                        }
+                    )
+                )
+
+            assert len(self._published_to(svc, "trading.executions")) == 2
+            assert self._published_to(svc, "inbox.c1") == []
+            await asyncio.gather(*svc._sync_replies)
+            [reply] = self._published_to(svc, "inbox.c2")
+            assert reply["status"] == "accepted"
+        finally:
+            await svc.broker.close()
+            await database.close()
+
+    async def test_async_mode_ignores_reply_to(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        svc = await self._sync_service(database, ack_mode="async")
+        try:
+            await svc._handle_order(_msg({**_ORDER, "reply_to": "inbox.1"}))
+
+            assert self._published_to(svc, "inbox.1") == []
+            [ack] = self._published_to(svc, "trading.executions")
+            assert ack["executed"] is False
+        finally:
+            await svc.broker.close()
+            await database.close()
+
+    async def test_lifecycle_events_on_ack_subject(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        svc = await self._sync_service(database, ack_mode="async")
+        svc.config.messaging.subjects = {
+            "executions": "trading.executions",
+            "order_acks": "trading.acks",
+        }
+        try:
+            await svc._handle_order(_msg(_ORDER))
+            await svc._handle_order(_msg({**_ORDER, "client_id": "c2", "symbol": "ETHUSDT"}))
+
+            accepted, rejected = self._published_to(svc, "trading.acks")
+            assert accepted["status"] == "accepted"
+            assert rejected["status"] == "rejected"
+            assert rejected["client_id"] == "c2"
+            assert self._published_to(svc, "trading.executions") == []
+        finally:
+            await svc.broker.close()
+            await database.close()
+
+
+class TestPositionReconcile:
+
+    async def test_reconcile_replies_with_discrepancies(self):
+        svc = _service()
+        discrepancy = {
+            "symbol": "BTCUSDT",
+            "position_size": 0.02,
+            "journal_size": 0.01,
+            "difference": 0.01,
+        }
+        svc.broker.reconcile_positions = AsyncMock(return_value=[discrepancy])
+
+        await svc._handle_reconcile(_msg({"reply_to": "inbox.reconcile"}))
+
+        svc.messaging.publish.assert_awaited_once_with(
+            "inbox.reconcile", {"discrepancies": [discrepancy]}
+        )
+
+    async def test_reconcile_without_reply_to_is_ignored(self):
+        svc = _service()
+        svc.broker.reconcile_positions = AsyncMock(return_value=[])
+
+        await svc._handle_reconcile(_msg({}))
+
+        svc.broker.reconcile_positions.assert_not_called()
+        svc.messaging.publish.assert_not_called()
+
+
+class TestOrderAmend:
+
+    async def test_amend_forwarded_to_broker(self):
+        svc = _service()
+        svc.broker.amend_order = AsyncMock()
+
+        await svc._handle_amend(_msg({"client_id": "c1", "new_price": 99.5}))
+
+        svc.broker.amend_order.assert_awaited_once_with(
+            "c1", new_price=99.5, new_quantity=None
+        )
+        svc.messaging.publish.assert_not_called()
+
+    async def test_failed_amend_published_as_reject(self):
+        svc = _service()
+        svc.broker.amend_order = AsyncMock(side_effect=ValueError("unknown_order"))
+
+        await svc._handle_amend(_msg({"client_id": "c1", "new_quantity": 0.5}))
+
+        subject, report = svc.messaging.publish.call_args[0]
+        assert subject == "trading.executions"
+        assert report["client_id"] == "c1"
+        assert report["reason"] == "unknown_order"
+        assert report["error_code"] == "ERR_UNKNOWN_ORDER"
+
+
+class TestSymbolControl:
+
+    async def test_disable_and_enable_forwarded_to_broker(self):
+        svc = _service()
+        svc.broker.set_symbol_enabled = AsyncMock()
+
+        await svc._handle_control(_msg({"command": "disable_symbol", "symbol": "BTCUSDT"}))
+        await svc._handle_control(_msg({"command": "enable_symbol", "symbol": "BTCUSDT"}))
+
+        assert svc.broker.set_symbol_enabled.await_args_list[0].args == ("BTCUSDT", False)
+        assert svc.broker.set_symbol_enabled.await_args_list[1].args == ("BTCUSDT", True)
+
+    async def test_command_without_symbol_ignored(self):
+        svc = _service()
+        svc.broker.set_symbol_enabled = AsyncMock()
+
+        await svc._handle_control(_msg({"command": "disable_symbol"}))
+
+        svc.broker.set_symbol_enabled.assert_not_called()
+
+
+def _fill(ms, quantity, price, status="partially_filled"):
+    return {
+        "client_id": "c1",
+        "symbol": "BTCUSDT",
+        "executed": True,
+        "quantity": quantity,
+        "price": price,
+        "fees": 0.5,
+        "realized_pnl": 2.0,
+        "status": status,
+        "timestamp": f"2024-01-01T00:00:00.{ms:03d}000+00:00",
+    }
+
+
+class TestFillCoalescing:
+
+    @staticmethod
+    def _coalescing_service():
+        svc = _service()
+        svc.config.paper = PaperConfig(coalesce_fills=True, coalesce_window_ms=100.0)
+        return svc
+
+    @staticmethod
+    def _published(svc):
+        return [
+            call.args[1]
+            for call in svc.messaging.publish.call_args_list
+            if call.args[0] == "trading.executions"
+        ]
+
+    async def test_fills_within_window_merge_until_filled(self):
+        svc = self._coalescing_service()
+
+        await svc._publish_execution_report(_fill(0, 1.0, 100.0))
+        await svc._publish_execution_report(_fill(10, 1.0, 102.0))
+        assert self._published(svc) == []
+        await svc._publish_execution_report(_fill(20, 2.0, 104.0, status="filled"))
+
+        [report] = self._published(svc)
+        assert report["quantity"] == pytest.approx(4.0)
+        assert report["price"] == pytest.approx(102.5)
+        assert report["fees"] == pytest.approx(1.5)
+        assert report["realized_pnl"] == pytest.approx(6.0)
+        assert report["coalesced_fills"] == 3
+
+    async def test_fill_past_window_releases_pending(self):
+        svc = self._coalescing_service()
+
+        await svc._publish_execution_report(_fill(0, 1.0, 100.0))
+        await svc._publish_execution_report(_fill(500, 1.0, 101.0))
+
+        [first] = self._published(svc)
+        assert first["quantity"] == pytest.approx(1.0)
+        assert first["coalesced_fills"] == 1
+
+    async def test_partial_fill_released_after_silence(self):
+        svc = _service()
+        svc.config.paper = PaperConfig(coalesce_fills=True, coalesce_window_ms=10.0)
+
+        await svc._publish_execution_report(_fill(0, 1.0, 100.0))
+        assert self._published(svc) == []
+
+        await asyncio.sleep(0.05)
+
+        [report] = self._published(svc)
+        assert report["quantity"] == pytest.approx(1.0)
+        assert report["coalesced_fills"] == 1
+        assert svc._coalescing == {}
+
+    async def test_shutdown_flushes_fill_coalesced_during_drain(self):
+        svc = self._coalescing_service()
+        svc.broker.cancel_open_orders = AsyncMock(return_value=0)
+        svc.broker.close = AsyncMock()
+
+        async def _drain():
+            await svc._publish_execution_report(_fill(0, 1.0, 100.0))
+
+        svc.messaging.close = AsyncMock(side_effect=_drain)
+
+        await svc.on_shutdown()
+
+        [report] = self._published(svc)
+        assert report["quantity"] == pytest.approx(1.0)
+
+
+class TestRecentExecutions:
+
+    async def test_replies_with_filtered_recent_reports(self):
+        svc = _service()
+        svc._recent_reports = deque(maxlen=3)
+        for index, symbol in enumerate(["BTCUSDT", "ETHUSDT", "BTCUSDT", "BTCUSDT"]):
+            await svc._publish_execution_report(
+                {"client_id": f"c{index}", "symbol": symbol, "executed": False}
+            )
+
+        await svc._handle_recent_executions(
+            _msg({"reply_to": "inbox.recent", "symbol": "BTCUSDT", "count": 1})
+        )
+        subject, reply = svc.messaging.publish.call_args[0]
+        assert subject == "inbox.recent"
+        assert [report["client_id"] for report in reply["reports"]] == ["c3"]
+
+        await svc._handle_recent_executions(_msg({"reply_to": "inbox.recent"}))
+        reply = svc.messaging.publish.call_args[0][1]
+        # Bounded: the oldest report was dropped
+        assert [report["client_id"] for report in reply["reports"]] == ["c1", "c2", "c3"]
+
+    async def test_flags_warming_up_until_first_tick(self):
+        svc = _service()
+
+        await svc._handle_recent_executions(_msg({"reply_to": "inbox.recent"}))
+        assert svc.messaging.publish.call_args[0][1]["warming_up"] is True
+
+        svc._last_market_data["BTCUSDT"] = 0.0
+        await svc._handle_recent_executions(_msg({"reply_to": "inbox.recent"}))
+        assert svc.messaging.publish.call_args[0][1]["warming_up"] is False
+
+
+class TestReportJournal:
+
+    async def test_flush_runs_off_the_event_loop(self):
+        svc = _service()
+        svc._journal = MagicMock()
+        loop_thread = threading.get_ident()
+        flush_threads = []
+        svc._journal.flush.side_effect = lambda: flush_threads.append(
+            threading.get_ident()
+        )
+
+        task = asyncio.create_task(svc._flush_journal_loop(0.01))
+        while not flush_threads:
+            await asyncio.sleep(0.01)
+        task.cancel()
+        with pytest.raises(asyncio.CancelledError):
+            await task
+
+        assert flush_threads[0] != loop_thread
+
+
+class TestRunStarted:
+
+    def test_payload_carries_config_without_credentials(self):
+        svc = _service()
+        svc.config = TradingBotConfig(
+            config_paths={
+                "strategy": "config/strategy.yaml",
+                "risk": "config/risk.yaml",
+                "venues": "config/venues.yaml",
+            },
+            paper=PaperConfig(seed=42),
+        )
+        svc.config.exchange.api_key = "key"
+        svc.broker.run_id = "exec-1"
+        svc.broker.seed = 42
+
+        payload = svc.run_started_payload()
+
+        assert payload["run_id"] == "exec-1"
+        assert payload["seed"] == 42
+        assert payload["paper"]["seed"] == 42
+        assert payload["config"]["paper"] == payload["paper"]
+        assert "api_key" not in payload["config"]["exchange"]
+        json.dumps(payload)
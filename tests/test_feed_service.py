@@ -0,0 +1,206 @@
+"""Tests for src/services/feed.py — FeedService."""
+
+import asyncio
+import sys
+import time
+from types import ModuleType
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+
+# Stub nats modules if not installed so the import doesn't fail at collection
+if "nats" not in sys.modules:
+    _nats = ModuleType("nats")
+    _nats_aio = ModuleType("nats.aio")
+    _nats_aio_msg = ModuleType("nats.aio.msg")
+    _nats_aio_sub = ModuleType("nats.aio.subscription")
+    _nats_aio_msg.Msg = MagicMock  # type: ignore[attr-defined]
+    _nats_aio_sub.Subscription = MagicMock  # type: ignore[attr-defined]
+    _nats.aio = _nats_aio  # type: ignore[attr-defined]
+    _nats_aio.msg = _nats_aio_msg  # type: ignore[attr-defined]
+    _nats_aio.subscription = _nats_aio_sub  # type: ignore[attr-defined]
+    sys.modules["nats"] = _nats
+    sys.modules["nats.aio"] = _nats_aio
+    sys.modules["nats.aio.msg"] = _nats_aio_msg
+    sys.modules["nats.aio.subscription"] = _nats_aio_sub
+
+from src.services.feed import FeedService
+
+
+# ---------------------------------------------------------------------------
+# Fixtures
+# ---------------------------------------------------------------------------
+
+def _mock_config(stall_timeout: float = 0.1):
+    """Return a minimal mock config for FeedService."""
+    config = MagicMock()
+    config.app_mode = "paper"
+    config.messaging.servers = ["nats://localhost:4222"]
+    config.messaging.subjects = {"market_data": "market.data"}
+    config.trading.symbols = ["BTCUSDT"]
+    config.feed.stall_timeout_seconds = stall_timeout
+    config.feed.publish_delay_ms = 0.0
+    config.feed.replay_mode = "idle"
+    config.feed.replay_subject = "market.data.feed"
+    return config
+
+
+@pytest.fixture
+def feed():
+    return FeedService()
+
+
+class TestBookImbalance:
+
+    def test_balanced_book_is_zero(self):
+        assert FeedService._book_imbalance(5.0, 5.0) == 0.0
+
+    def test_bounded_to_unit_interval(self):
+        assert FeedService._book_imbalance(3.0, 1.0) == 0.5
+        assert FeedService._book_imbalance(0.0, 2.0) == -1.0
+
+    def test_empty_book_does_not_divide_by_zero(self):
+        assert FeedService._book_imbalance(0.0, 0.0) == 0.0
+
+
+class TestFeedReplayGuard:
+
+    def test_publishes_market_data_outside_replay(self, feed):
+        feed.config = _mock_config()
+        assert feed._publish_subject() == "market.data"
+
+    def test_idle_in_replay_by_default(self, feed):
+        feed.config = _mock_config()
+        feed.config.app_mode = "replay"
+        assert feed._publish_subject() is None
+
+    def test_symbol_routing_spares_replay_subject(self, feed):
+        feed.config = _mock_config()
+        feed.config.messaging.market_data_subject.side_effect = (
+            lambda symbol: f"market.data.{symbol}"
+        )
+        assert feed._symbol_subject("market.data", "BTCUSDT") == "market.data.BTCUSDT"
+        assert feed._symbol_subject("market.data.feed", "BTCUSDT") == "market.data.feed"
+
+    def test_separate_subject_in_replay(self, feed):
+        feed.config = _mock_config()
+        feed.config.app_mode = "replay"
+        feed.config.feed.replay_mode = "separate_subject"
+        assert feed._publish_subject() == "market.data.feed"
+
+    @patch("src.services.feed.CCXTClient")
+    @patch("src.services.feed.MessagingClient")
+    @patch("src.services.feed.load_config")
+    async def test_idle_startup_skips_exchange(
+        self, mock_load_config, MockMessaging, MockClient, feed
+    ):
+        config = _mock_config()
+        config.app_mode = "replay"
+        mock_load_config.return_value = config
+        MockMessaging.return_value = AsyncMock()
+
+        await feed.on_startup()
+
+        MockClient.assert_not_called()
+        assert feed._task is None and feed._watchdog_task is None
+        await feed.on_shutdown()
+
+
+# ---------------------------------------------------------------------------
+# Watchdog tests
+# ---------------------------------------------------------------------------
+
+class TestFeedWatchdog:
+
+    async def test_publish_marks_feed_healthy(self, feed):
+        """A successful publish resets the stall timer and health flag."""
+        feed.config = _mock_config()
+        feed.messaging = AsyncMock()
+        feed.exchange_client = AsyncMock()
+        feed.exchange_client.get_ticker.return_value = {
+            "bid": 100.0,
+            "ask": 101.0,
+            "last": 100.5,
+        }
+        feed._healthy = False
+        feed._last_publish_at = 0.0
+
+        await feed._fetch_and_publish("BTCUSDT", "market.data")
+
+        assert feed._healthy is True
+        assert feed._last_publish_at > 0.0
+        feed.messaging.publish.assert_awaited_once()
+
+    @patch("src.services.feed.CCXTClient")
+    async def test_stall_flags_unhealthy_and_reconnects(self, MockClient, feed):
+        """No publishes within the timeout flips health and rebuilds the client."""
+        feed.config = _mock_config(stall_timeout=0.05)
+        stale_client = AsyncMock()
+        feed.exchange_client = stale_client
+        MockClient.return_value = AsyncMock()
+        feed._last_publish_at = time.monotonic() - 1.0
+
+        task = asyncio.create_task(feed._watchdog())
+        await asyncio.sleep(0.06)
+        task.cancel()
+        try:
+            await task
+        except asyncio.CancelledError:
+            pass
+
+        assert feed._healthy is False
+        stale_client.close.assert_awaited()
+        assert feed.exchange_client is MockClient.return_value
+
+    @patch("src.services.feed.CCXTClient")
+    async def test_reconnect_closes_old_client_after_inflight_fetch(
+        self, MockClient, feed
+    ):
+        """A fetch started on the old client finishes before that client closes."""
+        feed.config = _mock_config()
+        feed.messaging = AsyncMock()
+        stale_client = AsyncMock()
+        release = asyncio.Event()
+        events = []
+
+        async def _slow_ticker(symbol):
+            await release.wait()
+            events.append("ticker")
+            return {"bid": 100.0, "ask": 101.0, "last": 100.5}
+
+        stale_client.get_ticker.side_effect = _slow_ticker
+        stale_client.close.side_effect = lambda: events.append("close")
+        feed.exchange_client = stale_client
+        MockClient.return_value = AsyncMock()
+
+        fetch = asyncio.create_task(feed._fetch_and_publish("BTCUSDT", "market.data"))
+        await asyncio.sleep(0)
+        reconnect = asyncio.create_task(feed._reconnect())
+        await asyncio.sleep(0.01)
+
+        assert feed.exchange_client is MockClient.return_value
+        stale_client.close.assert_not_awaited()
+
+        release.set()
+        await asyncio.gather(fetch, reconnect)
+
+        assert events == ["ticker", "close"]
+        feed.messaging.publish.assert_awaited_once()
+
+    async def test_publish_delay_holds_snapshot_back(self, feed):
+        """With publish_delay_ms set, the snapshot is published that much later."""
+        feed.config = _mock_config()
+        feed.config.feed.publish_delay_ms = 50.0
+        feed.messaging = AsyncMock()
+        feed.exchange_client = AsyncMock()
+        feed.exchange_client.get_ticker.return_value = {
+            "bid": 100.0,
+            "ask": 101.0,
+            "last": 100.5,
+        }
+
+        with patch("src.services.feed.asyncio.sleep", new=AsyncMock()) as sleep:
+            await feed._fetch_and_publish("BTCUSDT", "market.data")
+
+        sleep.assert_awaited_once_with(0.05)
+        feed.messaging.publish.assert_awaited_once()
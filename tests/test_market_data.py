@@ -0,0 +1,61 @@
+"""Tests for src/services/market_data.py."""
+
+from unittest.mock import AsyncMock, MagicMock, patch
+
+from src.config import FeedConfig, MessagingConfig
+from src.services.market_data import MarketDataPublisher
+
+
+def _publisher(publish_delay_ms=0.0, **messaging):
+    config = MagicMock()
+    config.feed = FeedConfig(
+        default_symbol="BTCUSDT", publish_delay_ms=publish_delay_ms
+    )
+    config.messaging = MessagingConfig(**messaging)
+    exchange = AsyncMock()
+    exchange.get_ticker.return_value = {"bid": 100.0, "ask": 101.0, "last": 100.5}
+    return MarketDataPublisher(config, exchange, AsyncMock())
+
+
+async def _run_once(publisher):
+    """Run the loop until its first poll-interval sleep, returning the sleeps seen."""
+
+    async def _sleep(seconds):
+        if seconds == 1.0:
+            publisher.running = False
+
+    publisher.running = True
+    sleep = AsyncMock(side_effect=_sleep)
+    with patch("src.services.market_data.asyncio.sleep", new=sleep):
+        await publisher._run_loop()
+    return [call.args[0] for call in sleep.await_args_list]
+
+
+class TestMarketDataPublisher:
+
+    async def test_publishes_on_flat_subject_by_default(self):
+        publisher = _publisher()
+
+        await _run_once(publisher)
+
+        subject, snapshot = publisher.messaging.publish.await_args.args
+        assert subject == "market.data"
+        assert snapshot["symbol"] == "BTCUSDT"
+
+    async def test_publishes_on_templated_subject(self):
+        publisher = _publisher(market_data_subject_template="market.data.{symbol}")
+
+        await _run_once(publisher)
+
+        assert publisher.messaging.publish.await_args.args[0] == "market.data.BTCUSDT"
+
+    async def test_publish_delay_holds_snapshot_back(self):
+        publisher = _publisher(publish_delay_ms=50.0)
+
+        assert await _run_once(publisher) == [0.05, 1.0]
+        publisher.messaging.publish.assert_awaited_once()
+
+    async def test_no_delay_by_default(self):
+        publisher = _publisher()
+
+        assert await _run_once(publisher) == [1.0]
@@ -0,0 +1,158 @@
+"""Tests for src/messaging.py — shutdown semantics, wire codecs and subject routing."""
+
+import asyncio
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+
+from src.messaging import (
+    MessagingClient,
+    MemoryMessagingClient,
+    decode_payload,
+    encode_payload,
+    subject_matches,
+)
+from src.config import MessagingConfig
+
+
+def _client(drain_timeout: float = 1.0) -> MessagingClient:
+    client = MessagingClient(
+        {"servers": ["nats://localhost:4222"], "drain_timeout": drain_timeout}
+    )
+    client.nc = MagicMock()
+    client.nc.is_closed = False
+    client.nc.drain = AsyncMock()
+    client.nc.close = AsyncMock()
+    client.connected = True
+    return client
+
+
+class TestMessagingClose:
+
+    @patch("src.messaging.NATS_AVAILABLE", True)
+    async def test_close_drains_without_unsubscribing_first(self):
+        """Subscriptions are drained by the connection, not dropped up front."""
+        client = _client()
+        sub = AsyncMock()
+        client.subscribers["orders"] = sub
+
+        await client.close()
+
+        client.nc.drain.assert_awaited_once()
+        sub.unsubscribe.assert_not_awaited()
+        assert client.connected is False
+        assert client.subscribers == {}
+
+    @patch("src.messaging.NATS_AVAILABLE", True)
+    async def test_close_falls_back_when_drain_times_out(self):
+        client = _client(drain_timeout=0.01)
+
+        async def _slow_drain():
+            await asyncio.sleep(1.0)
+
+        client.nc.drain = _slow_drain
+
+        await client.close()
+
+        client.nc.close.assert_awaited_once()
+        assert client.connected is False
+
+
+_REPORT = {
+    "order_id": "abc",
+    "fill_id": "abc-1",
+    "executed": True,
+    "price": 50000.5,
+    "quantity": 0.01,
+    "error": "",
+    "stop_price": None,
+}
+
+
+class TestWireEncoding:
+
+    @pytest.mark.parametrize("encoding", ["json", "msgpack"])
+    def test_round_trip(self, encoding):
+        assert decode_payload(encode_payload(_REPORT, encoding)) == _REPORT
+
+    def test_msgpack_is_binary_and_smaller(self):
+        packed = encode_payload(_REPORT, "msgpack")
+        assert packed[0] >= 0x80
+        assert len(packed) < len(encode_payload(_REPORT, "json"))
+
+    def test_decode_accepts_text(self):
+        assert decode_payload('{"a": 1}') == {"a": 1}
+
+    def test_malformed_msgpack_raises_value_error(self):
+        with pytest.raises(ValueError):
+            decode_payload(b"\x85\x01")
+
+    def test_client_defaults_to_json(self):
+        client = MessagingClient({"servers": ["nats://localhost:4222"]})
+        assert client.encoding == "json"
+
+    async def test_memory_bus_publishes_configured_codec(self):
+        bus = MemoryMessagingClient({"encoding": "msgpack"})
+        await bus.connect()
+        received = asyncio.Queue()
+
+        async def _on_msg(msg):
+            await received.put(msg.data)
+
+        await bus.subscribe("trading.executions", _on_msg)
+        await bus.publish("trading.executions", _REPORT)
+        data = await asyncio.wait_for(received.get(), 1.0)
+
+        assert data[0] >= 0x80
+        assert decode_payload(data) == _REPORT
+
+
+class TestSubjectRouting:
+
+    @pytest.mark.parametrize(
+        "pattern, subject, expected",
+        [
+            ("market.data", "market.data", True),
+            ("market.data", "market.data.BTCUSDT", False),
+            ("market.data.*", "market.data.BTCUSDT", True),
+            ("market.data.*", "market.data", False),
+            ("market.data.*", "market.data.BTCUSDT.l2", False),
+            ("market.>", "market.data.BTCUSDT", True),
+            ("market.>", "market", False),
+        ],
+    )
+    def test_subject_matches(self, pattern, subject, expected):
+        assert subject_matches(pattern, subject) is expected
+
+    def test_flat_subject_by_default(self):
+        config = MessagingConfig()
+        assert config.market_data_subject("BTCUSDT") == "market.data"
+        assert config.market_data_subscription() == "market.data"
+
+    def test_template_routes_per_symbol(self):
+        config = MessagingConfig(market_data_subject_template="market.data.{symbol}")
+        assert config.market_data_subject("ETHUSDT") == "market.data.ETHUSDT"
+        assert config.market_data_subscription() == "market.data.*"
+
+    @pytest.mark.parametrize(
+        "template", ["market.data", "market.data.{symbol}.{symbol}", "market.*.{symbol}"]
+    )
+    def test_template_validated(self, template):
+        with pytest.raises(ValueError):
+            MessagingConfig(market_data_subject_template=template)
+
+    async def test_memory_bus_delivers_to_wildcard(self):
+        bus = MemoryMessagingClient()
+        await bus.connect()
+        received = asyncio.Queue()
+
+        async def _on_msg(msg):
+            await received.put(msg.subject)
+
+        await bus.subscribe("market.data.*", _on_msg)
+        await bus.publish("market.data.BTCUSDT", {"symbol": "BTCUSDT"})
+        await bus.publish("market.data", {"symbol": "BTCUSDT"})
+
+        assert await asyncio.wait_for(received.get(), 1.0) == "market.data.BTCUSDT"
+        await asyncio.sleep(0)
+        assert received.empty()
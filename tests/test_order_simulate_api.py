@@ -0,0 +1,98 @@
+"""Tests for POST /api/orders/simulate — the trading.orders.simulate proxy."""
+
+from datetime import datetime, timezone
+from unittest.mock import MagicMock, patch
+
+import pytest
+from fastapi import HTTPException
+
+from src.api.routes.market import PlaceOrderRequest, simulate_order
+from src.config import LatencyConfig, PaperConfig, PartialFillConfig
+from src.database import DatabaseManager
+from src.messaging import MemoryMessagingClient
+from src.models import MarketSnapshot
+from src.paper_trader import PaperBroker
+from src.services.execution import ExecutionService
+
+
+def _config():
+    config = MagicMock()
+    config.messaging.subjects = {"order_simulate": "trading.orders.simulate"}
+    return config
+
+
+async def _bus_with_execution_service(database):
+    bus = MemoryMessagingClient()
+    await bus.connect()
+    svc = ExecutionService()
+    svc.messaging = bus
+    svc.broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=database,
+        mode="paper",
+        run_id="simulate_api",
+        initial_balance=100000.0,
+    )
+    await svc.broker.update_market(
+        MarketSnapshot(
+            symbol="BTCUSDT",
+            best_bid=49995.0,
+            best_ask=50005.0,
+            bid_size=1.0,
+            ask_size=1.0,
+            last_price=50000.0,
+            timestamp=datetime.now(timezone.utc),
+        )
+    )
+    await bus.subscribe("trading.orders.simulate", svc._handle_simulate)
+    return bus, svc.broker
+
+
+class TestOrderSimulateEndpoint:
+
+    async def test_returns_projection_without_executing(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        bus, broker = await _bus_with_execution_service(database)
+        request = PlaceOrderRequest(symbol="BTCUSDT", side="buy", quantity=0.01, type="market")
+
+        try:
+            with patch("src.api.routes.market.get_config", return_value=_config()):
+                body = await simulate_order(request, timeout=0.5, messaging=bus)
+
+            assert body["simulated"] is True
+            assert body["price"] > 50005.0
+            assert body["fees"] > 0
+            assert await broker.get_positions() == []
+        finally:
+            await broker.close()
+            await database.close()
+
+    async def test_400_without_market_data(self):
+        database = DatabaseManager(":memory:")
+        await database.initialize()
+        bus, broker = await _bus_with_execution_service(database)
+        request = PlaceOrderRequest(symbol="ETHUSDT", side="buy", quantity=1.0, type="market")
+
+        try:
+            with patch("src.api.routes.market.get_config", return_value=_config()):
+                with pytest.raises(HTTPException) as exc:
+                    await simulate_order(request, timeout=0.5, messaging=bus)
+            assert exc.value.status_code == 400
+        finally:
+            await broker.close()
+            await database.close()
+
+    async def test_503_when_execution_service_silent(self):
+        bus = MemoryMessagingClient()
+        await bus.connect()
+        request = PlaceOrderRequest(symbol="BTCUSDT", side="buy", quantity=0.01)
+
+        with patch("src.api.routes.market.get_config", return_value=_config()):
+            with pytest.raises(HTTPException) as exc:
+                await simulate_order(request, timeout=0.05, messaging=bus)
+
+        assert exc.value.status_code == 503
@@ -1,10 +1,19 @@
 import asyncio
-from datetime import datetime, timezone
+from datetime import datetime, timedelta, timezone
 
 import pytest
+from prometheus_client import REGISTRY
 
-from src.config import LatencyConfig, PaperConfig, PartialFillConfig
+from src.config import (
+    EstimatorWarmupConfig,
+    InitialPosition,
+    LatencyConfig,
+    PaperConfig,
+    PartialFillConfig,
+    SymbolOverrides,
+)
 from src.database import DatabaseManager
+from src.metrics import GROSS_NOTIONAL, NET_NOTIONAL
 from src.models import MarketSnapshot
 from src.paper_trader import PaperBroker
 
@@ -238,3 +247,2382 @@ async def _test_partial_fill_splits_impl():
 
 def test_partial_fill_splits():
     run_async(_test_partial_fill_splits_impl())
+
+
+async def _test_max_order_qty_rejects_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    paper_config = PaperConfig(
+        latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+        max_order_qty=5.0,
+        max_order_qty_by_symbol={"ETHUSDT": 50.0},
+    )
+    broker = PaperBroker(
+        config=paper_config,
+        database=manager,
+        mode="backtest",
+        run_id="max_qty_test",
+        initial_balance=10000.0,
+    )
+
+    try:
+        for symbol in ("BTCUSDT", "ETHUSDT"):
+            await broker.update_market(
+                MarketSnapshot(
+                    symbol=symbol,
+                    best_bid=100.0,
+                    best_ask=100.1,
+                    bid_size=1.0,
+                    ask_size=1.0,
+                    last_price=100.05,
+                    timestamp=datetime.now(timezone.utc),
+                )
+            )
+
+        with pytest.raises(ValueError, match="max_order_qty_exceeded"):
+            await broker.place_order(
+                symbol="BTCUSDT", side="buy", order_type="market", quantity=6.0
+            )
+
+        # Per-symbol override takes precedence over the global limit
+        order = await broker.place_order(
+            symbol="ETHUSDT", side="buy", order_type="market", quantity=6.0
+        )
+        assert order.quantity == 6.0
+    finally:
+        await manager.close()
+
+
+def test_max_order_qty_rejects():
+    run_async(_test_max_order_qty_rejects_impl())
+
+
+def test_per_symbol_overrides_fall_back_to_globals():
+    paper_config = PaperConfig(
+        slippage_bps=3.0,
+        max_slippage_bps=10.0,
+        latency_ms=LatencyConfig(mean=100.0, p95=200.0),
+        per_symbol={
+            "DOGEUSDT": SymbolOverrides(
+                latency_mean_ms=400.0, latency_p95_ms=900.0, slippage_bps=15.0, max_slippage_bps=40.0
+            ),
+            "ETHUSDT": SymbolOverrides(slippage_bps=5.0),
+        },
+    )
+    broker = PaperBroker(
+        config=paper_config,
+        database=None,
+        mode="backtest",
+        run_id="overrides",
+        initial_balance=10000.0,
+    )
+
+    assert broker._slippage_params("DOGEUSDT") == (15.0, 40.0)
+    assert broker._slippage_params("ETHUSDT") == (5.0, 10.0)
+    assert broker._slippage_params("BTCUSDT") == (3.0, 10.0)
+
+    doge_mean, _ = broker._latency_params("DOGEUSDT")
+    assert doge_mean == 400.0
+    assert broker._latency_params("ETHUSDT") == broker._latency_params("BTCUSDT")
+
+
+def test_per_symbol_overrides_validated_like_globals():
+    with pytest.raises(ValueError, match="max_slippage_bps"):
+        PaperConfig(per_symbol={"BTCUSDT": SymbolOverrides(slippage_bps=50.0)})
+    with pytest.raises(ValueError, match="p95"):
+        PaperConfig(per_symbol={"BTCUSDT": SymbolOverrides(latency_mean_ms=500.0)})
+
+
+def test_latency_samples_clamped_to_bounds():
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=100.0, p95=2000.0, min_ms=50.0, max_ms=150.0),
+        ),
+        database=None,
+        mode="backtest",
+        run_id="latency_bounds",
+        initial_balance=10000.0,
+    )
+    samples = [broker._sample_latency_ms("BTCUSDT") for _ in range(500)]
+    assert min(samples) == 50.0
+    assert max(samples) == 150.0
+
+
+def test_deterministic_latency_returns_mean():
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=100.0, p95=2000.0),
+            per_symbol={"DOGEUSDT": SymbolOverrides(latency_mean_ms=400.0, latency_p95_ms=900.0)},
+            deterministic_latency=True,
+        ),
+        database=None,
+        mode="backtest",
+        run_id="latency_deterministic",
+        initial_balance=10000.0,
+    )
+    state = broker._random.getstate()
+    assert {broker._sample_latency_ms("BTCUSDT") for _ in range(50)} == {100.0}
+    assert broker._sample_latency_ms("DOGEUSDT") == 400.0
+    assert broker._random.getstate() == state
+
+
+def test_latency_bounds_must_contain_mean():
+    with pytest.raises(ValueError, match="min_ms <= mean <= max_ms"):
+        LatencyConfig(mean=100.0, p95=200.0, max_ms=80.0)
+    with pytest.raises(ValueError, match="min_ms <= mean <= max_ms"):
+        LatencyConfig(mean=100.0, p95=200.0, min_ms=120.0)
+    with pytest.raises(ValueError, match="min_ms/max_ms"):
+        PaperConfig(
+            latency_ms=LatencyConfig(mean=100.0, p95=200.0, max_ms=300.0),
+            per_symbol={"BTCUSDT": SymbolOverrides(latency_mean_ms=400.0, latency_p95_ms=500.0)},
+        )
+
+
+async def _test_reset_clears_state_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    paper_config = PaperConfig(latency_ms=LatencyConfig(mean=0.0, p95=0.0))
+    broker = PaperBroker(
+        config=paper_config,
+        database=manager,
+        mode="backtest",
+        run_id="reset_test",
+        initial_balance=10000.0,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        first_draw = broker._random.random()
+        await broker.place_order(
+            symbol=symbol, side="buy", order_type="market", quantity=0.1
+        )
+        await broker.place_order(
+            symbol=symbol, side="buy", order_type="limit", quantity=0.1, price=40000.0
+        )
+        await asyncio.sleep(0.01)
+        assert await broker.get_positions()
+
+        await broker.reset()
+
+        assert await broker.get_positions() == []
+        assert await broker.get_open_orders() == []
+        assert (await broker.get_account_balance())["totalWalletBalance"] == 10000.0
+        assert broker._maker_fills == 0 and broker._taker_fills == 0
+        assert broker._random.random() == first_draw
+    finally:
+        await manager.close()
+
+
+def test_reset_clears_state():
+    run_async(_test_reset_clears_state_impl())
+
+
+async def _test_reset_discards_due_fills_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+            fill_workers=1,
+        ),
+        database=manager,
+        mode="paper",
+        run_id="reset_due_fills",
+        initial_balance=10000.0,
+    )
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+
+        # Hold the book so both fills fall due: the one worker takes the
+        # first and blocks on the lock, the second waits in the ready queue
+        await broker._lock.acquire()
+        await asyncio.sleep(0.01)
+        assert broker._ready_fills.qsize() == 1
+        broker._lock.release()
+
+        await broker.reset()
+        await asyncio.sleep(0.05)
+
+        assert await broker.get_positions() == []
+        assert (await broker.get_account_balance())["totalWalletBalance"] == 10000.0
+        assert broker._ready_fills.qsize() == 0
+        assert (await broker.get_stats())["scheduled_fills"] == 0
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reset_discards_due_fills():
+    run_async(_test_reset_discards_due_fills_impl())
+
+
+async def _test_min_commission_floor_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    paper_config = PaperConfig(
+        fee_bps=5.0,
+        min_commission=0.5,
+        latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+        partial_fill=PartialFillConfig(enabled=False),
+    )
+    broker = PaperBroker(
+        config=paper_config,
+        database=manager,
+        mode="backtest",
+        run_id="min_commission_test",
+        initial_balance=10000.0,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        # 0.0001 BTC * ~50k * 5bps ~= 0.0025 in fees, well under the floor
+        await broker.place_order(
+            symbol=symbol, side="buy", order_type="market", quantity=0.0001
+        )
+        await asyncio.sleep(0.01)
+
+        trades = await manager.get_trades(symbol=symbol, run_id="min_commission_test")
+        assert len(trades) == 1
+        assert trades[0].fees == pytest.approx(0.5)
+
+    finally:
+        await manager.close()
+
+
+def test_min_commission_floor():
+    run_async(_test_min_commission_floor_impl())
+
+
+def _fee_floor_broker(**fees):
+    return PaperBroker(
+        config=PaperConfig(min_commission=0.5, **fees),
+        database=None,
+        mode="backtest",
+        run_id="min_commission_fees",
+        initial_balance=10000.0,
+    )
+
+
+def test_min_commission_leaves_small_rebate_untouched():
+    broker = _fee_floor_broker(maker_rebate_bps=-2.0)
+    assert broker._compute_fee(100.0, 0.001, maker=True) == pytest.approx(-0.00002)
+
+
+def test_min_commission_not_charged_on_zero_fee():
+    broker = _fee_floor_broker(fee_bps=0.0, maker_rebate_bps=0.0)
+    assert broker._compute_fee(100.0, 1.0, maker=False) == 0.0
+    assert broker._compute_fee(100.0, 1.0, maker=True) == 0.0
+
+
+def test_mark_price_source_selection():
+    snapshot = MarketSnapshot(
+        symbol="BTCUSDT",
+        best_bid=99.0,
+        best_ask=101.0,
+        bid_size=1.0,
+        ask_size=1.0,
+        last_price=102.0,
+        index_price=98.5,
+        timestamp=datetime.now(timezone.utc),
+    )
+
+    def _broker(source):
+        return PaperBroker(
+            config=PaperConfig(mark_price_source=source),
+            database=None,
+            mode="backtest",
+            run_id="mark_source",
+            initial_balance=10000.0,
+        )
+
+    assert _broker("mid")._mark_price(snapshot) == 100.0
+    assert _broker("last")._mark_price(snapshot) == 102.0
+    assert _broker("index")._mark_price(snapshot) == 98.5
+
+    no_index = snapshot.model_copy(update={"index_price": None})
+    assert _broker("index")._mark_price(no_index) == 100.0
+
+
+def test_mark_price_source_validated():
+    assert PaperConfig().mark_price_source == "mid"
+    with pytest.raises(ValueError):
+        PaperConfig(mark_price_source="mark")
+
+
+async def _test_resting_limit_expires_in_replay_time_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(latency_ms=LatencyConfig(mean=0.0, p95=0.0)),
+        database=manager,
+        mode="replay",
+        run_id="expiry_test",
+        initial_balance=10000.0,
+        execution_listener=_listener,
+    )
+
+    def _snapshot(ts):
+        return MarketSnapshot(
+            symbol="BTCUSDT",
+            best_bid=50000.0,
+            best_ask=50010.0,
+            bid_size=1.0,
+            ask_size=1.0,
+            last_price=50005.0,
+            timestamp=ts,
+        )
+
+    # Replay clock far from wall clock so expiry cannot lean on datetime.now()
+    start = datetime(2021, 1, 1, tzinfo=timezone.utc)
+    try:
+        await broker.update_market(_snapshot(start))
+        order = await broker.place_order(
+            symbol="BTCUSDT",
+            side="buy",
+            order_type="limit",
+            quantity=0.1,
+            price=40000.0,
+            ttl_seconds=60,
+        )
+        assert order.expires_at == start + timedelta(seconds=60)
+
+        await broker.update_market(_snapshot(start + timedelta(seconds=30)))
+        assert len(await broker.get_open_orders()) == 1
+        assert reports == []
+
+        await broker.update_market(_snapshot(start + timedelta(seconds=61)))
+        assert await broker.get_open_orders() == []
+        assert len(reports) == 1
+        assert reports[0]["error"] == "expired"
+        assert reports[0]["error_code"] == "ERR_EXPIRED"
+        assert reports[0]["client_id"] == order.client_id
+        assert not reports[0]["executed"]
+
+        stored = await manager.get_orders(symbol="BTCUSDT")
+        assert [o.status for o in stored] == ["expired"]
+    finally:
+        await manager.close()
+
+
+def test_resting_limit_expires_in_replay_time():
+    run_async(_test_resting_limit_expires_in_replay_time_impl())
+
+
+async def _test_market_partial_reject_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+            partial_reject_rate=1.0,
+        ),
+        database=manager,
+        mode="paper",
+        run_id="partial_reject_test",
+        initial_balance=10000.0,
+        execution_listener=_listener,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        await broker.place_order(
+            symbol=symbol, side="buy", order_type="market", quantity=0.1
+        )
+        await asyncio.sleep(0.05)
+
+        fills = [r for r in reports if r["executed"]]
+        rejects = [r for r in reports if r["error"] == "partial_reject"]
+        assert len(fills) == 1 and len(rejects) == 1
+        filled_qty = fills[0]["quantity"]
+        assert 0 < filled_qty < 0.1
+        assert filled_qty + rejects[0]["rejected_qty"] == pytest.approx(0.1)
+
+        positions = await broker.get_positions()
+        assert positions[0].size == pytest.approx(filled_qty)
+
+        stored = await manager.get_orders(symbol=symbol)
+        assert [o.status for o in stored] == ["canceled"]
+    finally:
+        await manager.close()
+
+
+def test_market_partial_reject():
+    run_async(_test_market_partial_reject_impl())
+
+
+async def _test_fill_worker_pool_bounds_tasks_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=5.0, p95=10.0),
+            partial_fill=PartialFillConfig(
+                enabled=True, min_slice_pct=0.1, max_slices=5, randomize=False
+            ),
+            fill_workers=2,
+        ),
+        database=manager,
+        mode="paper",
+        run_id="fill_pool_test",
+        initial_balance=1_000_000.0,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=10.0,
+                ask_size=10.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        tasks_before = len(asyncio.all_tasks())
+        for _ in range(20):
+            await broker.place_order(
+                symbol=symbol, side="buy", order_type="market", quantity=0.01
+            )
+
+        # 100 fill slices share one dispatcher and two workers
+        assert len(asyncio.all_tasks()) - tasks_before == 3
+
+        await asyncio.sleep(0.2)
+        positions = await broker.get_positions()
+        assert positions[0].size == pytest.approx(0.2)
+        assert broker._scheduled_fills == []
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_fill_worker_pool_bounds_tasks():
+    run_async(_test_fill_worker_pool_bounds_tasks_impl())
+
+
+async def _test_max_in_flight_orders_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            max_in_flight_orders=2,
+        ),
+        database=manager,
+        mode="paper",
+        run_id="in_flight_test",
+        initial_balance=10000.0,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        for price in (40000.0, 41000.0):
+            await broker.place_order(
+                symbol=symbol, side="buy", order_type="limit", quantity=0.01, price=price
+            )
+
+        with pytest.raises(ValueError, match="too_many_in_flight"):
+            await broker.place_order(
+                symbol=symbol, side="buy", order_type="limit", quantity=0.01, price=42000.0
+            )
+
+        # A second broker in the same mode does not take over the gauge
+        PaperBroker(
+            config=PaperConfig(),
+            database=None,
+            mode="paper",
+            run_id="other",
+            initial_balance=10000.0,
+        )
+        assert REGISTRY.get_sample_value(
+            "paper_in_flight_orders", {"mode": "paper"}
+        ) == 2
+
+        # Cancelling frees capacity again
+        await broker.cancel_all_orders(symbol)
+        assert REGISTRY.get_sample_value(
+            "paper_in_flight_orders", {"mode": "paper"}
+        ) == 0
+        await broker.place_order(
+            symbol=symbol, side="buy", order_type="limit", quantity=0.01, price=42000.0
+        )
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_max_in_flight_orders():
+    run_async(_test_max_in_flight_orders_impl())
+
+
+async def _test_bars_price_source_rebuilds_spread_impl():
+    def _broker(source):
+        return PaperBroker(
+            config=PaperConfig(price_source=source, bar_spread_range_pct=0.5),
+            database=None,
+            mode="backtest",
+            run_id="bars_source",
+            initial_balance=10000.0,
+        )
+
+    # A bar with a tight recorded quote but a wide intrabar range
+    snapshot = MarketSnapshot(
+        symbol="BTCUSDT",
+        best_bid=99.99,
+        best_ask=100.01,
+        bid_size=1.0,
+        ask_size=1.0,
+        last_price=100.0,
+        high=102.0,
+        low=98.0,
+        timestamp=datetime.now(timezone.utc),
+    )
+
+    live = _broker("live")
+    await live.update_market(snapshot.model_copy())
+    assert live._market_state["BTCUSDT"].best_ask == 100.01
+
+    bars = _broker("bars")
+    await bars.update_market(snapshot.model_copy())
+    rebuilt = bars._market_state["BTCUSDT"]
+    assert rebuilt.best_bid == pytest.approx(99.0)
+    assert rebuilt.best_ask == pytest.approx(101.0)
+
+
+def test_bars_price_source_rebuilds_spread():
+    run_async(_test_bars_price_source_rebuilds_spread_impl())
+
+
+def test_price_source_validated():
+    with pytest.raises(ValueError):
+        PaperConfig(price_source="ticks")
+
+
+def test_maker_rebate_requires_min_spread():
+    def _broker(gate):
+        return PaperBroker(
+            config=PaperConfig(maker_rebate_bps=-2.0, min_maker_spread_bps=gate),
+            database=None,
+            mode="backtest",
+            run_id="maker_gate",
+            initial_balance=10000.0,
+        )
+
+    ungated = _broker(None)
+    assert ungated._compute_fee(100.0, 1.0, True, spread_bps=0.5) == pytest.approx(-0.02)
+
+    gated = _broker(1.0)
+    assert gated._compute_fee(100.0, 1.0, True, spread_bps=0.5) == 0.0
+    assert gated._compute_fee(100.0, 1.0, True, spread_bps=2.0) == pytest.approx(-0.02)
+    # Taker fees are unaffected by the gate
+    assert gated._compute_fee(100.0, 1.0, False, spread_bps=0.5) == pytest.approx(0.07)
+
+
+async def _test_cancel_open_orders_reports_remaining_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(latency_ms=LatencyConfig(mean=0.0, p95=0.0)),
+        database=manager,
+        mode="paper",
+        run_id="shutdown_cancel_test",
+        initial_balance=10000.0,
+        execution_listener=_listener,
+    )
+
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=50000.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50005.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        limit = await broker.place_order(
+            symbol=symbol, side="buy", order_type="limit", quantity=0.02, price=40000.0
+        )
+        stop = await broker.place_order(
+            symbol=symbol, side="sell", order_type="stop", quantity=0.01, stop_price=45000.0
+        )
+
+        assert await broker.cancel_open_orders("cancelled_on_shutdown") == 2
+        assert await broker.get_open_orders() == []
+
+        by_client = {r["client_id"]: r for r in reports}
+        assert by_client[limit.client_id]["remaining_qty"] == 0.02
+        assert by_client[stop.client_id]["remaining_qty"] == 0.01
+        assert {r["error"] for r in reports} == {"cancelled_on_shutdown"}
+        assert {r["error_code"] for r in reports} == {"ERR_CANCELLED"}
+
+        stored = await manager.get_orders(symbol=symbol)
+        assert {o.status for o in stored} == {"canceled"}
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_cancel_open_orders_reports_remaining():
+    run_async(_test_cancel_open_orders_reports_remaining_impl())
+
+
+async def _test_limit_price_offset_from_mid_impl():
+    broker, manager = await _setup_broker()
+    try:
+        symbol = "BTCUSDT"
+        await broker.update_market(
+            MarketSnapshot(
+                symbol=symbol,
+                best_bid=49990.0,
+                best_ask=50010.0,
+                bid_size=1.0,
+                ask_size=1.0,
+                last_price=50000.0,
+                timestamp=datetime.now(timezone.utc),
+            )
+        )
+        buy = await broker.place_order(
+            symbol=symbol, side="buy", order_type="limit", quantity=0.01, price_offset_bps=-5
+        )
+        assert buy.price == pytest.approx(49975.0)
+        assert buy.price_offset_bps == -5
+
+        with pytest.raises(ValueError, match="requires a limit order"):
+            await broker.place_order(
+                symbol=symbol, side="buy", order_type="market", quantity=0.01, price_offset_bps=5
+            )
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_limit_price_offset_from_mid():
+    run_async(_test_limit_price_offset_from_mid_impl())
+
+
+def test_report_rounding_per_symbol():
+    broker = PaperBroker(
+        config=PaperConfig(
+            per_symbol={"BTCUSDT": SymbolOverrides(price_precision=2, qty_precision=3)}
+        ),
+        database=None,
+        mode="backtest",
+        run_id="rounding",
+        initial_balance=10000.0,
+    )
+    report = {
+        "symbol": "BTCUSDT",
+        "price": 50010.123456,
+        "quantity": 0.0123456,
+        "fees": 0.35007001,
+        "realized_pnl": -1.0000000001,
+    }
+    assert broker._round_report(dict(report)) == {
+        "symbol": "BTCUSDT",
+        "price": 50010.12,
+        "quantity": 0.012,
+        "fees": 0.35,
+        "realized_pnl": -1.0,
+    }
+
+    # Symbols without precision overrides are reported at full precision
+    other = dict(report, symbol="ETHUSDT")
+    assert broker._round_report(dict(other)) == other
+
+
+async def _test_apply_config_patch_impl():
+    broker = PaperBroker(
+        config=PaperConfig(
+            slippage_bps=3.0,
+            max_slippage_bps=10.0,
+            latency_ms=LatencyConfig(mean=100.0, p95=200.0),
+        ),
+        database=None,
+        mode="backtest",
+        run_id="patch",
+        initial_balance=10000.0,
+    )
+
+    patched = await broker.apply_config_patch(
+        {"slippage_bps": 6.0, "latency_ms": {"mean": 50.0}}
+    )
+    assert patched.slippage_bps == 6.0
+    assert patched.latency_ms.mean == 50.0 and patched.latency_ms.p95 == 200.0
+    assert broker._latency_params("BTCUSDT")[0] == 50.0
+
+    # Invalid merged result and non-patchable fields leave the config untouched
+    with pytest.raises(ValueError):
+        await broker.apply_config_patch({"slippage_bps": 50.0})
+    with pytest.raises(ValueError, match="Unpatchable"):
+        await broker.apply_config_patch({"fee_bps": 0.0})
+    assert broker.config.slippage_bps == 6.0
+
+
+def test_apply_config_patch():
+    run_async(_test_apply_config_patch_impl())
+
+
+async def _test_max_open_orders_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            max_open_orders_per_symbol=2,
+            max_open_orders=3,
+        ),
+        database=manager,
+        mode="paper",
+        run_id="open_orders_test",
+        initial_balance=100000.0,
+    )
+
+    def _snapshot(symbol, price):
+        return MarketSnapshot(
+            symbol=symbol,
+            best_bid=price - 5,
+            best_ask=price + 5,
+            bid_size=1.0,
+            ask_size=1.0,
+            last_price=price,
+            timestamp=datetime.now(timezone.utc),
+        )
+
+    try:
+        await broker.update_market(_snapshot("BTCUSDT", 50000.0))
+        await broker.update_market(_snapshot("ETHUSDT", 3000.0))
+
+        for price in (40000.0, 41000.0):
+            await broker.place_order(
+                symbol="BTCUSDT", side="buy", order_type="limit", quantity=0.01, price=price
+            )
+        with pytest.raises(ValueError, match="max_open_orders"):
+            await broker.place_order(
+                symbol="BTCUSDT", side="buy", order_type="limit", quantity=0.01, price=42000.0
+            )
+
+        # Marketable orders never rest, so the caps do not apply to them
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.01
+        )
+
+        await broker.place_order(
+            symbol="ETHUSDT", side="buy", order_type="limit", quantity=0.1, price=2500.0
+        )
+        with pytest.raises(ValueError, match="max_open_orders"):
+            await broker.place_order(
+                symbol="ETHUSDT", side="buy", order_type="limit", quantity=0.1, price=2400.0
+            )
+
+        stats = await broker.get_stats()
+        assert stats["open_orders"] == 3
+        assert stats["open_orders_by_symbol"] == {"BTCUSDT": 2, "ETHUSDT": 1}
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_max_open_orders():
+    run_async(_test_max_open_orders_impl())
+
+
+def _stop_snapshot(last, bid=None, ask=None):
+    return MarketSnapshot(
+        symbol="BTCUSDT",
+        best_bid=bid if bid is not None else last - 5,
+        best_ask=ask if ask is not None else last + 5,
+        bid_size=1.0,
+        ask_size=1.0,
+        last_price=last,
+        timestamp=datetime.now(timezone.utc),
+    )
+
+
+async def _stop_broker():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="paper",
+        run_id="stop_trigger_test",
+        initial_balance=100000.0,
+    )
+    return broker, manager
+
+
+async def _test_wait_for_fills_sleeps_until_due_impl(monkeypatch):
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=100.0, p95=200.0),
+            deterministic_latency=True,
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="paper",
+        run_id="wait_for_fills_test",
+        initial_balance=100000.0,
+    )
+    real_sleep = asyncio.sleep
+    sleeps = []
+
+    async def _counting_sleep(delay, *args, **kwargs):
+        sleeps.append(delay)
+        return await real_sleep(delay, *args, **kwargs)
+
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.1
+        )
+        monkeypatch.setattr(asyncio, "sleep", _counting_sleep)
+        await broker.wait_for_fills()
+        monkeypatch.undo()
+
+        assert (await broker.get_positions())[0].size == pytest.approx(0.1)
+        assert 0 < len(sleeps) <= 3
+        assert sleeps[0] > 0.05
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_wait_for_fills_sleeps_until_due(monkeypatch):
+    run_async(_test_wait_for_fills_sleeps_until_due_impl(monkeypatch))
+
+
+async def _test_buy_stop_waits_for_last_price_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="stop_market", quantity=0.01, stop_price=51000.0
+        )
+        await asyncio.sleep(0.02)
+        assert await broker.get_positions() == []
+
+        # Quotes above the stop while the last trade is still below: no trigger
+        await broker.update_market(_stop_snapshot(50900.0, bid=51000.0, ask=51020.0))
+        await asyncio.sleep(0.02)
+        assert await broker.get_positions() == []
+        assert len(await broker.get_open_orders()) == 1
+
+        await broker.update_market(_stop_snapshot(51010.0))
+        await asyncio.sleep(0.02)
+        positions = await broker.get_positions()
+        assert positions[0].side == "long" and positions[0].size == pytest.approx(0.01)
+        assert await broker.get_open_orders() == []
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_buy_stop_waits_for_last_price():
+    run_async(_test_buy_stop_waits_for_last_price_impl())
+
+
+async def _test_sell_stop_triggers_below_level_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.01
+        )
+        await broker.place_order(
+            symbol="BTCUSDT", side="sell", order_type="stop", quantity=0.01, stop_price=49000.0
+        )
+
+        await broker.update_market(_stop_snapshot(49500.0))
+        await asyncio.sleep(0.02)
+        positions = await broker.get_positions()
+        assert positions[0].size == pytest.approx(0.01)
+
+        await broker.update_market(_stop_snapshot(48990.0))
+        await asyncio.sleep(0.02)
+        assert all(p.size == pytest.approx(0.0) for p in await broker.get_positions())
+        assert await broker.get_open_orders() == []
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_sell_stop_triggers_below_level():
+    run_async(_test_sell_stop_triggers_below_level_impl())
+
+
+async def _test_symbol_aliases_normalize_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+            symbol_aliases={"XBTUSD": "BTCUSDT"},
+        ),
+        database=manager,
+        mode="paper",
+        run_id="alias_test",
+        initial_balance=100000.0,
+        execution_listener=_listener,
+    )
+    try:
+        alias_snapshot = _stop_snapshot(50000.0).model_copy(update={"symbol": "XBTUSD"})
+        await broker.update_market(alias_snapshot)
+        await broker.place_order(
+            symbol="XBTUSD", side="buy", order_type="market", quantity=0.01
+        )
+        await asyncio.sleep(0.02)
+
+        positions = await broker.get_positions()
+        assert [p.symbol for p in positions] == ["BTCUSDT"]
+        assert reports and all(r["symbol"] == "BTCUSDT" for r in reports)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_symbol_aliases_normalize():
+    run_async(_test_symbol_aliases_normalize_impl())
+
+
+def test_symbol_aliases_reject_chains():
+    with pytest.raises(ValueError, match="another alias"):
+        PaperConfig(symbol_aliases={"XBT": "XBTUSD", "XBTUSD": "BTCUSDT"})
+
+
+async def _test_fill_ids_unique_per_report_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(
+                enabled=True, min_slice_pct=0.2, max_slices=4, randomize=False
+            ),
+        ),
+        database=manager,
+        mode="paper",
+        run_id="fill_id_test",
+        initial_balance=100000.0,
+        execution_listener=_listener,
+    )
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        order = await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.1
+        )
+        await broker.wait_for_fills()
+
+        fills = [r for r in reports if r["executed"]]
+        assert len(fills) > 1
+        assert {r["order_id"] for r in fills} == {order.order_id}
+        assert {r["fill_id"] for r in fills} == {
+            f"{order.order_id}-{seq}" for seq in range(1, len(fills) + 1)
+        }
+        trades = await broker.get_recent_trades("BTCUSDT")
+        assert {t.trade_id for t in trades} == {r["fill_id"] for r in fills}
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_fill_ids_unique_per_report():
+    run_async(_test_fill_ids_unique_per_report_impl())
+
+
+async def _market_buy_fill_price(market_ref_price):
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(
+            slippage_bps=5.0,
+            spread_slippage_coeff=0.0,
+            ofi_slippage_coeff=0.0,
+            market_ref_price=market_ref_price,
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="paper",
+        run_id=f"ref_price_{market_ref_price}",
+        initial_balance=100000.0,
+        execution_listener=_listener,
+    )
+    try:
+        await broker.update_market(_stop_snapshot(50005.0, bid=50000.0, ask=50010.0))
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.01
+        )
+        await broker.wait_for_fills()
+        fills = [r for r in reports if r["executed"]]
+        assert len(fills) == 1
+        return fills[0]["price"]
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_market_buy_fill_price_from_touch():
+    # 50010 ask * (1 + 5 / 10_000)
+    price = run_async(_market_buy_fill_price("touch"))
+    assert price == pytest.approx(50035.005, abs=1e-9)
+
+
+def test_market_buy_fill_price_from_mid():
+    # 50005 mid * (1 + 5 / 10_000)
+    price = run_async(_market_buy_fill_price("mid"))
+    assert price == pytest.approx(50030.0025, abs=1e-9)
+
+
+async def _test_shortfall_vs_arrival_mid_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker = PaperBroker(
+        config=PaperConfig(
+            slippage_bps=5.0,
+            spread_slippage_coeff=0.0,
+            ofi_slippage_coeff=0.0,
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="paper",
+        run_id="shortfall",
+        initial_balance=100000.0,
+        execution_listener=_listener,
+    )
+    try:
+        await broker.update_market(_stop_snapshot(50005.0, bid=50000.0, ask=50010.0))
+        buy_order = await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.01
+        )
+        sell_order = await broker.place_order(
+            symbol="BTCUSDT", side="sell", order_type="market", quantity=0.01
+        )
+        await broker.wait_for_fills()
+        fills = {r["client_id"]: r for r in reports if r["executed"]}
+        buy, sell = fills[buy_order.client_id], fills[sell_order.client_id]
+
+        # Both legs pay half the spread plus 5 bps slippage vs the 50005 mid
+        assert buy["arrival_mid"] == pytest.approx(50005.0)
+        assert buy["shortfall_bps"] == pytest.approx(
+            (50010.0 * 1.0005 - 50005.0) / 50005.0 * 10_000
+        )
+        assert sell["shortfall_bps"] == pytest.approx(
+            (50005.0 - 50000.0 * 0.9995) / 50005.0 * 10_000
+        )
+        assert buy["shortfall_bps"] > buy["slippage_bps"]
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_shortfall_vs_arrival_mid():
+    run_async(_test_shortfall_vs_arrival_mid_impl())
+
+
+async def _test_notional_gauges_track_positions_impl():
+    broker, manager = await _stop_broker()
+    eth = _stop_snapshot(3000.0).model_copy(update={"symbol": "ETHUSDT"})
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.update_market(eth)
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.02
+        )
+        await broker.place_order(
+            symbol="ETHUSDT", side="sell", order_type="market", quantity=1.0
+        )
+        await broker.wait_for_fills()
+        await broker.update_market(_stop_snapshot(51000.0))
+
+        # 0.02 BTC long at 51000, 1 ETH short at 3000
+        assert GROSS_NOTIONAL.labels(mode="paper")._value.get() == pytest.approx(4020.0)
+        assert NET_NOTIONAL.labels(mode="paper")._value.get() == pytest.approx(-1980.0)
+
+        await broker.reset()
+        assert GROSS_NOTIONAL.labels(mode="paper")._value.get() == 0.0
+        assert NET_NOTIONAL.labels(mode="paper")._value.get() == 0.0
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_notional_gauges_track_positions():
+    run_async(_test_notional_gauges_track_positions_impl())
+
+
+async def _test_simulate_order_has_no_side_effects_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        rng_state = broker._random.getstate()
+
+        projection = await broker.simulate_order("BTCUSDT", "buy", "market", 0.01)
+        resting = await broker.simulate_order(
+            "BTCUSDT", "buy", "limit", 0.01, price=49000.0
+        )
+
+        assert projection["simulated"] is True
+        assert len(projection["fills"]) == 1
+        assert projection["price"] == projection["fills"][0]["price"]
+        assert projection["fees"] > 0
+        assert resting["rests"] is True and resting["fills"] == []
+        assert broker._random.getstate() == rng_state
+        assert await broker.get_positions() == []
+        assert await broker.get_open_orders() == []
+
+        # The real order fills where the simulation said it would
+        await broker.place_order(
+            symbol="BTCUSDT", side="buy", order_type="market", quantity=0.01
+        )
+        await broker.wait_for_fills()
+        trades = await broker.get_recent_trades("BTCUSDT")
+        assert trades[0].price == pytest.approx(projection["price"])
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_simulate_order_has_no_side_effects():
+    run_async(_test_simulate_order_has_no_side_effects_impl())
+
+
+def test_slippage_side_coefficients():
+    broker = PaperBroker(
+        config=PaperConfig(
+            slippage_bps=4.0,
+            max_slippage_bps=10.0,
+            spread_slippage_coeff=0.0,
+            ofi_slippage_coeff=0.0,
+            buy_slippage_coeff=2.0,
+            sell_slippage_coeff=0.5,
+        ),
+        database=None,
+        mode="backtest",
+        run_id="slippage_sides",
+        initial_balance=10000.0,
+    )
+    snapshot = _stop_snapshot(50000.0)
+    assert broker._compute_slippage_bps(snapshot, "buy") == pytest.approx(8.0)
+    assert broker._compute_slippage_bps(snapshot, "sell") == pytest.approx(2.0)
+
+    broker.config = broker.config.model_copy(update={"buy_slippage_coeff": 5.0})
+    assert broker._compute_slippage_bps(snapshot, "buy") == pytest.approx(10.0)
+
+
+def test_slippage_side_coefficients_non_negative():
+    with pytest.raises(ValueError):
+        PaperConfig(sell_slippage_coeff=-0.1)
+
+
+async def _test_place_order_and_wait_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        order, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        assert report["executed"] is True
+        assert report["client_id"] == order.client_id
+        assert report["quantity"] == pytest.approx(0.01)
+
+        resting, nothing = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=40000.0, timeout=0.05
+        )
+        assert nothing is None
+        assert resting.status == "open"
+        assert broker._report_waiters == {}
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_place_order_and_wait():
+    run_async(_test_place_order_and_wait_impl())
+
+
+async def _test_funding_sign_follows_position_impl():
+    broker, manager = await _stop_broker()
+    try:
+        snapshot = _stop_snapshot(50000.0)
+        snapshot.funding_rate = 0.0001
+        await broker.update_market(snapshot)
+        before_paid = REGISTRY.get_sample_value(
+            "paper_funding_total",
+            {"mode": "paper", "symbol": "BTCUSDT", "direction": "paid"},
+        ) or 0.0
+
+        _, opened = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        assert opened["funding"] > 0
+
+        _, flat = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        assert flat["funding"] == 0.0
+
+        _, short = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        assert short["funding"] < 0
+
+        paid = REGISTRY.get_sample_value(
+            "paper_funding_total",
+            {"mode": "paper", "symbol": "BTCUSDT", "direction": "paid"},
+        )
+        received = REGISTRY.get_sample_value(
+            "paper_funding_total",
+            {"mode": "paper", "symbol": "BTCUSDT", "direction": "received"},
+        )
+        assert paid - before_paid == pytest.approx(opened["funding"])
+        assert received >= abs(short["funding"])
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_funding_sign_follows_position():
+    run_async(_test_funding_sign_follows_position_impl())
+
+
+async def _test_funding_on_position_closed_between_intervals_impl(policy, rate, unheld):
+    broker, manager = await _stop_broker()
+    broker.config.funding_proration = policy
+    try:
+        opening = _stop_snapshot(50000.0)
+        opening.funding_rate = rate
+        await broker.update_market(opening)
+        _, opened = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        assert opened["funding"] == pytest.approx(opened["price"] * 0.01 * rate)
+
+        # Closed a quarter of the way into the prepaid hour
+        closing = opening.model_copy(
+            update={"timestamp": opening.timestamp + timedelta(minutes=15)}
+        )
+        await broker.update_market(closing)
+        _, closed = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.0)
+        assert closed["funding"] == pytest.approx(-opened["price"] * 0.01 * rate * unheld)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+@pytest.mark.parametrize(
+    "policy, rate, unheld",
+    [
+        ("skip", 0.0001, 0.0),
+        ("skip", -0.0001, 0.0),
+        ("prorate", 0.0001, 0.75),
+        ("prorate", -0.0001, 0.75),
+    ],
+)
+def test_funding_on_position_closed_between_intervals(policy, rate, unheld):
+    run_async(_test_funding_on_position_closed_between_intervals_impl(policy, rate, unheld))
+
+
+async def _test_funding_refund_uses_prepaid_rate_per_lot_impl():
+    broker, manager = await _stop_broker()
+    broker.config.funding_proration = "prorate"
+    try:
+        start = _stop_snapshot(50000.0)
+        start.funding_rate = 0.0001
+        await broker.update_market(start)
+        _, first = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+
+        # Size added half an hour later prepays its own hour at a higher rate
+        added = start.model_copy(
+            update={"timestamp": start.timestamp + timedelta(minutes=30), "funding_rate": 0.0003}
+        )
+        await broker.update_market(added)
+        _, second = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+
+        # The rate flips sign before a partial close of the oldest lot
+        flipped = start.model_copy(
+            update={"timestamp": start.timestamp + timedelta(minutes=45), "funding_rate": -0.0002}
+        )
+        await broker.update_market(flipped)
+        _, partial = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        # Refunded at the prepaid +0.0001: money back, not a further charge
+        assert partial["funding"] == pytest.approx(-first["price"] * 0.01 * 0.0001 * 0.25)
+        assert partial["funding"] < 0
+
+        # The later lot was held 15 of its 60 minutes
+        _, rest = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        assert rest["funding"] == pytest.approx(-second["price"] * 0.01 * 0.0003 * 0.75)
+        assert not broker._positions["BTCUSDT"].funding_lots
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_funding_refund_uses_prepaid_rate_per_lot():
+    run_async(_test_funding_refund_uses_prepaid_rate_per_lot_impl())
+
+
+async def _test_crossed_book_rejects_orders_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        before = REGISTRY.get_sample_value(
+            "paper_crossed_books_total", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) or 0.0
+
+        await broker.update_market(_stop_snapshot(50000.0, bid=50010.0, ask=50000.0))
+        assert broker._market_state["BTCUSDT"].best_bid == pytest.approx(49995.0)
+        with pytest.raises(ValueError, match="crossed_book"):
+            await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        assert REGISTRY.get_sample_value(
+            "paper_crossed_books_total", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) == pytest.approx(before + 1)
+
+        await broker.update_market(_stop_snapshot(50000.0))
+        order = await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        assert order.status == "open"
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_crossed_book_rejects_orders():
+    run_async(_test_crossed_book_rejects_orders_impl())
+
+
+async def _test_reset_clears_crossed_books_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.update_market(_stop_snapshot(50000.0, bid=50010.0, ask=50000.0))
+        assert "BTCUSDT" in broker._crossed_books
+
+        await broker.reset()
+
+        assert broker._crossed_books == set()
+        order = await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        assert order.status == "open"
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reset_clears_crossed_books():
+    run_async(_test_reset_clears_crossed_books_impl())
+
+
+async def _test_disabled_symbol_allows_only_reduce_only_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order_and_wait("BTCUSDT", "buy", "market", 0.1, timeout=1.0)
+
+        await broker.set_symbol_enabled("BTCUSDT", False)
+        with pytest.raises(ValueError, match="symbol_disabled"):
+            await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        assert (await broker.get_stats())["disabled_symbols"] == ["BTCUSDT"]
+        assert REGISTRY.get_sample_value(
+            "paper_symbol_enabled", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) == 0.0
+
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.1, reduce_only=True, timeout=1.0
+        )
+        assert report["executed"] is True
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.0)
+
+        await broker.set_symbol_enabled("BTCUSDT", True)
+        order = await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        assert order.status == "open"
+        assert (await broker.get_stats())["disabled_symbols"] == []
+        assert REGISTRY.get_sample_value(
+            "paper_symbol_enabled", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) == 1.0
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_disabled_symbol_allows_only_reduce_only():
+    run_async(_test_disabled_symbol_allows_only_reduce_only_impl())
+
+
+async def _test_crossed_book_falls_back_to_last_price_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(update={"crossed_book_policy": "last_price"})
+    try:
+        await broker.update_market(_stop_snapshot(50000.0, bid=50005.0, ask=50005.0))
+        snapshot = broker._market_state["BTCUSDT"]
+        assert snapshot.best_bid == snapshot.best_ask == pytest.approx(50000.0)
+
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        assert report["executed"] is True
+        assert report["arrival_mid"] == pytest.approx(50000.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_crossed_book_falls_back_to_last_price():
+    run_async(_test_crossed_book_falls_back_to_last_price_impl())
+
+
+def _flow_snapshot(side, size):
+    snapshot = _stop_snapshot(50000.0)
+    snapshot.last_side = side
+    snapshot.last_size = size
+    return snapshot
+
+
+async def _test_ofi_seeded_from_first_ticks_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(
+        update={"estimator_warmup": EstimatorWarmupConfig(seed_from_first_n=2)}
+    )
+    try:
+        await broker.update_market(_flow_snapshot("buy", 0.3))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(2.0)
+        await broker.update_market(_flow_snapshot("sell", 0.1))
+        seeded = (0.3 - 0.1) / 2 / 0.15
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(seeded)
+
+        await broker.update_market(_flow_snapshot("buy", 0.5))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(
+            seeded * 0.85 + 0.5
+        )
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_ofi_seeded_from_first_ticks():
+    run_async(_test_ofi_seeded_from_first_ticks_impl())
+
+
+async def _test_reset_restarts_ofi_warmup_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(
+        update={"estimator_warmup": EstimatorWarmupConfig(seed_from_first_n=2)}
+    )
+    try:
+        await broker.update_market(_flow_snapshot("buy", 0.3))
+        await broker.update_market(_flow_snapshot("sell", 0.1))
+        assert broker._ofi_warmup["BTCUSDT"][0] == 2
+
+        await broker.reset()
+        assert broker._ofi_warmup == {}
+        await broker.update_market(_flow_snapshot("buy", 0.6))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(4.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reset_restarts_ofi_warmup():
+    run_async(_test_reset_restarts_ofi_warmup_impl())
+
+
+async def _test_ofi_initial_value_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(
+        update={"estimator_warmup": EstimatorWarmupConfig(ofi_initial=-1.5)}
+    )
+    try:
+        await broker.update_market(_flow_snapshot("buy", 0.5))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(-1.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_ofi_initial_value():
+    run_async(_test_ofi_initial_value_impl())
+
+
+async def _test_ofi_first_tick_flow_impl(policy, first, second):
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(
+        update={"estimator_warmup": EstimatorWarmupConfig(first_tick_flow=policy)}
+    )
+    try:
+        await broker.update_market(_flow_snapshot("buy", 4.0))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(first)
+        await broker.update_market(_flow_snapshot("buy", 0.1))
+        assert broker._market_state["BTCUSDT"].order_flow_imbalance == pytest.approx(second)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+@pytest.mark.parametrize(
+    "policy, first, second",
+    [
+        ("raw", 4.0, 4.0 * 0.85 + 0.1),
+        ("zero", 0.0, 0.1),
+        ("normalized", 0.6, 0.6 * 0.85 + 0.1),
+    ],
+)
+def test_ofi_first_tick_flow(policy, first, second):
+    run_async(_test_ofi_first_tick_flow_impl(policy, first, second))
+
+
+async def _test_replay_fill_timestamps_follow_market_time_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=50.0, p95=50.0, jitter=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="replay",
+        run_id="fill_timestamp_test",
+        initial_balance=100000.0,
+    )
+    try:
+        market_time = datetime(2024, 1, 1, 12, 0, tzinfo=timezone.utc)
+        snapshot = _stop_snapshot(50000.0)
+        snapshot.timestamp = market_time
+        await broker.update_market(snapshot)
+
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        filled_at = datetime.fromisoformat(report["timestamp"])
+        assert filled_at == market_time + timedelta(milliseconds=report["latency_ms"])
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_replay_fill_timestamps_follow_market_time():
+    run_async(_test_replay_fill_timestamps_follow_market_time_impl())
+
+
+async def _test_reconcile_positions_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(update={"fill_journal_size": 2})
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        for side, qty in (("buy", 0.03), ("sell", 0.01), ("buy", 0.02)):
+            await broker.place_order_and_wait("BTCUSDT", side, "market", qty, timeout=1.0)
+
+        # Three fills through a two-entry journal: the oldest is in the baseline
+        assert len(broker._fill_journal) == 2
+        assert await broker.reconcile_positions() == []
+
+        broker._positions["BTCUSDT"].size += 0.005
+        discrepancies = await broker.reconcile_positions()
+        assert len(discrepancies) == 1
+        assert discrepancies[0]["symbol"] == "BTCUSDT"
+        assert discrepancies[0]["journal_size"] == pytest.approx(0.04)
+        assert discrepancies[0]["difference"] == pytest.approx(0.005)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reconcile_positions():
+    run_async(_test_reconcile_positions_impl())
+
+
+def _empty_book_snapshot():
+    snapshot = _stop_snapshot(50000.0)
+    snapshot.bid_size = 0.0
+    snapshot.ask_size = 0.0
+    return snapshot
+
+
+async def _test_zero_liquidity_reject_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(update={"zero_liquidity_policy": "reject"})
+    try:
+        await broker.update_market(_empty_book_snapshot())
+        with pytest.raises(ValueError, match="no_liquidity"):
+            await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        # A passive limit takes nothing and may still rest
+        order = await broker.place_order(
+            "BTCUSDT", "buy", "limit", 0.01, price=49000.0
+        )
+        assert order.status == "open"
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_zero_liquidity_reject():
+    run_async(_test_zero_liquidity_reject_impl())
+
+
+async def _test_zero_liquidity_delay_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(update={"zero_liquidity_policy": "delay"})
+    try:
+        await broker.update_market(_empty_book_snapshot())
+        order, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=0.05
+        )
+        assert report is None
+        assert len(broker._pending_markets) == 1
+
+        await broker.update_market(_empty_book_snapshot())
+        assert len(broker._pending_markets) == 1
+
+        waiter = broker.next_report(order.client_id)
+        await broker.update_market(_stop_snapshot(50000.0))
+        report = await asyncio.wait_for(waiter, 1.0)
+        assert report["executed"] is True
+        assert broker._pending_markets == []
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_zero_liquidity_delay():
+    run_async(_test_zero_liquidity_delay_impl())
+
+
+async def _test_slippage_overflow_rejects_impl():
+    broker, manager = await _stop_broker()
+    broker.config = broker.config.model_copy(
+        update={"slippage_overflow": "reject", "buy_slippage_coeff": 4.0}
+    )
+    reports = []
+
+    async def _listener(report):
+        reports.append(report)
+
+    broker._execution_listener = _listener
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        before = REGISTRY.get_sample_value(
+            "paper_slippage_rejects_total", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) or 0.0
+
+        with pytest.raises(ValueError, match="max_slippage_exceeded"):
+            await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        # Sells stay inside the band
+        _, sold = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.01, timeout=1.0
+        )
+        assert sold["executed"] is True
+
+        stop = await broker.place_order(
+            "BTCUSDT", "buy", "stop_market", 0.01, stop_price=50100.0
+        )
+        await broker.update_market(_stop_snapshot(50200.0))
+        rejected = [r for r in reports if r["client_id"] == stop.client_id]
+        assert rejected[0]["error"] == "max_slippage_exceeded"
+        assert rejected[0]["error_code"] == "ERR_MAX_SLIPPAGE"
+
+        assert REGISTRY.get_sample_value(
+            "paper_slippage_rejects_total", {"mode": "paper", "symbol": "BTCUSDT"}
+        ) == pytest.approx(before + 2)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_slippage_overflow_rejects():
+    run_async(_test_slippage_overflow_rejects_impl())
+
+
+async def _test_histogram_symbol_label_allow_list_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+        ),
+        database=manager,
+        mode="paper",
+        run_id="metric_symbols",
+        initial_balance=100000.0,
+        metric_symbols=["BTCUSDT"],
+    )
+
+    def _count(symbol):
+        return REGISTRY.get_sample_value(
+            "paper_fill_slippage_bps_count", {"mode": "paper", "symbol": symbol}
+        ) or 0.0
+
+    try:
+        btc_before, other_before = _count("BTCUSDT"), _count("other")
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.update_market(
+            _stop_snapshot(3000.0).model_copy(update={"symbol": "ETHUSDT"})
+        )
+        await broker.place_order_and_wait("BTCUSDT", "buy", "market", 0.01, timeout=1.0)
+        await broker.place_order_and_wait("ETHUSDT", "buy", "market", 0.1, timeout=1.0)
+
+        assert _count("BTCUSDT") == btc_before + 1
+        assert _count("other") == other_before + 1
+        assert _count("ETHUSDT") == 0.0
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_histogram_symbol_label_allow_list():
+    run_async(_test_histogram_symbol_label_allow_list_impl())
+
+
+async def _test_initial_positions_seed_book_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(enabled=False),
+            initial_positions={"BTCUSDT": InitialPosition(size=0.1, avg_price=40000.0)},
+        ),
+        database=manager,
+        mode="backtest",
+        run_id="initial_positions",
+        initial_balance=5000.0,
+    )
+    try:
+        await broker.restore_state()
+        await broker.update_market(_stop_snapshot(50000.0))
+        position = broker._positions["BTCUSDT"]
+        assert position.size == pytest.approx(0.1)
+        assert position.unrealized_pnl == pytest.approx(0.1 * (50000.0 - 40000.0))
+
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.05, timeout=1.0
+        )
+        # Realized against the seeded basis, not the first trade seen
+        assert report["realized_pnl"] == pytest.approx(
+            0.05 * (report["price"] - 40000.0)
+        )
+        assert await broker.reconcile_positions() == []
+
+        await broker.reset()
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.1)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_initial_positions_seed_book():
+    run_async(_test_initial_positions_seed_book_impl())
+
+
+def test_initial_positions_validated():
+    with pytest.raises(ValueError):
+        InitialPosition(size=0.0, avg_price=100.0)
+    with pytest.raises(ValueError):
+        InitialPosition(size=1.0, avg_price=0.0)
+
+
+async def _test_account_equity_marks_short_against_position_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.1, timeout=1.0
+        )
+        entry = report["price"]
+
+        # The mark rallies 1000 against the short: a loss, not a gain
+        await broker.update_market(_stop_snapshot(51000.0))
+        snapshot = await broker.account_snapshot()
+        loss = 0.1 * (51000.0 - entry)
+        (position,) = snapshot["positions"]
+        assert position["size"] == pytest.approx(-0.1)
+        assert position["unrealized_pnl"] == pytest.approx(-loss)
+        assert snapshot["equity"] == pytest.approx(broker._balance - loss)
+        assert snapshot["equity"] < snapshot["balance"]
+
+        balances = await broker.get_account_balance()
+        assert balances["totalMarginBalance"] == pytest.approx(snapshot["equity"])
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_account_equity_marks_short_against_position():
+    run_async(_test_account_equity_marks_short_against_position_impl())
+
+
+async def _test_reduce_only_overflow_clamps_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order_and_wait("BTCUSDT", "buy", "market", 0.1, timeout=1.0)
+
+        order, report = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "market", 0.25, reduce_only=True, timeout=1.0
+        )
+        assert order.quantity == pytest.approx(0.1)
+        assert report["quantity"] == pytest.approx(0.1)
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.0)
+
+        # Nothing left to reduce
+        with pytest.raises(ValueError, match="reduce_only_exceeds_position"):
+            await broker.place_order(
+                "BTCUSDT", "sell", "market", 0.1, reduce_only=True
+            )
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reduce_only_overflow_clamps():
+    run_async(_test_reduce_only_overflow_clamps_impl())
+
+
+async def _test_reduce_only_overflow_rejects_impl():
+    broker, manager = await _stop_broker()
+    broker.config.reduce_only_overflow = "reject"
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order_and_wait("BTCUSDT", "sell", "market", 0.1, timeout=1.0)
+
+        with pytest.raises(ValueError, match="reduce_only_exceeds_position"):
+            await broker.place_order(
+                "BTCUSDT", "buy", "market", 0.25, reduce_only=True
+            )
+        assert broker._positions["BTCUSDT"].size == pytest.approx(-0.1)
+
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.1, reduce_only=True, timeout=1.0
+        )
+        assert report["quantity"] == pytest.approx(0.1)
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_reduce_only_overflow_rejects():
+    run_async(_test_reduce_only_overflow_rejects_impl())
+
+
+@pytest.mark.parametrize(
+    "timing, expected",
+    [
+        ("constant", [10.0, 10.0, 10.0]),
+        ("linear", [10.0, 15.0, 20.0]),
+        ("exponential", [10.0, 20.0, 40.0]),
+    ],
+)
+def test_partial_fill_slice_timing(timing, expected):
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=10.0, p95=10.0, min_ms=10.0, max_ms=10.0),
+            partial_fill=PartialFillConfig(
+                min_slice_pct=0.2, max_slices=3, randomize=False, slice_timing=timing
+            ),
+        ),
+        database=DatabaseManager(":memory:"),
+        mode="paper",
+        run_id="slice_timing",
+        initial_balance=1000.0,
+    )
+    fills = broker._plan_fills("BTCUSDT", 9.0, 100.0, maker=True, slippage_bps=0.0)
+    assert [delay for delay, *_ in fills] == pytest.approx(expected)
+
+
+async def _test_amend_resting_limit_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        for client_id in ("first", "second"):
+            await broker.place_order(
+                "BTCUSDT", "buy", "limit", 0.02, price=49900.0, client_id=client_id
+            )
+
+        # A smaller quantity keeps time priority
+        report = await broker.amend_order("first", new_quantity=0.01)
+        assert report["status"] == "amended"
+        assert report["priority_kept"] is True
+        book = broker._resting_limits["BTCUSDT"]
+        assert [rest.order.client_id for rest in book] == ["first", "second"]
+        assert book[0].remaining_qty == pytest.approx(0.01)
+
+        # A new price sends the order to the back of the book
+        report = await broker.amend_order("first", new_price=49950.0)
+        assert report["priority_kept"] is False
+        assert report["previous_price"] == pytest.approx(49900.0)
+        book = broker._resting_limits["BTCUSDT"]
+        assert [rest.order.client_id for rest in book] == ["second", "first"]
+        assert book[1].limit_price == pytest.approx(49950.0)
+
+        with pytest.raises(ValueError, match="amend_would_cross"):
+            await broker.amend_order("second", new_price=50010.0)
+        with pytest.raises(ValueError, match="unknown_order"):
+            await broker.amend_order("missing", new_quantity=0.01)
+
+        # Filled orders leave the book and can no longer be amended
+        await broker.update_market(_stop_snapshot(49800.0))
+        with pytest.raises(ValueError, match="unknown_order"):
+            await broker.amend_order("first", new_quantity=0.005)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_amend_resting_limit():
+    run_async(_test_amend_resting_limit_impl())
+
+
+def _last_only_snapshot(last):
+    return _stop_snapshot(last, bid=0.0, ask=0.0)
+
+
+async def _test_synthetic_spread_for_last_only_ticks_impl():
+    broker, manager = await _stop_broker()
+    try:
+        # Default: the last trade stands in for both sides
+        await broker.update_market(_last_only_snapshot(50000.0))
+        assert broker._market_state["BTCUSDT"].best_ask == 0.0
+
+        broker.config.synthetic_spread = "fixed"
+        broker.config.synthetic_spread_bps = 10.0
+        await broker.update_market(_last_only_snapshot(50000.0))
+        book = broker._market_state["BTCUSDT"]
+        assert book.best_bid == pytest.approx(49975.0)
+        assert book.best_ask == pytest.approx(50025.0)
+
+        # A 1% move feeds 6% of 100 bps into the average: 5 x 6 = 30 bps
+        broker.config.synthetic_spread = "vol"
+        broker.config.synthetic_spread_vol_mult = 5.0
+        await broker.update_market(_last_only_snapshot(50500.0))
+        assert broker._market_state["BTCUSDT"].spread_bps == pytest.approx(30.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_synthetic_spread_for_last_only_ticks():
+    run_async(_test_synthetic_spread_for_last_only_ticks_impl())
+
+
+async def _test_resting_order_age_observed_in_market_time_impl():
+    broker, manager = await _stop_broker()
+
+    def age_sum(reason):
+        return REGISTRY.get_sample_value(
+            "paper_resting_order_age_seconds_sum",
+            {"mode": "paper", "reason": reason},
+        ) or 0.0
+
+    start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+    try:
+        filled_before, cancelled_before = age_sum("filled"), age_sum("cancelled")
+        await broker.update_market(
+            _stop_snapshot(50000.0).model_copy(update={"timestamp": start})
+        )
+        await broker.place_order("BTCUSDT", "buy", "limit", 0.01, price=49900.0)
+        await broker.place_order("BTCUSDT", "sell", "limit", 0.01, price=50500.0)
+
+        await broker.update_market(
+            _stop_snapshot(49850.0).model_copy(
+                update={"timestamp": start + timedelta(seconds=120)}
+            )
+        )
+        assert age_sum("filled") - filled_before == pytest.approx(120.0)
+
+        await broker.update_market(
+            _stop_snapshot(49850.0).model_copy(
+                update={"timestamp": start + timedelta(seconds=300)}
+            )
+        )
+        await broker.cancel_open_orders("cancelled_by_test")
+        assert age_sum("cancelled") - cancelled_before == pytest.approx(300.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_resting_order_age_observed_in_market_time():
+    run_async(_test_resting_order_age_observed_in_market_time_impl())
+
+
+def _at(last, seconds):
+    start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+    return _stop_snapshot(last).model_copy(
+        update={"timestamp": start + timedelta(seconds=seconds)}
+    )
+
+
+async def _test_post_gap_cooldown_rejects_taker_orders_impl():
+    broker, manager = await _stop_broker()
+    broker.config.gap_threshold_s = 60.0
+    broker.config.post_gap_cooldown_s = 10.0
+    try:
+        await broker.update_market(_at(50000.0, 0))
+        await broker.update_market(_at(50500.0, 120))
+        with pytest.raises(ValueError, match="post_gap_cooldown"):
+            await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+        # A resting limit takes no liquidity and is accepted
+        await broker.place_order("BTCUSDT", "buy", "limit", 0.01, price=50000.0)
+
+        await broker.update_market(_at(50500.0, 130))
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "market", 0.01, timeout=1.0
+        )
+        assert report["executed"] is True
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_post_gap_cooldown_rejects_taker_orders():
+    run_async(_test_post_gap_cooldown_rejects_taker_orders_impl())
+
+
+async def _test_post_gap_cooldown_holds_market_orders_impl():
+    broker, manager = await _stop_broker()
+    broker.config.gap_threshold_s = 60.0
+    broker.config.post_gap_cooldown_s = 10.0
+    broker.config.post_gap_policy = "wait"
+    try:
+        await broker.update_market(_at(50000.0, 0))
+        await broker.update_market(_at(50500.0, 120))
+        filled = broker.next_report("held")
+        await broker.place_order(
+            "BTCUSDT", "buy", "market", 0.01, client_id="held"
+        )
+        await broker.update_market(_at(50600.0, 125))
+        assert not filled.done()
+
+        await broker.update_market(_at(50700.0, 131))
+        report = await asyncio.wait_for(filled, 1.0)
+        assert report["price"] == pytest.approx(50705.0, rel=1e-3)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_post_gap_cooldown_holds_market_orders():
+    run_async(_test_post_gap_cooldown_holds_market_orders_impl())
+
+
+async def _test_clock_seed_resolved_and_reported_impl():
+    manager = DatabaseManager(":memory:")
+    await manager.initialize()
+    broker = PaperBroker(
+        config=PaperConfig(seed=None),
+        database=manager,
+        mode="paper",
+        run_id="clock_seed",
+        initial_balance=1000.0,
+    )
+    try:
+        assert isinstance(broker.seed, int)
+        assert (await broker.get_stats())["seed"] == broker.seed
+        first = broker._random.random()
+        # A reset replays the same resolved seed, not a fresh clock draw
+        await broker.reset()
+        assert broker._random.random() == first
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_clock_seed_resolved_and_reported():
+    run_async(_test_clock_seed_resolved_and_reported_impl())
+
+
+@pytest.mark.parametrize(
+    "minimum, expected",
+    [
+        (None, [1.0, 1.0, 1.0, 1.0]),
+        (150.0, [2.0, 2.0]),
+        (250.0, [4.0]),
+        (300.0, [4.0]),
+    ],
+)
+def test_min_fill_notional_merges_slices(minimum, expected):
+    broker = PaperBroker(
+        config=PaperConfig(
+            latency_ms=LatencyConfig(mean=0.0, p95=0.0),
+            partial_fill=PartialFillConfig(
+                min_slice_pct=0.25,
+                max_slices=4,
+                randomize=False,
+                min_fill_notional=minimum,
+            ),
+        ),
+        database=DatabaseManager(":memory:"),
+        mode="paper",
+        run_id="min_fill_notional",
+        initial_balance=1000.0,
+    )
+    fills = broker._plan_fills("BTCUSDT", 4.0, 100.0, maker=True, slippage_bps=0.0)
+    assert [qty for _, qty, *_ in fills] == pytest.approx(expected)
+
+
+def test_partial_fill_slice_timing_validated():
+    with pytest.raises(ValueError):
+        PartialFillConfig(slice_timing="random")
+    with pytest.raises(ValueError):
+        PartialFillConfig(slice_backoff=0.5)
+
+
+async def _test_price_band_rejects_far_limits_impl():
+    broker, manager = await _stop_broker()
+    broker.config.price_band_bps = 100.0
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        with pytest.raises(ValueError, match="price_out_of_band"):
+            await broker.place_order("BTCUSDT", "buy", "limit", 0.01, price=40000.0)
+        with pytest.raises(ValueError, match="price_out_of_band"):
+            await broker.place_order("BTCUSDT", "sell", "limit", 0.01, price=50600.0)
+
+        order = await broker.place_order("BTCUSDT", "buy", "limit", 0.01, price=49600.0)
+        assert order.status == "open"
+        # Market orders are not banded
+        await broker.place_order("BTCUSDT", "buy", "market", 0.01)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_price_band_rejects_far_limits():
+    run_async(_test_price_band_rejects_far_limits_impl())
+
+
+async def _test_marketable_limit_fills_at_touch_impl():
+    broker, manager = await _stop_broker()
+    broker.config.slippage_bps = 0.0
+    broker.config.spread_slippage_coeff = 0.0
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        # Priced far through the ask: improved to the touch
+        _, buy = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=50500.0, timeout=1.0
+        )
+        assert buy["price"] == pytest.approx(50005.0)
+        _, sell = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "limit", 0.01, price=49500.0, timeout=1.0
+        )
+        assert sell["price"] == pytest.approx(49995.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_marketable_limit_fills_at_touch():
+    run_async(_test_marketable_limit_fills_at_touch_impl())
+
+
+async def _test_marketable_limit_slippage_capped_at_limit_impl():
+    broker, manager = await _stop_broker()
+    broker.config.slippage_bps = 20.0
+    broker.config.max_slippage_bps = 50.0
+    broker.config.spread_slippage_coeff = 0.0
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        # Ask 50005 slipped by 20 bps would be ~50105, beyond the limit
+        _, buy = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=50010.0, timeout=1.0
+        )
+        assert buy["price"] == pytest.approx(50010.0)
+        assert buy["slippage_bps"] == pytest.approx(5.0 / 50005.0 * 10_000)
+        _, sell = await broker.place_order_and_wait(
+            "BTCUSDT", "sell", "limit", 0.01, price=49990.0, timeout=1.0
+        )
+        assert sell["price"] == pytest.approx(49990.0)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_marketable_limit_slippage_capped_at_limit():
+    run_async(_test_marketable_limit_slippage_capped_at_limit_impl())
+
+
+async def _test_maker_rebate_only_for_rested_fills_impl():
+    broker, manager = await _stop_broker()
+    broker.config.fee_bps = 5.0
+    broker.config.maker_rebate_bps = -2.0
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        # Crosses the ask on submission: taker, pays the fee
+        _, immediate = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=50010.0, timeout=1.0
+        )
+        assert immediate["maker"] is False
+        assert immediate["fees"] > 0
+
+        # Rests below the bid, then fills when the market trades through it
+        resting = broker.next_report("rested")
+        await broker.place_order(
+            "BTCUSDT", "buy", "limit", 0.01, price=49900.0, client_id="rested"
+        )
+        await broker.update_market(_stop_snapshot(49850.0))
+        rested = await asyncio.wait_for(resting, 1.0)
+        assert rested["maker"] is True
+        assert rested["fees"] < 0
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_maker_rebate_only_for_rested_fills():
+    run_async(_test_maker_rebate_only_for_rested_fills_impl())
+
+
+async def _test_force_liquidity_overrides_classification_impl():
+    broker, manager = await _stop_broker()
+    broker.config.force_liquidity = "maker"
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        # Would be a taker fill under automatic classification
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=50010.0, timeout=1.0
+        )
+        assert report["maker"] is True
+
+        broker.config.force_liquidity = "taker"
+        _, report = await broker.place_order_and_wait(
+            "BTCUSDT", "buy", "limit", 0.01, price=50010.0, timeout=1.0
+        )
+        assert report["maker"] is False
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_force_liquidity_overrides_classification():
+    run_async(_test_force_liquidity_overrides_classification_impl())
+
+
+async def _test_no_flip_rejects_orders_through_zero_impl():
+    broker, manager = await _stop_broker()
+    try:
+        await broker.update_market(_stop_snapshot(50000.0))
+        await broker.place_order_and_wait("BTCUSDT", "buy", "market", 0.1, timeout=1.0)
+
+        broker.config.no_flip = True
+        with pytest.raises(ValueError, match="would_flip_position"):
+            await broker.place_order("BTCUSDT", "sell", "market", 0.15)
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.1)
+
+        # Closing exactly, then opening the other side, takes two orders
+        await broker.place_order_and_wait("BTCUSDT", "sell", "market", 0.1, timeout=1.0)
+        await broker.place_order_and_wait("BTCUSDT", "sell", "market", 0.05, timeout=1.0)
+        assert broker._positions["BTCUSDT"].size == pytest.approx(-0.05)
+
+        broker.config.no_flip = False
+        await broker.place_order_and_wait("BTCUSDT", "buy", "market", 0.15, timeout=1.0)
+        assert broker._positions["BTCUSDT"].size == pytest.approx(0.1)
+    finally:
+        await broker.close()
+        await manager.close()
+
+
+def test_no_flip_rejects_orders_through_zero():
+    run_async(_test_no_flip_rejects_orders_through_zero_impl())
@@ -0,0 +1,83 @@
+import asyncio
+import csv
+
+import pytest
+
+from tools.check_paper_determinism import check, first_divergence
+from tools.run_paper_sweep import build_paper_config, expand_combinations, run_sweep
+
+
+def test_expand_combinations_grid_and_runs():
+    spec = {
+        "grid": {"slippage_bps": [1.0, 3.0], "fee_bps": [5.0, 7.0]},
+        "runs": [{"slippage_bps": 9.0, "max_slippage_bps": 20.0}],
+    }
+    combos = expand_combinations(spec)
+    assert len(combos) == 5
+    assert combos[0] == {"slippage_bps": 1.0, "fee_bps": 5.0}
+    assert combos[-1] == {"slippage_bps": 9.0, "max_slippage_bps": 20.0}
+    assert expand_combinations({}) == [{}]
+
+
+def test_build_paper_config_merges_and_validates():
+    config = build_paper_config(
+        {"latency_ms": {"mean": 0, "p95": 0}}, {"latency_ms": {"p95": 5}}
+    )
+    assert config.latency_ms.mean == 0 and config.latency_ms.p95 == 5
+    with pytest.raises(ValueError):
+        build_paper_config({}, {"slippage_bps": 50.0})
+
+
+def test_run_sweep_writes_consolidated_csv(tmp_path):
+    data = tmp_path / "bars.csv"
+    data.write_text(
+        "timestamp,open,high,low,close,volume\n"
+        "2023-01-01T00:00:00Z,100,101,99,100,10\n"
+        "2023-01-01T01:00:00Z,100,102,99,101,10\n"
+        "2023-01-01T02:00:00Z,101,103,100,102,10\n"
+    )
+    orders = tmp_path / "orders.csv"
+    orders.write_text(
+        "timestamp,symbol,side,order_type,quantity,price\n"
+        "2023-01-01T00:00:00Z,BTCUSDT,buy,market,1,\n"
+        "2023-01-01T02:00:00Z,BTCUSDT,sell,market,1,\n"
+    )
+    spec = {
+        "dataset": f"csv://{data}",
+        "orders": str(orders),
+        "base": {
+            "latency_ms": {"mean": 0, "p95": 0},
+            "partial_fill": {"enabled": False},
+        },
+        "grid": {"fee_bps": [0.0, 10.0]},
+    }
+
+    rows = asyncio.run(run_sweep(spec, tmp_path / "out"))
+
+    assert [row["fills"] for row in rows] == [2, 2]
+    assert len({row["run_id"] for row in rows}) == 2
+    assert rows[0]["fees"] == 0.0 and rows[1]["fees"] > 0.0
+    assert rows[1]["net_pnl"] < rows[0]["net_pnl"]
+
+    with open(tmp_path / "out" / "sweep.csv", newline="") as f:
+        lines = list(csv.DictReader(f))
+    assert [line["fee_bps"] for line in lines] == ["0.0", "10.0"]
+    assert "net_pnl" in lines[0]
+
+
+def test_first_divergence():
+    assert first_divergence(["a", "b"], ["a", "b"]) is None
+    assert first_divergence(["a", "b"], ["a", "c"]) == 1
+    assert first_divergence(["a"], ["a", "b"]) == 1
+
+
+def test_determinism_check_passes_on_sample_fixture():
+    code = asyncio.run(
+        check(
+            "csv://sample_data/btc_4h.csv",
+            "sample_data/sweep_orders.csv",
+            "BTCUSDT",
+            seed=7,
+        )
+    )
+    assert code == 0
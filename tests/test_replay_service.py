@@ -1,12 +1,16 @@
 """Tests for src/services/replay.py — ReplayService."""
 
+import asyncio
+import json
 import sys
-from datetime import datetime, timezone
+from datetime import datetime, timedelta, timezone
 from pathlib import Path
 from types import ModuleType
 from unittest.mock import AsyncMock, MagicMock, patch
 
+import pandas as pd
 import pytest
+from prometheus_client import REGISTRY
 
 # Stub nats modules if not installed so the import doesn't fail at collection
 if "nats" not in sys.modules:
@@ -24,6 +28,7 @@ if "nats" not in sys.modules:
     sys.modules["nats.aio.msg"] = _nats_aio_msg
     sys.modules["nats.aio.subscription"] = _nats_aio_sub
 
+from src.config import ReplayConfig, SessionFilterConfig
 from src.services.replay import ReplayService
 
 
@@ -42,6 +47,24 @@ def _mock_config(speed: str = "1x", source: str = "sample_data/"):
     }
     config.replay.speed = speed
     config.replay.source = source
+    config.replay.seed = 1337
+    config.replay.timestamp_jitter_ms = 0.0
+    config.replay.funding_source = None
+    config.replay.default_symbol = None
+    config.replay.validate_data = False
+    config.replay.stream_batch_rows = 0
+    config.replay.read_workers = 1
+    config.replay.start_index = 0
+    config.replay.max_records = None
+    config.replay.downsample = 1
+    config.replay.resume = False
+    config.replay.max_duration_s = None
+    config.replay.record_budget = None
+    config.replay.dedupe_timestamps = False
+    config.replay.gap_alert_seconds = None
+    config.replay.max_gap_multiplier = 5.0
+    config.replay.preserve_zero_sizes = False
+    config.replay.session = None
     config.trading.symbols = ["BTCUSDT"]
     return config
 
@@ -88,6 +111,18 @@ class TestReplayBuildSnapshot:
         snap = ReplayService._build_snapshot("ETH", ts, 100, 110, 90, 95, 50)
         assert snap["last_side"] == "sell"
 
+    def test_build_snapshot_zero_volume_sizes(self):
+        """Zero volume floors sizes at 1 unless zero sizes are preserved."""
+        ts = datetime(2024, 1, 1, tzinfo=timezone.utc)
+        clamped = ReplayService._build_snapshot("ETH", ts, 100, 110, 90, 95, 0)
+        assert clamped["bid_size"] == clamped["ask_size"] == 1
+
+        preserved = ReplayService._build_snapshot(
+            "ETH", ts, 100, 110, 90, 95, 0, preserve_zero_sizes=True
+        )
+        assert preserved["bid_size"] == preserved["ask_size"] == 0
+        assert preserved["last_size"] == 0
+
 
 class TestReplayParseSource:
     """Test ReplayService._parse_source()."""
@@ -103,6 +138,58 @@ class TestReplayParseSource:
         assert isinstance(path, Path)
 
 
+class TestReplayValidateDataset:
+    """Test ReplayService._validate_dataset()."""
+
+    def test_clean_dataset_has_no_issues(self):
+        df = pd.DataFrame(
+            {
+                "timestamp": pd.date_range("2024-01-01", periods=5, freq="1min", tz="UTC"),
+                "close": [100.0, 101.0, 102.0, 101.5, 103.0],
+            }
+        )
+        issues = ReplayService._validate_dataset(df, 5.0)
+        assert sum(issues.values()) == 0
+
+    def test_detects_each_issue_type(self):
+        timestamps = pd.to_datetime(
+            [
+                "2024-01-01T00:00:00Z",
+                "2024-01-01T00:01:00Z",
+                "2024-01-01T00:01:00Z",
+                "2024-01-01T00:02:00Z",
+                "2024-01-01T00:03:00Z",
+                "2024-01-01T02:00:00Z",
+            ],
+            utc=True,
+        )
+        df = pd.DataFrame(
+            {
+                "timestamp": timestamps,
+                "close": [100.0, -1.0, 100.0, 100.0, 100.0, 100.0],
+                "best_bid": [99.0, 99.0, 101.0, 99.0, 99.0, 99.0],
+                "best_ask": [101.0, 101.0, 100.0, 101.0, 101.0, 101.0],
+            }
+        )
+        issues = ReplayService._validate_dataset(df, 5.0)
+        assert issues["duplicate_timestamps"] == 1
+        assert issues["negative_prices"] == 1
+        assert issues["crossed_quotes"] == 1
+        assert issues["time_gaps"] == 1
+
+    def test_duplicates_checked_per_symbol(self):
+        ts = pd.date_range("2024-01-01", periods=3, freq="1min", tz="UTC")
+        df = pd.DataFrame(
+            {
+                "timestamp": list(ts) * 2,
+                "symbol": ["BTCUSDT"] * 3 + ["ETHUSDT"] * 3,
+                "close": [1.0] * 6,
+            }
+        )
+        issues = ReplayService._validate_dataset(df, 5.0)
+        assert issues["duplicate_timestamps"] == 0
+
+
 class TestReplayCoerceTimestamp:
     """Test ReplayService._coerce_timestamp()."""
 
@@ -136,6 +223,370 @@ class TestReplayDeriveInterval:
         assert service._derive_interval() == 0.05  # minimum
 
 
+class TestReplayFunding:
+    """Test ReplayService._attach_funding()."""
+
+    @staticmethod
+    def _bars():
+        return pd.DataFrame(
+            {
+                "timestamp": pd.to_datetime(
+                    [f"2024-01-01 0{h}:00" for h in range(5)], utc=True
+                ),
+                "close": [100.0] * 5,
+            }
+        )
+
+    def test_absent_funding_column_left_alone(self):
+        df = ReplayService._attach_funding(self._bars())
+        assert "funding_rate" not in df.columns
+
+    def test_sparse_funding_column_forward_filled(self):
+        df = self._bars()
+        df["funding"] = [None, 0.0001, None, -0.0002, None]
+        df = ReplayService._attach_funding(df)
+        assert df["funding_rate"].tolist() == [0.0, 0.0001, 0.0001, -0.0002, -0.0002]
+
+    def test_schedule_matched_as_of_each_bar(self):
+        schedule = pd.DataFrame(
+            {
+                "timestamp": ["2024-01-01 01:30", "2024-01-01 03:00"],
+                "funding_rate": [0.0003, 0.0005],
+            }
+        )
+        df = ReplayService._attach_funding(self._bars(), schedule)
+        assert df["funding_rate"].tolist() == [0.0, 0.0, 0.0003, 0.0005, 0.0005]
+
+    def test_build_snapshot_carries_funding_rate(self):
+        ts = datetime(2024, 1, 1, tzinfo=timezone.utc)
+        snap = ReplayService._build_snapshot(
+            "BTCUSDT", ts, 100, 101, 99, 100, 10, funding_rate=0.0001
+        )
+        assert snap["funding_rate"] == 0.0001
+
+
+class TestReplaySessionFilter:
+    """Test the replay trading-hours filter."""
+
+    def test_mask_keeps_weekday_rth_only(self):
+        # 2024-01-05 is a Friday; New York is UTC-5 in January
+        timestamps = pd.Series(
+            pd.to_datetime(
+                [
+                    "2024-01-05 14:00",  # 09:00 NY, before the open
+                    "2024-01-05 14:30",  # 09:30 NY, at the open
+                    "2024-01-05 20:59",  # 15:59 NY
+                    "2024-01-05 21:00",  # 16:00 NY, at the close
+                    "2024-01-06 15:00",  # Saturday
+                ],
+                utc=True,
+            )
+        )
+        session = SessionFilterConfig(
+            timezone="America/New_York", windows=["09:30-16:00"], weekdays=[0, 1, 2, 3, 4]
+        )
+        mask = ReplayService._session_mask(timestamps, session)
+        assert mask.tolist() == [False, True, True, False, False]
+
+    def test_window_past_midnight(self):
+        timestamps = pd.Series(
+            pd.to_datetime(["2024-01-05 23:00", "2024-01-06 01:00", "2024-01-06 03:00"], utc=True)
+        )
+        mask = ReplayService._session_mask(
+            timestamps, SessionFilterConfig(windows=["22:00-02:00"])
+        )
+        assert mask.tolist() == [True, True, False]
+
+    def test_invalid_window_rejected(self):
+        with pytest.raises(ValueError, match="HH:MM-HH:MM"):
+            SessionFilterConfig(windows=["9:30 to 16:00"])
+
+    def test_load_drops_out_of_session_records(self, service, tmp_path):
+        path = tmp_path / "bars.parquet"
+        TestReplayStreaming._write_bars(path, rows=10)
+        service.config = _mock_config(source=f"parquet://{path}")
+        # Bars run 00:00-00:09 UTC; keep 00:03-00:06
+        service.config.replay.session = SessionFilterConfig(windows=["00:03-00:07"])
+
+        dataset = service._load_dataset()
+
+        assert len(dataset) == 4
+        assert dataset[0]["timestamp"].startswith("2024-01-01T00:03")
+
+
+class TestReplayStreaming:
+    """Test batched parquet loading in ReplayService._load_dataset()."""
+
+    @staticmethod
+    def _write_bars(path, rows=10):
+        df = pd.DataFrame(
+            {
+                "timestamp": pd.date_range("2024-01-01", periods=rows, freq="1min", tz="UTC"),
+                "open": [100.0 + i for i in range(rows)],
+                "high": [101.0 + i for i in range(rows)],
+                "low": [99.0 + i for i in range(rows)],
+                "close": [100.5 + i for i in range(rows)],
+                "volume": [10.0] * rows,
+                "funding": [0.0001] + [None] * (rows - 1),
+            }
+        )
+        df.to_parquet(path, row_group_size=3)
+
+    def test_batched_load_matches_whole_file(self, service, tmp_path):
+        path = tmp_path / "bars.parquet"
+        self._write_bars(path)
+
+        service.config = _mock_config(source=f"parquet://{path}")
+        whole = service._load_dataset()
+        service.config.replay.stream_batch_rows = 3
+        with patch.object(
+            ReplayService, "_read_source", side_effect=AssertionError("not batched")
+        ):
+            batched = service._load_dataset()
+
+        assert len(batched) == 10
+        assert batched == whole
+        # Funding observed in the first batch carries into later ones
+        assert all(snap["funding_rate"] == 0.0001 for snap in batched)
+
+    def test_small_file_read_whole(self, tmp_path):
+        path = tmp_path / "bars.parquet"
+        self._write_bars(path, rows=5)
+        frames = list(
+            ReplayService._iter_source(f"parquet://{path}", batch_rows=100)
+        )
+        assert [len(df) for df in frames] == [5]
+
+    def test_directory_load_with_workers(self, tmp_path):
+        for name in ("BTCUSDT", "ETHUSDT"):
+            self._write_bars(tmp_path / f"{name}.parquet", rows=4)
+        df = ReplayService._read_source(f"parquet://{tmp_path}", workers=2)
+        assert len(df) == 8
+        assert df["symbol"].tolist() == ["BTCUSDT"] * 4 + ["ETHUSDT"] * 4
+
+
+class TestReplaySlice:
+    """Test ReplayService._slice_dataset() record-count window."""
+
+    @staticmethod
+    def _dataset(n=10):
+        return [{"timestamp": f"2024-01-01T00:{i:02d}:00+00:00", "i": i} for i in range(n)]
+
+    def test_defaults_keep_everything(self):
+        data = self._dataset()
+        assert ReplayService._slice_dataset(data, 0, None) is data
+
+    def test_start_index_and_max_records(self):
+        sliced = ReplayService._slice_dataset(self._dataset(), 3, 4)
+        assert [row["i"] for row in sliced] == [3, 4, 5, 6]
+
+    def test_max_records_past_end_truncates(self):
+        sliced = ReplayService._slice_dataset(self._dataset(), 8, 5)
+        assert [row["i"] for row in sliced] == [8, 9]
+
+    def test_start_index_out_of_bounds(self):
+        with pytest.raises(ValueError, match="beyond the dataset"):
+            ReplayService._slice_dataset(self._dataset(), 10, None)
+
+
+class TestReplayDedupe:
+    """Test ReplayService._dedupe_dataset() duplicate removal."""
+
+    def test_keeps_last_per_symbol_and_timestamp(self):
+        data = [
+            {"symbol": "BTCUSDT", "timestamp": "t0", "i": 0},
+            {"symbol": "ETHUSDT", "timestamp": "t0", "i": 1},
+            {"symbol": "BTCUSDT", "timestamp": "t0", "i": 2},
+            {"symbol": "BTCUSDT", "timestamp": "t1", "i": 3},
+        ]
+        assert [row["i"] for row in ReplayService._dedupe_dataset(data)] == [1, 2, 3]
+
+    def test_no_duplicates_returns_input(self):
+        data = [{"symbol": "BTCUSDT", "timestamp": f"t{i}"} for i in range(3)]
+        assert ReplayService._dedupe_dataset(data) is data
+
+
+class TestReplayDownsample:
+    """Test ReplayService._downsample_dataset() every-Nth thinning."""
+
+    @staticmethod
+    def _dataset(n=10):
+        return [{"timestamp": f"2024-01-01T00:{i:02d}:00+00:00", "i": i} for i in range(n)]
+
+    def test_one_keeps_everything(self):
+        data = self._dataset()
+        assert ReplayService._downsample_dataset(data, 1) is data
+
+    def test_keeps_every_nth_from_first(self):
+        thinned = ReplayService._downsample_dataset(self._dataset(), 3)
+        assert [row["i"] for row in thinned] == [0, 3, 6, 9]
+
+    def test_rejects_zero(self):
+        with pytest.raises(ValueError):
+            ReplayConfig(downsample=0)
+
+
+class TestReplayJitter:
+    """Test ReplayService._next_delay() emission-time jitter."""
+
+    def test_no_jitter_by_default(self, service):
+        service.config = _mock_config()
+        service._interval = 0.5
+        assert service._next_delay() == 0.5
+
+    def test_jitter_is_bounded_and_seeded(self, service):
+        service.config = _mock_config()
+        service.config.replay.timestamp_jitter_ms = 100.0
+        service._interval = 0.5
+
+        service._random.seed(7)
+        delays = [service._next_delay() for _ in range(50)]
+        assert all(0.4 <= d <= 0.6 for d in delays)
+        assert len(set(delays)) > 1
+
+        service._random.seed(7)
+        assert [service._next_delay() for _ in range(50)] == delays
+
+
+class TestReplayBudget:
+    """Test the replay run budget (record count or wall-clock duration)."""
+
+    @staticmethod
+    def _service(service, **budget):
+        service.config = _mock_config()
+        for key, value in budget.items():
+            setattr(service.config.replay, key, value)
+        start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+        service._dataset = [
+            ReplayService._build_snapshot(
+                "BTCUSDT", start + timedelta(minutes=m), 100, 101, 99, 100, 10
+            )
+            for m in range(2)
+        ]
+        service._interval = 0.0
+        service._running.set()
+        service.messaging = MagicMock()
+        service.messaging.publish = AsyncMock()
+        return service
+
+    async def test_record_budget_spans_passes(self, service):
+        svc = self._service(service, record_budget=3)
+
+        await asyncio.wait_for(svc._run_loop(), timeout=1.0)
+
+        calls = svc.messaging.publish.call_args_list
+        assert len(calls) == 4
+        subject, event = calls[-1].args
+        assert subject == "replay.status"
+        assert event["event"] == "completed"
+        assert event["reason"] == "record_budget"
+        assert event["records"] == 3
+        assert svc.status_payload()["stop_reason"] == "record_budget"
+        assert svc.state == "completed"
+
+    async def test_max_duration_stops_first(self, service):
+        svc = self._service(service, record_budget=100, max_duration_s=5.0)
+
+        with patch("src.services.replay.time.monotonic", side_effect=[0.0, 1.0, 6.0, 6.0]):
+            await asyncio.wait_for(svc._run_loop(), timeout=1.0)
+
+        _, event = svc.messaging.publish.call_args_list[-1].args
+        assert event["reason"] == "max_duration"
+        assert event["records"] == 2
+        assert event["elapsed_s"] == 6.0
+
+    async def test_max_duration_runs_out_while_paused(self, service):
+        svc = self._service(service, max_duration_s=0.05)
+        svc._running.clear()
+
+        await asyncio.wait_for(svc._run_loop(), timeout=1.0)
+
+        [call] = svc.messaging.publish.call_args_list
+        assert call.args[1]["reason"] == "max_duration"
+        assert call.args[1]["records"] == 0
+        assert svc.state == "completed"
+
+    async def test_resume_after_complete_is_ignored(self, service):
+        svc = self._service(service, record_budget=1)
+        await asyncio.wait_for(svc._run_loop(), timeout=1.0)
+
+        await svc.set_state("resume")
+        await svc.set_state("pause")
+
+        assert svc.state == "completed"
+        assert svc.status_payload()["stop_reason"] == "record_budget"
+        assert svc.last_control is None
+
+    async def test_reload_after_complete_restarts_budget(self, service):
+        svc = self._service(service, record_budget=1)
+        await asyncio.wait_for(svc._run_loop(), timeout=1.0)
+        assert svc.state == "completed"
+
+        svc.config.replay.record_budget = 3
+        with patch.object(svc, "_load_dataset", return_value=list(svc._dataset)), \
+                patch.object(svc, "_derive_interval", return_value=0.0):
+            payload = await svc.load_source("parquet://other/")
+
+        assert payload["state"] == "running"
+        assert payload["stop_reason"] is None
+        await asyncio.wait_for(svc._loop_task, timeout=1.0)
+        _, event = svc.messaging.publish.call_args_list[-1].args
+        assert event["event"] == "completed"
+        assert event["records"] == 3
+
+
+class TestReplayCheckpoint:
+    """Test ReplayService checkpoint write/resume."""
+
+    def _configure(self, service, tmp_path, source="parquet://bars/"):
+        service.config = _mock_config(source=source)
+        service.config.replay.checkpoint_path = str(tmp_path / "ckpt.json")
+        service._dataset = [{"timestamp": str(i)} for i in range(10)]
+
+    def test_roundtrip_resumes_at_saved_index(self, service, tmp_path):
+        self._configure(service, tmp_path)
+        service._write_checkpoint(4, "2024-01-01T00:04:00+00:00")
+        assert service._load_checkpoint() == 4
+
+    def test_missing_checkpoint_starts_fresh(self, service, tmp_path):
+        self._configure(service, tmp_path)
+        assert service._load_checkpoint() == 0
+
+    def test_source_mismatch_starts_fresh(self, service, tmp_path):
+        self._configure(service, tmp_path, source="parquet://old/")
+        service._write_checkpoint(4, "ts")
+        service.config.replay.source = "parquet://new/"
+        assert service._load_checkpoint() == 0
+
+    def test_out_of_range_index_starts_fresh(self, service, tmp_path):
+        self._configure(service, tmp_path)
+        service._write_checkpoint(25, "ts")
+        assert service._load_checkpoint() == 0
+
+    @pytest.mark.parametrize(
+        "setting, value",
+        [
+            ("start_index", 100),
+            ("max_records", 500),
+            ("downsample", 5),
+            ("dedupe_timestamps", True),
+        ],
+    )
+    def test_changed_settings_start_fresh(self, service, tmp_path, setting, value):
+        self._configure(service, tmp_path)
+        service._write_checkpoint(4, "ts")
+        setattr(service.config.replay, setting, value)
+        assert service._load_checkpoint() == 0
+
+    def test_checkpoint_without_settings_hash_ignored(self, service, tmp_path):
+        self._configure(service, tmp_path)
+        Path(service.config.replay.checkpoint_path).write_text(
+            json.dumps({"source": service.source, "index": 4, "timestamp": "ts"}),
+            encoding="utf-8",
+        )
+        assert service._load_checkpoint() == 0
+
+
 # ---------------------------------------------------------------------------
 # Service lifecycle tests
 # ---------------------------------------------------------------------------
@@ -217,3 +668,107 @@ class TestReplayOnStartupEmpty:
         mock_client.close.assert_awaited_once()
         assert service.messaging is None
         assert service._dataset == []
+
+
+class TestReplayLoadControl:
+    """Test the ``load`` replay.control command."""
+
+    @staticmethod
+    def _load_msg(source):
+        msg = MagicMock()
+        msg.data = json.dumps({"action": "load", "source": source}).encode("utf-8")
+        return msg
+
+    @staticmethod
+    def _status_replies(service):
+        return [
+            call.args[1]
+            for call in service.messaging.publish.call_args_list
+            if call.args[0] == "replay.status"
+        ]
+
+    @staticmethod
+    async def _stop(service):
+        service._loop_task.cancel()
+        with pytest.raises(asyncio.CancelledError):
+            await service._loop_task
+
+    async def test_load_swaps_dataset_and_restarts(self, service, tmp_path):
+        first, second = tmp_path / "first.parquet", tmp_path / "second.parquet"
+        TestReplayStreaming._write_bars(first, rows=10)
+        TestReplayStreaming._write_bars(second, rows=4)
+        service.config = _mock_config(source=f"parquet://{first}")
+        service.config.replay.resume = False
+        service.messaging = AsyncMock()
+        service._dataset = service._load_dataset()
+
+        await service._handle_control(self._load_msg(f"parquet://{second}"))
+
+        [reply] = self._status_replies(service)
+        assert reply["ok"] is True
+        assert reply["source"] == f"parquet://{second}"
+        assert service.dataset_size == 4
+        assert service.state == "running"
+        await self._stop(service)
+
+    async def test_bad_source_keeps_active_replay(self, service, tmp_path):
+        path = tmp_path / "bars.parquet"
+        TestReplayStreaming._write_bars(path, rows=10)
+        service.config = _mock_config(source=f"parquet://{path}")
+        service.config.replay.resume = False
+        service.messaging = AsyncMock()
+        await service.load_source(f"parquet://{path}")
+        active = service._loop_task
+
+        await service._handle_control(self._load_msg(f"parquet://{tmp_path}/missing.parquet"))
+
+        [reply] = self._status_replies(service)
+        assert reply["ok"] is False and reply["error"]
+        assert service._loop_task is active and not active.done()
+        assert service.source == f"parquet://{path}"
+        assert service.dataset_size == 10
+        await self._stop(service)
+
+    async def test_plain_text_pause_still_accepted(self, service):
+        msg = MagicMock()
+        msg.data = b"pause"
+        service._running.set()
+        await service._handle_control(msg)
+        assert service.state == "paused"
+
+
+class TestReplayGapMetrics:
+    """Test per-transition data-gap tracking during replay."""
+
+    @staticmethod
+    def _dataset(symbol, minutes):
+        start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+        return [
+            ReplayService._build_snapshot(
+                symbol, start + timedelta(minutes=m), 100, 101, 99, 100, 10
+            )
+            for m in minutes
+        ]
+
+    def test_threshold_derived_from_median_spacing(self, service):
+        service.config = _mock_config()
+        service._dataset = self._dataset("GAPA", [0, 1, 2, 3, 20])
+        assert service._derive_gap_threshold() == 300.0
+
+        service.config.replay.gap_alert_seconds = 30.0
+        assert service._derive_gap_threshold() == 30.0
+
+    def test_gaps_over_threshold_counted_and_kept(self, service):
+        service.config = _mock_config()
+        service._dataset = self._dataset("GAPB", [0, 1, 2, 3, 20, 21, 60])
+        service._gap_threshold_s = service._derive_gap_threshold()
+
+        for snapshot in service._dataset:
+            service._observe_gap(snapshot)
+
+        labels = {"symbol": "GAPB"}
+        assert REGISTRY.get_sample_value("replay_data_gap_seconds_count", labels) == 6
+        assert REGISTRY.get_sample_value("replay_data_gaps_total", labels) == 2
+        worst = sorted(service._worst_gaps, reverse=True)
+        assert [gap for gap, *_ in worst] == [2340.0, 1020.0]
+        assert worst[0][2] == "2024-01-01T00:21:00+00:00"
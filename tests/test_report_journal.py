@@ -0,0 +1,72 @@
+"""Tests for src/report_journal.py — Parquet journal of execution reports."""
+
+import threading
+
+import pytest
+
+pq = pytest.importorskip("pyarrow.parquet")
+
+from src.report_journal import REPORT_COLUMNS, ParquetReportJournal
+
+
+def _fill(client_id, price):
+    return {
+        "run_id": "exec-1",
+        "mode": "paper",
+        "timestamp": "2024-01-01T00:00:00+00:00",
+        "client_id": client_id,
+        "symbol": "BTCUSDT",
+        "executed": True,
+        "price": price,
+        "quantity": 1,
+        "maker": False,
+        "agent_id": 7,
+    }
+
+
+def test_reports_written_across_flushes_and_readable_after_close(tmp_path):
+    journal = ParquetReportJournal(str(tmp_path / "journal"), "exec-1")
+    journal.append(_fill("c1", 100.0))
+    assert journal.flush() == 1
+    journal.append(_fill("c2", 101.5))
+    journal.append({"client_id": "c3", "executed": False, "error": "expired"})
+    journal.close()
+
+    assert journal.path == tmp_path / "journal" / "exec-1.parquet"
+    table = pq.read_table(journal.path)
+    assert table.column_names == [name for name, _ in REPORT_COLUMNS]
+    rows = table.to_pylist()
+    assert [row["client_id"] for row in rows] == ["c1", "c2", "c3"]
+    assert rows[1]["price"] == 101.5
+    assert rows[0]["quantity"] == 1.0
+    assert rows[0]["timestamp"].year == 2024
+    assert rows[2]["price"] is None
+    assert rows[2]["error"] == "expired"
+
+
+def test_close_without_reports_writes_nothing(tmp_path):
+    journal = ParquetReportJournal(str(tmp_path), "exec-empty")
+    journal.close()
+    assert not journal.path.exists()
+
+
+def test_appends_during_threaded_flushes_are_all_written(tmp_path):
+    journal = ParquetReportJournal(str(tmp_path), "exec-threaded")
+    done = threading.Event()
+
+    def _flush_until_done():
+        while not done.is_set():
+            journal.flush()
+
+    flusher = threading.Thread(target=_flush_until_done)
+    flusher.start()
+    try:
+        for i in range(500):
+            journal.append(_fill(f"c{i}", 100.0))
+    finally:
+        done.set()
+        flusher.join()
+    journal.close()
+
+    rows = pq.read_table(journal.path).to_pylist()
+    assert [row["client_id"] for row in rows] == [f"c{i}" for i in range(500)]
@@ -7,6 +7,7 @@ from types import ModuleType
 from unittest.mock import AsyncMock, MagicMock, patch
 
 import pytest
+from fastapi import HTTPException
 
 # Stub nats modules if not installed so the import doesn't fail at collection
 if "nats" not in sys.modules:
@@ -24,7 +25,8 @@ if "nats" not in sys.modules:
     sys.modules["nats.aio.msg"] = _nats_aio_msg
     sys.modules["nats.aio.subscription"] = _nats_aio_sub
 
-from src.services.reporter import ReporterService
+from src.config import ReportingConfig
+from src.services.reporter import ReporterService, compare_runs
 
 
 # ---------------------------------------------------------------------------
@@ -38,8 +40,10 @@ def _mock_config():
     config.messaging.servers = ["nats://localhost:4222"]
     config.messaging.subjects = {
         "performance": "perf.metrics",
+        "executions": "trading.executions",
         "reports": "reports.performance",
     }
+    config.reporting = ReportingConfig()
     return config
 
 
@@ -63,7 +67,7 @@ class TestReporterService:
     @patch("src.services.reporter.MessagingClient")
     @patch("src.services.reporter.load_config")
     async def test_on_startup_connects_messaging(self, mock_load_config, MockMessaging, reporter):
-        """Startup loads config, connects messaging, subscribes to performance subject."""
+        """Startup subscribes to metrics, fills, runs and shadow fills."""
         mock_load_config.return_value = _mock_config()
         mock_client = AsyncMock()
         mock_sub = AsyncMock()
@@ -74,9 +78,13 @@ class TestReporterService:
 
         mock_load_config.assert_called_once()
         mock_client.connect.assert_awaited_once()
-        mock_client.subscribe.assert_awaited_once()
-        # First arg of subscribe should be the performance subject
-        assert mock_client.subscribe.call_args[0][0] == "perf.metrics"
+        subjects = [call[0][0] for call in mock_client.subscribe.call_args_list]
+        assert subjects == [
+            "perf.metrics",
+            "trading.executions",
+            "run.started",
+            "trading.executions.shadow",
+        ]
 
         # Cleanup
         reporter._summary_task.cancel()
@@ -88,17 +96,20 @@ class TestReporterService:
     @patch("src.services.reporter.MessagingClient")
     @patch("src.services.reporter.load_config")
     async def test_on_shutdown_cleans_up(self, mock_load_config, MockMessaging, reporter):
-        """Shutdown unsubscribes, cancels summary task, closes messaging."""
+        """Shutdown cancels summary task, drains messaging, then unsubscribes."""
         mock_load_config.return_value = _mock_config()
         mock_client = AsyncMock()
         mock_sub = AsyncMock()
         mock_client.subscribe.return_value = mock_sub
         MockMessaging.return_value = mock_client
+        calls = []
+        mock_client.close.side_effect = lambda: calls.append("close")
+        mock_sub.unsubscribe.side_effect = lambda: calls.append("unsubscribe")
 
         await reporter.on_startup()
         await reporter.on_shutdown()
 
-        mock_sub.unsubscribe.assert_awaited_once()
+        assert calls == ["close"] + ["unsubscribe"] * 4
         mock_client.close.assert_awaited_once()
         assert reporter.messaging is None
         assert reporter._summary_task is None
@@ -156,3 +167,254 @@ class TestReporterService:
         assert published_subject == "reports.performance"
         assert "timestamp" in published_data
         assert published_data["equity"] == 50000
+
+    def test_shortfall_quantity_weighted_per_symbol(self, reporter):
+        """Fills fold into a quantity-weighted shortfall; rejects are ignored."""
+        reporter.record_execution(
+            {"symbol": "BTCUSDT", "executed": True, "quantity": 1.0, "shortfall_bps": 2.0}
+        )
+        reporter.record_execution(
+            {"symbol": "BTCUSDT", "executed": True, "quantity": 3.0, "shortfall_bps": 6.0}
+        )
+        reporter.record_execution(
+            {"symbol": "BTCUSDT", "executed": False, "quantity": 0.0, "shortfall_bps": 0.0}
+        )
+
+        btc = reporter.symbol_summary()["BTCUSDT"]
+        assert btc["fills"] == 2
+        assert btc["quantity"] == 4.0
+        assert btc["avg_shortfall_bps"] == 5.0
+
+    def test_funding_split_from_trading_pnl(self, reporter):
+        """Funding paid and received are tracked per symbol, apart from PnL."""
+        reporter.record_execution(
+            {"symbol": "BTCUSDT", "executed": True, "quantity": 1.0,
+             "realized_pnl": 0.0, "funding": 5.0}
+        )
+        reporter.record_execution(
+            {"symbol": "ETHUSDT", "executed": True, "quantity": 2.0,
+             "realized_pnl": 12.0, "funding": -1.5}
+        )
+
+        summary = reporter.symbol_summary()
+        assert summary["BTCUSDT"]["funding_paid"] == 5.0
+        assert summary["BTCUSDT"]["funding_net"] == 5.0
+        assert summary["ETHUSDT"]["funding_received"] == 1.5
+        assert summary["ETHUSDT"]["funding_net"] == -1.5
+        assert summary["ETHUSDT"]["realized_pnl"] == 12.0
+
+    async def test_summary_includes_per_symbol_breakdown(self, reporter):
+        """Summary carries the per-symbol block even before any metrics arrive."""
+        reporter.config = _mock_config()
+        reporter.messaging = AsyncMock()
+        msg = MagicMock()
+        msg.data = json.dumps(
+            {"symbol": "ETHUSDT", "executed": True, "quantity": 2.0, "shortfall_bps": -1.5}
+        ).encode("utf-8")
+        await reporter._handle_execution(msg)
+
+        with patch("asyncio.sleep", side_effect=asyncio.CancelledError):
+            with pytest.raises(asyncio.CancelledError):
+                await reporter._publish_summary_loop()
+
+        published = reporter.messaging.publish.call_args[0][1]
+        assert published["per_symbol"]["ETHUSDT"]["avg_shortfall_bps"] == -1.5
+
+    async def test_summary_carries_run_params(self, reporter):
+        """Key parameters from run.started are attached to the summary."""
+        reporter.config = _mock_config()
+        reporter.messaging = AsyncMock()
+        reporter._latest_metrics = {"equity": 50000}
+        msg = MagicMock()
+        msg.data = json.dumps(
+            {
+                "run_id": "exec-20240101000000",
+                "mode": "replay",
+                "seed": 7,
+                "paper": {"fee_bps": 5.0, "slippage_bps": 2.0, "seed": 7, "ack_mode": "async"},
+                "config": {"app_mode": "replay"},
+            }
+        ).encode("utf-8")
+        await reporter._handle_run_started(msg)
+
+        with patch("asyncio.sleep", side_effect=asyncio.CancelledError):
+            with pytest.raises(asyncio.CancelledError):
+                await reporter._publish_summary_loop()
+
+        run = reporter.messaging.publish.call_args[0][1]["run"]
+        assert run == {
+            "run_id": "exec-20240101000000",
+            "mode": "replay",
+            "seed": 7,
+            "fee_bps": 5.0,
+            "slippage_bps": 2.0,
+        }
+
+    async def test_summary_carries_latest_account_equity(self, reporter):
+        """The last fill's balance and equity are attached to the summary."""
+        reporter.config = _mock_config()
+        reporter.messaging = AsyncMock()
+        for balance, equity in ((99_000.0, 99_500.0), (98_000.0, 101_000.0)):
+            reporter.record_execution(
+                {"symbol": "BTCUSDT", "executed": True, "quantity": 1.0,
+                 "balance": balance, "equity": equity, "timestamp": "2024-01-01T00:00:00"}
+            )
+
+        with patch("asyncio.sleep", side_effect=asyncio.CancelledError):
+            with pytest.raises(asyncio.CancelledError):
+                await reporter._publish_summary_loop()
+
+        account = reporter.messaging.publish.call_args[0][1]["account"]
+        assert account["balance"] == 98_000.0
+        assert account["equity"] == 101_000.0
+
+    async def test_shadow_divergence_pairs_by_client_id(self, reporter):
+        """Shadow fills are compared with the primary fill of the same client_id."""
+        reporter.config = _mock_config()
+        reporter.messaging = AsyncMock()
+        reporter._latest_metrics = {"equity": 50000}
+        fills = [
+            ("c1", False, 100.0, 20.0),
+            ("c1", True, 100.1, 35.0),
+            ("c2", False, 200.0, 10.0),
+            ("c2", True, 199.8, 10.0),
+        ]
+        for client_id, is_shadow, price, latency in fills:
+            msg = MagicMock()
+            msg.data = json.dumps(
+                {"client_id": client_id, "is_shadow": is_shadow, "executed": True,
+                 "quantity": 1.0, "price": price, "latency_ms": latency}
+            ).encode("utf-8")
+            if is_shadow:
+                await reporter._handle_shadow_execution(msg)
+            else:
+                await reporter._handle_execution(msg)
+        # c3 filled on the primary only; the shadow order was acknowledged
+        reporter.record_leg(
+            {"client_id": "c3", "executed": True, "quantity": 1.0, "price": 50.0}
+        )
+        reporter.record_leg({"client_id": "c3", "is_shadow": True, "executed": False})
+        # Unpaired: ignored
+        reporter.record_leg(
+            {"client_id": "c4", "executed": True, "quantity": 1.0, "price": 50.0}
+        )
+
+        with patch("asyncio.sleep", side_effect=asyncio.CancelledError):
+            with pytest.raises(asyncio.CancelledError):
+                await reporter._publish_summary_loop()
+
+        divergence = reporter.messaging.publish.call_args[0][1]["shadow_divergence"]
+        assert divergence["pairs"] == 3
+        assert divergence["filled_pairs"] == 2
+        assert divergence["avg_price_diff_bps"] == pytest.approx(0.0)
+        assert divergence["max_abs_price_diff_bps"] == pytest.approx(10.0)
+        assert divergence["avg_latency_diff_ms"] == pytest.approx(7.5)
+        assert divergence["primary_fill_rate"] == 1.0
+        assert divergence["shadow_fill_rate"] == pytest.approx(0.6667)
+        assert divergence["fill_rate_diff"] == pytest.approx(-0.3333)
+
+    def test_no_divergence_without_shadow(self, reporter):
+        reporter.record_leg(
+            {"client_id": "c1", "executed": True, "quantity": 1.0, "price": 50.0}
+        )
+        assert reporter.divergence_summary() is None
+
+    async def test_compare_runs_reports_metrics_and_deltas(self, reporter):
+        """Two runs are compared on PnL, Sharpe, drawdown, fees and trades."""
+        fills = {
+            "run-a": [(1.0, 10.0, 100_000.0), (1.0, -20.0, 99_000.0), (1.0, 5.0, 99_500.0)],
+            "run-b": [(2.0, 30.0, 100_500.0), (2.0, 10.0, 101_000.0)],
+        }
+        for run_id, run_fills in fills.items():
+            for fees, realized, equity in run_fills:
+                reporter.record_execution(
+                    {"run_id": run_id, "symbol": "BTCUSDT", "executed": True,
+                     "quantity": 1.0, "fees": fees, "realized_pnl": realized,
+                     "equity": equity}
+                )
+
+        comparison = reporter.compare_runs("run-a", "run-b")
+
+        assert comparison["a"]["trades"] == 3
+        assert comparison["a"]["pnl"] == pytest.approx(-8.0)
+        assert comparison["a"]["max_drawdown"] == pytest.approx(0.01)
+        assert comparison["b"]["fees"] == 4.0
+        assert comparison["b"]["max_drawdown"] == 0.0
+        assert comparison["delta"]["pnl"] == pytest.approx(44.0)
+        assert comparison["delta"]["trades"] == -1
+
+    async def test_compare_endpoint_404_for_unknown_run(self, reporter):
+        msg = MagicMock()
+        msg.data = json.dumps({"run_id": "run-a", "mode": "backtest"}).encode("utf-8")
+        await reporter._handle_run_started(msg)
+
+        with patch("src.services.reporter.service", reporter):
+            body = await compare_runs(a="run-a", b="run-a")
+            assert body["a"]["trades"] == 0
+            with pytest.raises(HTTPException) as excinfo:
+                await compare_runs(a="run-a", b="run-missing")
+        assert excinfo.value.status_code == 404
+
+    async def test_pnl_converted_into_reporting_currency(self, reporter):
+        """Per-symbol PnL is reported natively and in the reporting currency."""
+        reporter.config = _mock_config()
+        reporter.config.reporting = ReportingConfig(
+            currency="USD",
+            quote_currencies={"BTCEUR": "EUR", "BTCUSD": "USD"},
+            fx_rates={"EUR": 1.1},
+        )
+        reporter.messaging = AsyncMock()
+        reporter.record_execution(
+            {"symbol": "BTCEUR", "executed": True, "quantity": 1.0,
+             "realized_pnl": 100.0, "funding": 10.0}
+        )
+        reporter.record_execution(
+            {"symbol": "BTCUSD", "executed": True, "quantity": 1.0, "realized_pnl": -50.0}
+        )
+
+        with patch("asyncio.sleep", side_effect=asyncio.CancelledError):
+            with pytest.raises(asyncio.CancelledError):
+                await reporter._publish_summary_loop()
+
+        published = reporter.messaging.publish.call_args[0][1]
+        eur = published["per_symbol"]["BTCEUR"]
+        assert eur["realized_pnl"] == 100.0
+        assert eur["quote_currency"] == "EUR"
+        assert eur["realized_pnl_converted"] == pytest.approx(110.0)
+        assert eur["funding_net_converted"] == pytest.approx(11.0)
+        assert published["converted_pnl"] == {
+            "currency": "USD",
+            "realized_pnl": pytest.approx(60.0),
+            "funding_net": pytest.approx(11.0),
+        }
+
+    def test_reporting_config_requires_fx_rates(self):
+        with pytest.raises(ValueError):
+            ReportingConfig(currency="USD", quote_currencies={"BTCEUR": "EUR"})
+        assert ReportingConfig().quote_currency("BTCUSDT") is None
+
+    def test_trailing_windows_use_fill_time_and_evict(self, reporter):
+        """Windows end at the latest fill; fills beyond the largest are dropped."""
+        reporter.config = _mock_config()
+        reporter.config.reporting = ReportingConfig(windows_s={"1h": 3600, "2h": 7200})
+        fills = [
+            ("2024-01-01T00:00:00+00:00", 50.0),
+            ("2024-01-01T01:00:00+00:00", -20.0),
+            ("2024-01-01T02:00:00+00:00", 40.0),
+            ("2024-01-01T02:20:00+00:00", 10.0),
+        ]
+        for timestamp, realized in fills:
+            reporter.record_execution(
+                {"symbol": "BTCUSDT", "executed": True, "quantity": 1.0,
+                 "realized_pnl": realized, "fees": 1.0, "timestamp": timestamp}
+            )
+
+        windows = reporter.window_summary()
+        assert windows["1h"]["trades"] == 2
+        assert windows["1h"]["pnl"] == pytest.approx(48.0)
+        assert windows["1h"]["win_rate"] == 1.0
+        assert windows["2h"]["trades"] == 3
+        assert windows["2h"]["win_rate"] == pytest.approx(0.6667)
+        assert windows["2h"]["sharpe"] != 0.0
+        # The first fill fell out of the largest window
+        assert len(reporter._window_fills) == 3
@@ -0,0 +1,141 @@
+"""Tests for src/services/risk.py — target-volatility sizing and crisis mode."""
+
+import math
+from datetime import datetime, timedelta, timezone
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+
+from src.config import CrisisModeConfig, VolTargetConfig
+from src.services.risk import RiskService
+
+
+def _service(**vol_target):
+    svc = RiskService()
+    svc.config = MagicMock()
+    svc.config.risk_management.vol_target = VolTargetConfig(**vol_target)
+    return svc
+
+
+def _feed(svc, symbol, prices, step_seconds=60):
+    start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+    for i, price in enumerate(prices):
+        svc.record_price(symbol, price, start + timedelta(seconds=i * step_seconds))
+
+
+class TestVolTargetSizing:
+
+    def test_no_history_gives_full_size(self):
+        svc = _service()
+        assert svc.realized_vol() == 0.0
+        assert svc.vol_target_factor(0.0) == 1.0
+
+    def test_factor_is_inverse_of_realized_vol(self):
+        svc = _service(target_vol=0.5, min_factor=0.0)
+        assert svc.vol_target_factor(2.0) == pytest.approx(0.25)
+        assert svc.vol_target_factor(0.25) == 1.0
+
+    def test_factor_clamped_to_configured_range(self):
+        svc = _service(target_vol=0.5, min_factor=0.2, max_factor=0.8)
+        assert svc.vol_target_factor(100.0) == pytest.approx(0.2)
+        assert svc.vol_target_factor(0.1) == pytest.approx(0.8)
+
+    def test_realized_vol_is_annualised_and_takes_riskiest_symbol(self):
+        svc = _service(window=10)
+        _feed(svc, "BTCUSDT", [100, 101, 100, 101, 100, 101])
+        _feed(svc, "ETHUSDT", [100, 100.1, 100, 100.1, 100, 100.1])
+
+        returns = [math.log(101 / 100), math.log(100 / 101)] * 2 + [math.log(101 / 100)]
+        mean = sum(returns) / len(returns)
+        stdev = math.sqrt(sum((r - mean) ** 2 for r in returns) / (len(returns) - 1))
+        expected = stdev * math.sqrt(365 * 24 * 3600 / 60)
+        assert svc.realized_vol() == pytest.approx(expected)
+
+    def test_window_bounds_history(self):
+        svc = _service(window=3)
+        _feed(svc, "BTCUSDT", [100, 150, 50, 100, 100.1, 100, 100.1])
+        assert len(svc._returns["BTCUSDT"]) == 3
+        assert svc.realized_vol() < 1.0
+
+    def test_invalid_bounds_rejected(self):
+        with pytest.raises(ValueError):
+            VolTargetConfig(min_factor=0.9, max_factor=0.5)
+
+
+def _crisis_service(cooldown_seconds):
+    svc = RiskService()
+    svc.config = MagicMock()
+    svc.config.app_mode = "paper"
+    svc.config.risk_management.crisis_mode = CrisisModeConfig(
+        cooldown_seconds=cooldown_seconds
+    )
+    return svc
+
+
+class TestCrisisCooldown:
+
+    def test_trip_holds_until_cooldown_elapses(self):
+        svc = _crisis_service(cooldown_seconds=600)
+
+        assert svc._update_crisis(0.15, now=1000.0) == pytest.approx(600.0)
+        assert svc._crisis
+
+        # Conditions recover straight away, but the cooldown keeps crisis on
+        assert svc._update_crisis(0.0, now=1100.0) == pytest.approx(500.0)
+        assert svc._crisis
+
+        assert svc._update_crisis(0.0, now=1600.0) == 0.0
+        assert not svc._crisis
+
+    def test_cooldown_does_not_clear_unrecovered_crisis(self):
+        svc = _crisis_service(cooldown_seconds=60)
+        svc._update_crisis(0.15, now=0.0)
+
+        # Drawdown still above half the threshold: crisis holds past the cooldown
+        assert svc._update_crisis(0.07, now=120.0) == 0.0
+        assert svc._crisis
+
+    def test_zero_cooldown_clears_on_recovery(self):
+        svc = _crisis_service(cooldown_seconds=0)
+        svc._update_crisis(0.15, now=0.0)
+        svc._update_crisis(0.0, now=1.0)
+        assert not svc._crisis
+
+    def test_loss_streak_trip_holds_until_cooldown_elapses(self):
+        svc = _crisis_service(cooldown_seconds=300)
+
+        svc._consecutive_losses = 4
+        svc._update_crisis(0.0, now=0.0)
+        assert not svc._crisis
+
+        svc._consecutive_losses = 5
+        assert svc._update_crisis(0.0, now=10.0) == pytest.approx(300.0)
+
+        svc._consecutive_losses = 0
+        assert svc._update_crisis(0.0, now=200.0) == pytest.approx(110.0)
+        assert svc._crisis
+        svc._update_crisis(0.0, now=310.0)
+        assert not svc._crisis
+
+
+class TestShutdown:
+
+    async def test_messaging_drains_before_unsubscribe(self):
+        svc = RiskService()
+        calls = []
+        svc.messaging = MagicMock()
+        svc.messaging.close = AsyncMock(side_effect=lambda: calls.append("close"))
+        svc._query_sub = MagicMock()
+        svc._query_sub.unsubscribe = AsyncMock(
+            side_effect=lambda: calls.append("query")
+        )
+        svc._market_sub = MagicMock()
+        svc._market_sub.unsubscribe = AsyncMock(
+            side_effect=lambda: calls.append("market")
+        )
+
+        await svc.on_shutdown()
+
+        assert calls == ["close", "query", "market"]
+        assert svc.messaging is None
+        assert svc._query_sub is None and svc._market_sub is None
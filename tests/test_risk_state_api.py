@@ -0,0 +1,74 @@
+"""Tests for GET /api/risk — the risk.query request/reply proxy."""
+
+import json
+from unittest.mock import MagicMock, patch
+
+import pytest
+from fastapi import HTTPException
+
+from src.api.middleware.error_handler import ServiceWarmingUp, global_exception_handler
+from src.api.routes.risk import current_risk_state
+from src.messaging import MemoryMessagingClient
+from src.services.risk import RiskService
+
+
+def _config():
+    config = MagicMock()
+    config.messaging.subjects = {"risk_query": "risk.query"}
+    return config
+
+
+async def _bus_with_risk_service(state):
+    bus = MemoryMessagingClient()
+    await bus.connect()
+    svc = RiskService()
+    svc.messaging = bus
+    svc._latest_state = state
+    await bus.subscribe("risk.query", svc._handle_query)
+    return bus
+
+
+class TestRiskStateEndpoint:
+
+    async def test_returns_latest_published_state(self):
+        state = {
+            "crisis_mode": True,
+            "consecutive_losses": 5,
+            "drawdown": 0.12,
+            "volatility": 0.0,
+            "position_size_factor": 0.5,
+            "timestamp": "2024-01-01T00:00:00+00:00",
+        }
+        bus = await _bus_with_risk_service(state)
+
+        with patch("src.api.routes.risk.get_config", return_value=_config()):
+            body = await current_risk_state(timeout=0.5, messaging=bus)
+
+        assert body == state
+        assert [k for k, subs in bus.subscribers.items() if subs] == ["risk.query"]
+
+    async def test_warming_up_before_first_state(self):
+        bus = await _bus_with_risk_service(None)
+
+        with patch("src.api.routes.risk.get_config", return_value=_config()):
+            with pytest.raises(ServiceWarmingUp) as exc:
+                await current_risk_state(timeout=0.5, messaging=bus)
+
+        assert exc.value.service == "risk"
+
+    async def test_warming_up_response_carries_retry_after(self):
+        response = await global_exception_handler(MagicMock(), ServiceWarmingUp("risk"))
+
+        assert response.status_code == 503
+        assert json.loads(response.body) == {"status": "warming_up", "service": "risk"}
+        assert response.headers["Retry-After"] == "5"
+
+    async def test_503_when_risk_service_silent(self):
+        bus = MemoryMessagingClient()
+        await bus.connect()
+
+        with patch("src.api.routes.risk.get_config", return_value=_config()):
+            with pytest.raises(HTTPException) as exc:
+                await current_risk_state(timeout=0.05, messaging=bus)
+
+        assert exc.value.status_code == 503
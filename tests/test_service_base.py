@@ -0,0 +1,154 @@
+"""Tests for src/services/base.py — shared service scaffolding."""
+
+import json
+import logging
+from unittest.mock import MagicMock, patch
+
+from fastapi.testclient import TestClient
+from prometheus_client import REGISTRY
+
+from src.api.routes.system import _collect_service_health
+from src.logging_config import _CorrelationJsonFormatter
+from src.messaging import MemoryMessagingClient
+from src.services.base import (
+    BaseService,
+    build_debug_server,
+    create_app,
+    create_debug_app,
+)
+
+
+class _DummyService(BaseService):
+    async def on_startup(self) -> None:
+        pass
+
+    async def on_shutdown(self) -> None:
+        pass
+
+
+class TestCreateApp:
+
+    def test_two_services_coexist_in_one_process(self):
+        """Each service gets its own app and routes; logging is set up once."""
+        first = _DummyService("alpha")
+        second = _DummyService("beta")
+
+        app_a = create_app(first)
+        handlers_after_first = list(logging.getLogger().handlers)
+        app_b = create_app(second)
+
+        assert app_a is not app_b
+        assert logging.getLogger().handlers == handlers_after_first
+
+        # No lifespan context: services are not started, only routed.
+        health_a = TestClient(app_a).get("/health").json()
+        health_b = TestClient(app_b).get("/health").json()
+        assert health_a == {"service": "alpha", "status": "starting"}
+        assert health_b == {"service": "beta", "status": "starting"}
+
+        assert TestClient(app_a).get("/metrics").status_code == 200
+        assert TestClient(app_b).get("/metrics").status_code == 200
+
+
+_chatty_log = logging.getLogger("tests.service_base.chatty")
+
+
+class _ChattyService(_DummyService):
+    async def on_startup(self) -> None:
+        _chatty_log.info("started")
+
+
+class _JsonCapture(logging.Handler):
+    def __init__(self) -> None:
+        super().__init__()
+        self.setFormatter(_CorrelationJsonFormatter("process", fmt="%(message)s"))
+        self.records = []
+
+    def emit(self, record: logging.LogRecord) -> None:
+        self.records.append(json.loads(self.format(record)))
+
+
+class TestServiceNameInLogs:
+
+    def test_cohosted_services_log_under_their_own_names(self):
+        capture = _JsonCapture()
+        _chatty_log.addHandler(capture)
+        _chatty_log.setLevel(logging.INFO)
+
+        async def _ping():
+            _chatty_log.info("ping")
+            return {}
+
+        try:
+            for name in ("alpha", "beta"):
+                app = create_app(_ChattyService(name))
+                app.add_api_route("/ping", _ping)
+                with TestClient(app) as client:
+                    client.get("/ping")
+            _chatty_log.info("outside")
+        finally:
+            _chatty_log.removeHandler(capture)
+
+        assert [(r["message"], r["service"]) for r in capture.records] == [
+            ("started", "alpha"),
+            ("ping", "alpha"),
+            ("started", "beta"),
+            ("ping", "beta"),
+            ("outside", "process"),
+        ]
+
+
+class TestBuildInfo:
+
+    async def test_start_registers_build_info(self):
+        svc = _DummyService("gamma")
+        await svc.start()
+
+        from src.metrics import BUILD_COMMIT, BUILD_VERSION
+
+        labels = {"service": "gamma", "version": BUILD_VERSION, "commit": BUILD_COMMIT}
+        assert REGISTRY.get_sample_value("build_info", labels) == 1.0
+        assert REGISTRY.get_sample_value(
+            "service_start_time_seconds", {"service": "gamma"}
+        ) > 0
+
+
+class TestHealthPing:
+
+    async def test_started_service_answers_health_ping(self):
+        """A started service replies to health.ping with name, mode and uptime."""
+        bus = MemoryMessagingClient()
+        await bus.connect()
+        config = MagicMock()
+        config.messaging.subjects = {"health_ping": "health.ping"}
+
+        svc = _DummyService("alpha")
+        svc.messaging = bus
+        svc.config = config
+        svc.set_mode("paper")
+        await svc.start()
+
+        with patch("src.api.routes.system.get_config", return_value=config):
+            replies = await _collect_service_health(bus, 0.05)
+
+        assert replies["alpha"]["status"] == "ok"
+        assert replies["alpha"]["mode"] == "paper"
+        assert replies["alpha"]["uptime_seconds"] >= 0
+
+        await svc.stop()
+        assert bus.subscribers["health.ping"] == []
+
+
+class TestDebugServer:
+
+    def test_debug_server_disabled_by_default(self):
+        config = MagicMock(enable_pprof=False)
+        with patch("src.services.base.load_config", return_value=config):
+            assert build_debug_server() is None
+
+    def test_debug_app_reports_asyncio_tasks(self):
+        response = TestClient(create_debug_app()).get("/debug/pprof/tasks")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["total"] >= 1
+        assert sum(body["by_coroutine"].values()) == body["total"]
@@ -89,3 +89,23 @@ def test_trade_logger_handles_missing_optional_fields(tmp_path):
     assert row["config_id"] == ""
     assert row["extra"] == ""
     assert row["risk_blocked_before_entry"] == ""
+
+
+def test_trade_logger_never_writes_scientific_notation(tmp_path):
+    trade = _sample_trade()
+    trade.update({"size": 1e-05, "fees": 2.5e-07, "realized_pnl_pct": -3e-06})
+
+    for decimals in (None, 8):
+        csv_path = tmp_path / f"trades_{decimals}.csv"
+        TradeLogger(csv_path, decimals=decimals).log_completed_trade(trade)
+        text = csv_path.read_text(encoding="utf-8")
+        assert "e-0" not in text.lower()
+
+        with csv_path.open(newline="", encoding="utf-8") as handle:
+            row = next(csv.DictReader(handle))
+        if decimals is None:
+            assert row["size"] == "0.00001"
+            assert row["fees"] == "0.00000025"
+        else:
+            assert row["size"] == "0.00001000"
+            assert row["entry_price"] == "100.00000000"
@@ -0,0 +1,131 @@
+#!/usr/bin/env python3
+"""
+Paper broker determinism self-test.
+
+Replays a fixed dataset and order log through ``PaperBroker`` twice with the
+same seed and requires the two execution-report streams to be byte-identical.
+Wall-clock time or unseeded randomness leaking into the fill path shows up as
+a divergence: the first differing report is printed and the exit code is 1.
+
+Usage:
+    python tools/check_paper_determinism.py
+    python tools/check_paper_determinism.py --dataset csv://sample_data/btc_4h.csv \\
+        --orders sample_data/sweep_orders.csv --seed 7
+"""
+
+import argparse
+import asyncio
+import hashlib
+import json
+import logging
+import sys
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+# Add project root to path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from src.config import LatencyConfig, PaperConfig, PartialFillConfig
+from src.database import DatabaseManager
+from tools.run_paper_sweep import load_orders, load_snapshots, run_combination
+
+logging.basicConfig(
+    level=logging.INFO,
+    format="%(asctime)s [%(levelname)s] %(message)s",
+)
+logger = logging.getLogger(__name__)
+
+RUN_ID = "determinism"
+
+
+def build_config(seed: int) -> PaperConfig:
+    """Exercise every seeded draw: latency, partial-fill slicing and rejects."""
+    return PaperConfig(
+        seed=seed,
+        latency_ms=LatencyConfig(mean=50.0, p95=120.0),
+        partial_fill=PartialFillConfig(enabled=True, randomize=True),
+        partial_reject_rate=0.2,
+    )
+
+
+async def collect_reports(
+    paper_config: PaperConfig,
+    snapshots: List[Any],
+    orders: List[Dict[str, Any]],
+) -> List[str]:
+    """Run one replay on a fresh database; return canonical JSON per report."""
+    database = DatabaseManager(":memory:")
+    await database.initialize()
+    reports: List[Dict[str, Any]] = []
+    try:
+        await run_combination(
+            run_id=RUN_ID,
+            paper_config=paper_config,
+            snapshots=snapshots,
+            orders=orders,
+            database=database,
+            initial_balance=10_000.0,
+            reports=reports,
+        )
+    finally:
+        await database.close()
+    return [json.dumps(report, sort_keys=True, default=str) for report in reports]
+
+
+def first_divergence(first: List[str], second: List[str]) -> Optional[int]:
+    """Index of the first differing report, or ``None`` if the streams match."""
+    for index, (a, b) in enumerate(zip(first, second)):
+        if a != b:
+            return index
+    if len(first) != len(second):
+        return min(len(first), len(second))
+    return None
+
+
+async def check(dataset: str, orders_path: str, symbol: str, seed: int) -> int:
+    snapshots = load_snapshots(dataset, symbol)
+    orders = load_orders(orders_path)
+    if not snapshots:
+        logger.error("Determinism dataset is empty: %s", dataset)
+        return 2
+
+    paper_config = build_config(seed)
+    first = await collect_reports(paper_config, snapshots, orders)
+    second = await collect_reports(paper_config, snapshots, orders)
+
+    index = first_divergence(first, second)
+    if index is None:
+        digest = hashlib.sha256("\n".join(first).encode("utf-8")).hexdigest()
+        logger.info(
+            "Deterministic: %d execution reports identical across runs (sha256 %s)",
+            len(first),
+            digest[:16],
+        )
+        return 0
+
+    logger.error(
+        "Execution reports diverge at #%d (%d vs %d reports)",
+        index,
+        len(first),
+        len(second),
+    )
+    print(f"run 1: {first[index] if index < len(first) else '<missing>'}")
+    print(f"run 2: {second[index] if index < len(second) else '<missing>'}")
+    return 1
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description="Paper broker determinism self-test")
+    parser.add_argument("--dataset", default="csv://sample_data/btc_4h.csv")
+    parser.add_argument("--orders", default="sample_data/sweep_orders.csv")
+    parser.add_argument(
+        "--symbol", default="BTCUSDT", help="Symbol when the dataset has no symbol column"
+    )
+    parser.add_argument("--seed", type=int, default=1337)
+    args = parser.parse_args()
+
+    sys.exit(asyncio.run(check(args.dataset, args.orders, args.symbol, args.seed)))
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,317 @@
+#!/usr/bin/env python3
+"""
+Paper broker parameter sweep.
+
+Replays one market dataset and one order log through ``PaperBroker`` once per
+``PaperConfig`` combination (e.g. a grid of slippage/fee/OFI coefficients).
+Each run gets its own ``run_id`` so persisted orders and trades stay isolated,
+and produces a summary of fill metrics.  Summaries are written per run as
+JSON and consolidated into a single parameters -> metrics CSV.
+
+Usage:
+    python tools/run_paper_sweep.py configs/sweeps/paper_calibration_example.yaml
+"""
+
+import argparse
+import asyncio
+import csv
+import itertools
+import json
+import logging
+import sys
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+import pandas as pd
+import yaml
+
+# Add project root to path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from src.config import PaperConfig
+from src.database import DatabaseManager
+from src.models import MarketSnapshot
+from src.paper_trader import PaperBroker
+from src.services.replay import ReplayService
+
+logging.basicConfig(
+    level=logging.INFO,
+    format="%(asctime)s [%(levelname)s] %(message)s",
+)
+logger = logging.getLogger(__name__)
+
+SUMMARY_FIELDS = [
+    "orders",
+    "fills",
+    "rejects",
+    "maker_ratio",
+    "avg_slippage_bps",
+    "fees",
+    "funding",
+    "realized_pnl",
+    "net_pnl",
+    "final_balance",
+]
+
+
+def expand_combinations(spec: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Return the parameter combinations described by a sweep spec.
+
+    ``grid`` expands to its cartesian product and ``runs`` appends explicit
+    combinations; with neither, the sweep is a single run of ``base``.
+    """
+    combinations: List[Dict[str, Any]] = []
+    grid: Dict[str, List[Any]] = spec.get("grid") or {}
+    if grid:
+        keys = list(grid.keys())
+        for values in itertools.product(*(grid[key] for key in keys)):
+            combinations.append(dict(zip(keys, values, strict=True)))
+    combinations.extend(dict(run) for run in spec.get("runs") or [])
+    return combinations or [{}]
+
+
+def build_paper_config(base: Dict[str, Any], params: Dict[str, Any]) -> PaperConfig:
+    """Merge ``params`` over ``base`` (nested sections field by field) and validate."""
+    merged = PaperConfig.model_validate(base).model_dump()
+    for key, value in params.items():
+        if isinstance(value, dict) and isinstance(merged.get(key), dict):
+            merged[key] = {**merged[key], **value}
+        else:
+            merged[key] = value
+    return PaperConfig.model_validate(merged)
+
+
+def load_snapshots(source: str, default_symbol: str) -> List[MarketSnapshot]:
+    """Load bars with the replay service's reader and snapshot builder."""
+    df = ReplayService._read_source(source)
+    if df.empty:
+        return []
+    df["timestamp"] = pd.to_datetime(df["timestamp"], utc=True)
+    df = ReplayService._attach_funding(df.sort_values("timestamp"))
+
+    snapshots: List[MarketSnapshot] = []
+    for _, row in df.iterrows():
+        open_price = float(row.get("open", row.get("close", 0)))
+        close = float(row.get("close", open_price))
+        data = ReplayService._build_snapshot(
+            row.get("symbol", default_symbol),
+            ReplayService._coerce_timestamp(row["timestamp"]),
+            open_price,
+            float(row.get("high", open_price)),
+            float(row.get("low", open_price)),
+            close,
+            float(row.get("volume", 1)),
+            funding_rate=float(row.get("funding_rate", 0.0)),
+        )
+        snapshots.append(MarketSnapshot.model_validate(data))
+    return snapshots
+
+
+def load_orders(path: str) -> List[Dict[str, Any]]:
+    """Load the order log (timestamp, symbol, side, order_type, quantity[, price])."""
+    df = pd.read_csv(path)
+    df["timestamp"] = pd.to_datetime(df["timestamp"], utc=True)
+    orders = []
+    for _, row in df.sort_values("timestamp").iterrows():
+        price = row.get("price")
+        orders.append(
+            {
+                "timestamp": row["timestamp"].to_pydatetime(),
+                "symbol": row["symbol"],
+                "side": row["side"],
+                "order_type": row.get("order_type", "market"),
+                "quantity": float(row["quantity"]),
+                "price": None if pd.isna(price) else float(price),
+            }
+        )
+    return orders
+
+
+async def run_combination(
+    *,
+    run_id: str,
+    paper_config: PaperConfig,
+    snapshots: List[MarketSnapshot],
+    orders: List[Dict[str, Any]],
+    database: DatabaseManager,
+    initial_balance: float,
+    reports: Optional[List[Dict[str, Any]]] = None,
+) -> Dict[str, Any]:
+    """Replay ``snapshots`` and submit ``orders`` on the data clock; summarise fills.
+
+    Execution reports are appended to ``reports`` when given.
+    """
+    reports = [] if reports is None else reports
+
+    async def _collect(report: Dict[str, Any]) -> None:
+        reports.append(report)
+
+    clock = [snapshots[0].timestamp if snapshots else datetime.now(timezone.utc)]
+    broker = PaperBroker(
+        config=paper_config,
+        database=database,
+        mode="backtest",
+        run_id=run_id,
+        initial_balance=initial_balance,
+        execution_listener=_collect,
+        time_provider=lambda: clock[0],
+    )
+
+    submitted = 0
+    rejected = 0
+    pending = list(orders)
+    try:
+        for snapshot in snapshots:
+            clock[0] = snapshot.timestamp
+            await broker.update_market(snapshot.model_copy())
+            while pending and pending[0]["timestamp"] <= snapshot.timestamp:
+                order = pending.pop(0)
+                submitted += 1
+                try:
+                    await broker.place_order(
+                        symbol=order["symbol"],
+                        side=order["side"],
+                        order_type=order["order_type"],
+                        quantity=order["quantity"],
+                        price=order["price"],
+                        client_id=f"{run_id}-{submitted:05d}",
+                    )
+                except (RuntimeError, ValueError) as exc:
+                    rejected += 1
+                    logger.debug("%s: order rejected: %s", run_id, exc)
+            await broker.wait_for_fills()
+        balance = (await broker.get_account_balance())["totalWalletBalance"]
+    finally:
+        await broker.close()
+
+    fills = [r for r in reports if r.get("executed")]
+    makers = sum(1 for r in fills if r.get("maker"))
+    fees = sum(r.get("fees", 0.0) for r in fills)
+    funding = sum(r.get("funding", 0.0) for r in fills)
+    realized = sum(r.get("realized_pnl", 0.0) for r in fills)
+    return {
+        "run_id": run_id,
+        "orders": submitted,
+        "fills": len(fills),
+        "rejects": rejected + sum(1 for r in reports if r.get("reason")),
+        "maker_ratio": makers / len(fills) if fills else 0.0,
+        "avg_slippage_bps": (
+            sum(r.get("slippage_bps", 0.0) for r in fills) / len(fills) if fills else 0.0
+        ),
+        "fees": fees,
+        "funding": funding,
+        "realized_pnl": realized,
+        "net_pnl": realized - fees - funding,
+        "final_balance": balance,
+    }
+
+
+async def run_sweep(
+    spec: Dict[str, Any], output_dir: Path, db_url: str = ":memory:"
+) -> List[Dict[str, Any]]:
+    """Run every combination in ``spec`` and write per-run JSON plus ``sweep.csv``."""
+    default_symbol = spec.get("symbol", "BTCUSDT")
+    snapshots = load_snapshots(spec["dataset"], default_symbol)
+    orders = load_orders(spec["orders"])
+    if not snapshots:
+        raise ValueError(f"Sweep dataset is empty: {spec['dataset']}")
+
+    combinations = expand_combinations(spec)
+    sweep_id = datetime.now(timezone.utc).strftime("%Y%m%d%H%M%S")
+    output_dir.mkdir(parents=True, exist_ok=True)
+    logger.info(
+        "Sweep %s: %d combinations over %d bars and %d orders",
+        sweep_id,
+        len(combinations),
+        len(snapshots),
+        len(orders),
+    )
+
+    database = DatabaseManager(db_url)
+    await database.initialize()
+    rows: List[Dict[str, Any]] = []
+    try:
+        for index, params in enumerate(combinations):
+            run_id = f"sweep-{sweep_id}-{index:03d}"
+            try:
+                paper_config = build_paper_config(spec.get("base") or {}, params)
+            except ValueError as exc:
+                logger.error("%s: invalid parameters %s: %s", run_id, params, exc)
+                continue
+
+            summary = await run_combination(
+                run_id=run_id,
+                paper_config=paper_config,
+                snapshots=snapshots,
+                orders=orders,
+                database=database,
+                initial_balance=float(spec.get("initial_balance", 10_000.0)),
+            )
+            summary["params"] = params
+            (output_dir / f"{run_id}.json").write_text(
+                json.dumps(summary, indent=2, default=str), encoding="utf-8"
+            )
+            logger.info(
+                "%s %s: fills=%d net_pnl=%.4f avg_slippage_bps=%.3f",
+                run_id,
+                params,
+                summary["fills"],
+                summary["net_pnl"],
+                summary["avg_slippage_bps"],
+            )
+            rows.append(summary)
+    finally:
+        await database.close()
+
+    write_csv(rows, output_dir / "sweep.csv")
+    return rows
+
+
+def write_csv(rows: List[Dict[str, Any]], path: Path) -> None:
+    """Write one line per run: run_id, each swept parameter, then the metrics."""
+    param_keys: List[str] = []
+    for row in rows:
+        for key in row["params"]:
+            if key not in param_keys:
+                param_keys.append(key)
+
+    with open(path, "w", newline="", encoding="utf-8") as f:
+        writer = csv.writer(f)
+        writer.writerow(["run_id", *param_keys, *SUMMARY_FIELDS])
+        for row in rows:
+            params = row["params"]
+            writer.writerow(
+                [
+                    row["run_id"],
+                    *(_csv_value(params.get(key)) for key in param_keys),
+                    *(row[field] for field in SUMMARY_FIELDS),
+                ]
+            )
+    logger.info("Wrote %d sweep rows to %s", len(rows), path)
+
+
+def _csv_value(value: Optional[Any]) -> Any:
+    return json.dumps(value) if isinstance(value, (dict, list)) else value
+
+
+async def main():
+    parser = argparse.ArgumentParser(description="Sweep PaperConfig over a replay")
+    parser.add_argument("spec", help="Sweep spec YAML (dataset, orders, base, grid, runs)")
+    parser.add_argument(
+        "--output-dir", default="results/paper_sweep", help="Output directory"
+    )
+    parser.add_argument(
+        "--db", default=":memory:", help="Database URL for persisted sweep runs"
+    )
+    args = parser.parse_args()
+
+    with open(args.spec, "r", encoding="utf-8") as f:
+        spec = yaml.safe_load(f) or {}
+
+    await run_sweep(spec, Path(args.output_dir), db_url=args.db)
+    logger.info("Sweep complete.")
+
+
+if __name__ == "__main__":
+    asyncio.run(main())
@@ -0,0 +1,104 @@
+"""Wire Codec Benchmark
+
+Times encode/decode of representative market-data and execution-report
+messages with each messaging codec (``messaging.encoding``) so the JSON vs
+msgpack trade-off can be measured on the target host.
+
+Usage:
+    python tools/wire_codec_bench.py                 # 100k iterations
+    python tools/wire_codec_bench.py --iterations 500000
+"""
+
+import argparse
+import sys
+import time
+from datetime import datetime, timezone
+from pathlib import Path
+
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from src.messaging import WIRE_ENCODINGS, decode_payload, encode_payload, msgpack
+from src.services.replay import ReplayService
+
+MARKET_DATA = ReplayService._build_snapshot(
+    "BTCUSDT",
+    datetime(2024, 1, 1, tzinfo=timezone.utc),
+    50000.0,
+    50120.5,
+    49950.25,
+    50080.75,
+    12.5,
+    funding_rate=0.0001,
+)
+
+EXECUTION_REPORT = {
+    "order_id": "paper-5f2c1e9a",
+    "fill_id": "paper-5f2c1e9a-1",
+    "client_id": "paper-5f2c1e9a",
+    "symbol": "BTCUSDT",
+    "executed": True,
+    "price": 50085.12,
+    "mark_price": 50080.75,
+    "quantity": 0.015,
+    "fees": 0.7512768,
+    "funding": 0.0,
+    "realized_pnl": 0.0,
+    "slippage_bps": 0.87,
+    "achieved_vs_signal_bps": -0.87,
+    "maker": False,
+    "latency_ms": 42.0,
+    "ack_latency_ms": 42.0,
+    "mode": "paper",
+    "run_id": "exec-20240101000000",
+    "timestamp": "2024-01-01T00:00:00.042000+00:00",
+    "is_shadow": False,
+    "error": "",
+    "reduce_only": False,
+    "order_type": "market",
+    "stop_price": None,
+    "initial_price": None,
+}
+
+
+def bench(message: dict, encoding: str, iterations: int) -> tuple[float, float, int]:
+    """Return (encode µs/op, decode µs/op, encoded size in bytes)."""
+    payload = encode_payload(message, encoding)
+
+    start = time.perf_counter()
+    for _ in range(iterations):
+        encode_payload(message, encoding)
+    encode_us = (time.perf_counter() - start) / iterations * 1e6
+
+    start = time.perf_counter()
+    for _ in range(iterations):
+        decode_payload(payload)
+    decode_us = (time.perf_counter() - start) / iterations * 1e6
+
+    return encode_us, decode_us, len(payload)
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description="Benchmark messaging wire codecs")
+    parser.add_argument("--iterations", type=int, default=100_000)
+    args = parser.parse_args()
+
+    encodings = [e for e in WIRE_ENCODINGS if e != "msgpack" or msgpack is not None]
+    if len(encodings) < len(WIRE_ENCODINGS):
+        print("  msgpack not installed; benchmarking json only")
+
+    print(f"\n  {'Message':<18} {'Codec':<8} {'Encode µs':>10} {'Decode µs':>10} {'Bytes':>7}")
+    print(f"  {'─' * 57}")
+    for name, message in (
+        ("market_data", MARKET_DATA),
+        ("execution_report", EXECUTION_REPORT),
+    ):
+        for encoding in encodings:
+            encode_us, decode_us, size = bench(message, encoding, args.iterations)
+            print(
+                f"  {name:<18} {encoding:<8} {encode_us:>10.2f} {decode_us:>10.2f} {size:>7}"
+            )
+    print()
+
+
+if __name__ == "__main__":
+    main()